@@ -0,0 +1,332 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/eason-lee/l-rpc/status"
+)
+
+// ErrOpen 熔断器打开, 调用被拒绝
+var ErrOpen = errors.New("circuit breaker is open")
+
+func init() {
+	status.RegisterError(ErrOpen, status.Unavailable, "CIRCUIT_OPEN")
+}
+
+// State 熔断器状态
+type State int
+
+const (
+	// StateClosed 正常放行调用, 统计滑动窗口内的失败率和慢调用率
+	StateClosed State = iota
+	// StateOpen 拒绝所有调用, 经过 OpenTimeout 后进入半开状态
+	StateOpen
+	// StateHalfOpen 放行有限的探测调用, 全部成功后关闭, 任一失败重新打开
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Config 熔断器配置, 未设置的字段使用 DefaultConfig 中的值
+type Config struct {
+	// 滑动窗口内统计的最近调用数
+	WindowSize int
+	// 窗口内至少有 MinCalls 次调用才计算失败率
+	MinCalls int
+	// 失败率达到该值时打开, 取值 0~1
+	FailureRateThreshold float64
+	// 耗时超过该值的调用视为慢调用, 为 0 时不统计慢调用
+	SlowCallDuration time.Duration
+	// 慢调用率达到该值时打开, 取值 0~1
+	SlowCallRateThreshold float64
+	// 打开状态持续的时间
+	OpenTimeout time.Duration
+	// 半开状态允许的探测调用数
+	HalfOpenMaxCalls int
+	// 判断调用是否失败, 默认 Unavailable、DeadlineExceeded、Internal、ResourceExhausted
+	// 以及未携带状态的错误(如网络错误)视为失败, 调用方取消不视为失败
+	IsFailure func(err error) bool
+	// 状态变化时回调, 在状态变化的调用方协程中执行, 不能阻塞
+	OnStateChange func(name string, from, to State)
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig() Config {
+	return Config{
+		WindowSize:            20,
+		MinCalls:              10,
+		FailureRateThreshold:  0.5,
+		SlowCallRateThreshold: 1,
+		OpenTimeout:           5 * time.Second,
+		HalfOpenMaxCalls:      3,
+		IsFailure:             isFailure,
+	}
+}
+
+func (c Config) withDefaults() Config {
+	def := DefaultConfig()
+	if c.WindowSize <= 0 {
+		c.WindowSize = def.WindowSize
+	}
+	if c.MinCalls <= 0 {
+		c.MinCalls = def.MinCalls
+	}
+	if c.FailureRateThreshold <= 0 {
+		c.FailureRateThreshold = def.FailureRateThreshold
+	}
+	if c.SlowCallRateThreshold <= 0 {
+		c.SlowCallRateThreshold = def.SlowCallRateThreshold
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = def.OpenTimeout
+	}
+	if c.HalfOpenMaxCalls <= 0 {
+		c.HalfOpenMaxCalls = def.HalfOpenMaxCalls
+	}
+	if c.IsFailure == nil {
+		c.IsFailure = def.IsFailure
+	}
+	return c
+}
+
+func isFailure(err error) bool {
+	// 调用方主动取消不代表实例不健康
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return true
+	}
+	switch st.Code {
+	case status.Unavailable, status.DeadlineExceeded, status.Internal, status.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+// outcome 窗口中的一次调用结果
+type outcome struct {
+	failed bool
+	slow   bool
+}
+
+// Breaker 熔断器
+type Breaker struct {
+	name   string
+	config Config
+	now    func() time.Time
+
+	mu        sync.Mutex
+	state     State
+	window    []outcome // 环形缓冲区
+	next      int
+	count     int
+	failures  int
+	slows     int
+	openUntil time.Time
+	probes    int // 半开状态已放行的探测调用数
+	successes int // 半开状态已成功的探测调用数
+}
+
+// New 创建熔断器, name 用于状态变化回调
+func New(name string, config Config) *Breaker {
+	config = config.withDefaults()
+	return &Breaker{
+		name:   name,
+		config: config,
+		now:    time.Now,
+		window: make([]outcome, config.WindowSize),
+	}
+}
+
+// State 返回当前状态
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	from := b.state
+	state := b.currentState()
+	b.mu.Unlock()
+
+	b.notify(from, state)
+	return state
+}
+
+// Ready 是否可以放行调用, 不占用半开状态的探测名额
+func (b *Breaker) Ready() bool {
+	b.mu.Lock()
+	from := b.state
+	ready := true
+	switch b.currentState() {
+	case StateOpen:
+		ready = false
+	case StateHalfOpen:
+		ready = b.probes < b.config.HalfOpenMaxCalls
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+	return ready
+}
+
+// Allow 放行一次调用, 放行后必须调用 Done 记录结果
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	from := b.state
+	allowed := true
+	switch b.currentState() {
+	case StateOpen:
+		allowed = false
+	case StateHalfOpen:
+		if b.probes < b.config.HalfOpenMaxCalls {
+			b.probes++
+		} else {
+			allowed = false
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+	return allowed
+}
+
+// Done 记录一次调用的结果和耗时
+func (b *Breaker) Done(err error, duration time.Duration) {
+	result := outcome{
+		failed: b.config.IsFailure(err),
+		slow:   b.config.SlowCallDuration > 0 && duration >= b.config.SlowCallDuration,
+	}
+
+	b.mu.Lock()
+	from := b.state
+	switch b.currentState() {
+	case StateClosed:
+		b.record(result)
+		if b.tripped() {
+			b.open()
+		}
+	case StateHalfOpen:
+		if result.failed || result.slow {
+			b.open()
+			break
+		}
+		b.successes++
+		if b.successes >= b.config.HalfOpenMaxCalls {
+			b.close()
+		}
+	}
+	// 打开状态下收到的是打开前放行的调用结果, 忽略
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+}
+
+// currentState 返回当前状态, 打开超时后转为半开
+func (b *Breaker) currentState() State {
+	if b.state == StateOpen && !b.now().Before(b.openUntil) {
+		b.state = StateHalfOpen
+		b.probes = 0
+		b.successes = 0
+	}
+	return b.state
+}
+
+func (b *Breaker) record(result outcome) {
+	if b.count == len(b.window) {
+		old := b.window[b.next]
+		if old.failed {
+			b.failures--
+		}
+		if old.slow {
+			b.slows--
+		}
+	} else {
+		b.count++
+	}
+	b.window[b.next] = result
+	b.next = (b.next + 1) % len(b.window)
+	if result.failed {
+		b.failures++
+	}
+	if result.slow {
+		b.slows++
+	}
+}
+
+func (b *Breaker) tripped() bool {
+	if b.count < b.config.MinCalls {
+		return false
+	}
+	total := float64(b.count)
+	return float64(b.failures)/total >= b.config.FailureRateThreshold ||
+		(b.config.SlowCallDuration > 0 && float64(b.slows)/total >= b.config.SlowCallRateThreshold)
+}
+
+func (b *Breaker) open() {
+	b.state = StateOpen
+	b.openUntil = b.now().Add(b.config.OpenTimeout)
+}
+
+func (b *Breaker) close() {
+	b.state = StateClosed
+	b.next, b.count, b.failures, b.slows = 0, 0, 0, 0
+}
+
+func (b *Breaker) notify(from, to State) {
+	if from != to && b.config.OnStateChange != nil {
+		b.config.OnStateChange(b.name, from, to)
+	}
+}
+
+// Group 按名称管理一组熔断器, 客户端以服务实例ID作为名称
+type Group struct {
+	config Config
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+}
+
+// NewGroup 创建熔断器组, 组内的熔断器使用相同的配置
+func NewGroup(config Config) *Group {
+	return &Group{
+		config:   config,
+		breakers: make(map[string]*Breaker),
+	}
+}
+
+// Get 获取指定名称的熔断器, 不存在时创建
+func (g *Group) Get(name string) *Breaker {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	b, ok := g.breakers[name]
+	if !ok {
+		b = New(name, g.config)
+		g.breakers[name] = b
+	}
+	return b
+}
+
+// Remove 移除指定名称的熔断器, 用于服务实例下线后释放状态
+func (g *Group) Remove(name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.breakers, name)
+}
@@ -0,0 +1,151 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/status"
+	"github.com/stretchr/testify/suite"
+)
+
+type BreakerTestSuite struct {
+	suite.Suite
+	now         time.Time
+	transitions []State
+	breaker     *Breaker
+}
+
+func (s *BreakerTestSuite) SetupTest() {
+	s.now = time.Now()
+	s.transitions = nil
+	s.breaker = New("instance-1", Config{
+		WindowSize:            10,
+		MinCalls:              4,
+		FailureRateThreshold:  0.5,
+		SlowCallDuration:      100 * time.Millisecond,
+		SlowCallRateThreshold: 0.5,
+		OpenTimeout:           time.Second,
+		HalfOpenMaxCalls:      2,
+		OnStateChange: func(name string, from, to State) {
+			s.Equal("instance-1", name)
+			s.transitions = append(s.transitions, to)
+		},
+	})
+	s.breaker.now = func() time.Time { return s.now }
+}
+
+func (s *BreakerTestSuite) call(err error, duration time.Duration) {
+	s.Require().True(s.breaker.Allow())
+	s.breaker.Done(err, duration)
+}
+
+func (s *BreakerTestSuite) TestFailureRate() {
+	unavailable := status.Error(status.Unavailable, "down")
+
+	// 调用数不足 MinCalls 时不打开
+	s.call(unavailable, 0)
+	s.call(unavailable, 0)
+	s.call(unavailable, 0)
+	s.Equal(StateClosed, s.breaker.State())
+
+	s.call(nil, 0)
+	s.Equal(StateOpen, s.breaker.State())
+	s.False(s.breaker.Ready())
+	s.False(s.breaker.Allow())
+	s.Equal([]State{StateOpen}, s.transitions)
+}
+
+func (s *BreakerTestSuite) TestIgnoreBusinessErrors() {
+	for i := 0; i < 10; i++ {
+		s.call(status.Error(status.NotFound, "no such user"), 0)
+	}
+	s.Equal(StateClosed, s.breaker.State())
+
+	// 调用方取消不视为失败
+	for i := 0; i < 10; i++ {
+		s.call(context.Canceled, 0)
+	}
+	s.Equal(StateClosed, s.breaker.State())
+
+	// 没有状态的错误视为失败
+	for i := 0; i < 5; i++ {
+		s.call(errors.New("connection reset"), 0)
+	}
+	s.Equal(StateOpen, s.breaker.State())
+}
+
+func (s *BreakerTestSuite) TestSlidingWindow() {
+	// 旧的失败移出窗口后不再计入失败率
+	for i := 0; i < 4; i++ {
+		s.call(nil, 0)
+	}
+	s.call(errors.New("failed"), 0)
+	s.call(errors.New("failed"), 0)
+	for i := 0; i < 10; i++ {
+		s.call(nil, 0)
+	}
+	s.call(errors.New("failed"), 0)
+	s.call(errors.New("failed"), 0)
+	s.call(errors.New("failed"), 0)
+	s.call(errors.New("failed"), 0)
+	s.Equal(StateClosed, s.breaker.State())
+	s.call(errors.New("failed"), 0)
+	s.Equal(StateOpen, s.breaker.State())
+}
+
+func (s *BreakerTestSuite) TestSlowCalls() {
+	for i := 0; i < 4; i++ {
+		s.call(nil, 200*time.Millisecond)
+	}
+	s.Equal(StateOpen, s.breaker.State())
+}
+
+func (s *BreakerTestSuite) TestHalfOpen() {
+	for i := 0; i < 4; i++ {
+		s.call(errors.New("failed"), 0)
+	}
+	s.Equal(StateOpen, s.breaker.State())
+
+	// 打开超时后进入半开, 只放行 HalfOpenMaxCalls 次探测
+	s.now = s.now.Add(time.Second)
+	s.True(s.breaker.Ready())
+	s.True(s.breaker.Allow())
+	s.True(s.breaker.Allow())
+	s.False(s.breaker.Ready())
+	s.False(s.breaker.Allow())
+
+	// 探测全部成功后关闭
+	s.breaker.Done(nil, 0)
+	s.Equal(StateHalfOpen, s.breaker.State())
+	s.breaker.Done(nil, 0)
+	s.Equal(StateClosed, s.breaker.State())
+	s.Equal([]State{StateOpen, StateHalfOpen, StateClosed}, s.transitions)
+}
+
+func (s *BreakerTestSuite) TestHalfOpenFailure() {
+	for i := 0; i < 4; i++ {
+		s.call(errors.New("failed"), 0)
+	}
+	s.now = s.now.Add(time.Second)
+
+	// 任一探测失败重新打开
+	s.call(errors.New("still failing"), 0)
+	s.Equal(StateOpen, s.breaker.State())
+	s.Equal([]State{StateOpen, StateHalfOpen, StateOpen}, s.transitions)
+}
+
+func (s *BreakerTestSuite) TestGroup() {
+	g := NewGroup(Config{})
+	s.Same(g.Get("a"), g.Get("a"))
+	s.NotSame(g.Get("a"), g.Get("b"))
+
+	b := g.Get("a")
+	g.Remove("a")
+	s.NotSame(b, g.Get("a"))
+}
+
+func TestBreakerSuite(t *testing.T) {
+	suite.Run(t, new(BreakerTestSuite))
+}
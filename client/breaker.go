@@ -0,0 +1,22 @@
+package client
+
+import (
+	"github.com/eason-lee/l-rpc/breaker"
+	"github.com/eason-lee/l-rpc/registry"
+)
+
+// breakerBalancer 过滤掉熔断器打开的实例, 负载均衡器只在可用的实例中选择
+type breakerBalancer struct {
+	registry.LoadBalancer
+	breakers *breaker.Group
+}
+
+func (b *breakerBalancer) Select(instances []*registry.ServiceInstance) (*registry.ServiceInstance, error) {
+	available := make([]*registry.ServiceInstance, 0, len(instances))
+	for _, instance := range instances {
+		if b.breakers.Get(instance.ID).Ready() {
+			available = append(available, instance)
+		}
+	}
+	return b.LoadBalancer.Select(available)
+}
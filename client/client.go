@@ -2,131 +2,394 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/eason-lee/l-rpc/breaker"
+	"github.com/eason-lee/l-rpc/codec"
+	"github.com/eason-lee/l-rpc/metadata"
+	"github.com/eason-lee/l-rpc/metrics"
 	"github.com/eason-lee/l-rpc/protocol"
 	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/status"
+	"github.com/eason-lee/l-rpc/trace"
 	"github.com/eason-lee/l-rpc/transport"
 )
 
 // Client RPC客户端
 type Client struct {
-	seq        uint64
-	registry   registry.Registry
-	balancer   registry.LoadBalancer
-	transport  *transport.Client
-	pendingMap sync.Map
+	seq          uint64
+	registry     registry.Registry
+	balancer     registry.LoadBalancer
+	pendingMap   sync.Map
+	codec        codec.Codec
+	interceptors []Interceptor
+
+	// 重试
+	retryPolicy         *RetryPolicy
+	methodRetryPolicies map[string]*RetryPolicy
+	retryBudget         *retryBudget
+
+	// 熔断, 按服务实例ID区分
+	breakers *breaker.Group
+
+	// 降级, 服务方法或服务名 -> 降级处理函数
+	fallbacks map[string]FallbackFunc
+
+	// 链路追踪和监控指标
+	tracer  *trace.Tracer
+	metrics *metrics.RPCMetrics
+
+	// 建立连接时的传输层配置
+	transportOpts []transport.Option
+
+	// 消息体压缩, 服务端地址 -> 服务端可以解压的算法
+	compressors       []transport.CompressType
+	compressMinSize   int
+	serverCompressors sync.Map
+	maxBodySize       int // 连接上允许接收的消息体最大长度, 同时限制解压后的响应
+
+	// 按服务端地址管理的连接池和多路复用连接
+	conns *connManager
+
+	// 多路复用模式
+	multiplex bool
+}
+
+// Option 客户端配置项
+type Option func(*Client)
+
+// WithMultiplex 开启多路复用模式
+// 多个请求共享同一连接并发发送, 响应按消息ID分发给对应的调用
+func WithMultiplex() Option {
+	return func(c *Client) {
+		c.multiplex = true
+	}
+}
+
+// WithCodec 设置客户端默认的参数序列化方式
+func WithCodec(cc codec.Codec) Option {
+	return func(c *Client) {
+		c.codec = cc
+	}
+}
+
+// WithCircuitBreaker 为每个服务实例开启熔断
+// 熔断器打开的实例在负载均衡之前被过滤掉, 所有实例都被熔断时调用立即失败
+func WithCircuitBreaker(config breaker.Config) Option {
+	return func(c *Client) {
+		c.breakers = breaker.NewGroup(config)
+	}
+}
+
+// WithTLS 使用 TLS 连接服务端, config 中设置证书后可用于 mTLS
+// 可以使用 transport.TLSConfig 根据证书文件创建
+func WithTLS(config *tls.Config) Option {
+	return func(c *Client) {
+		c.transportOpts = append(c.transportOpts, transport.WithTLS(config))
+	}
+}
+
+// WithTransportOptions 设置建立连接时的传输层配置, 如 transport.WithEncryptor
+func WithTransportOptions(opts ...transport.Option) Option {
+	return func(c *Client) {
+		c.transportOpts = append(c.transportOpts, opts...)
+	}
+}
+
+// CallOption 单次调用的配置项
+type CallOption func(*Call)
+
+// UseCodec 设置本次调用的参数序列化方式, 优先于客户端默认配置
+func UseCodec(cc codec.Codec) CallOption {
+	return func(call *Call) {
+		call.codec = cc
+	}
 }
 
 // Call 表示一个待处理的调用
 type Call struct {
-	ServiceMethod string      // 格式: "服务.方法"
-	Args         interface{} // 参数
-	Reply        interface{} // 响应
-	Error        error       // 错误信息
-	Done         chan *Call  // 调用完成时的通知通道
+	ServiceMethod string                    // 格式: "服务.方法"
+	Args          interface{}               // 参数
+	Reply         interface{}               // 响应
+	Metadata      map[string]string         // 请求元数据, 随请求头发送给服务端
+	Instance      *registry.ServiceInstance // 最近一次发送选中的服务实例
+	Error         error                     // 错误信息
+	Degraded      bool                      // 调用失败后由降级处理函数产生了结果
+	Done          chan *Call                // 调用完成时的通知通道
+
+	codec      codec.Codec     // 参数序列化方式
+	excluded   map[string]bool // 本次调用中已经失败的实例, 重试时不再选择
+	onDegraded func(err error) // 调用降级时的回调
 }
 
-func NewClient(reg registry.Registry, balancer registry.LoadBalancer) *Client {
-	return &Client{
-		registry: reg,
-		balancer: balancer,
+func NewClient(reg registry.Registry, balancer registry.LoadBalancer, opts ...Option) *Client {
+	c := &Client{
+		registry:        reg,
+		balancer:        balancer,
+		codec:           codec.DefaultCodec,
+		retryBudget:     newRetryBudget(10, 0.1),
+		compressMinSize: transport.DefaultCompressMinSize,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.maxBodySize = transport.MaxBodySize(c.transportOpts...)
+	c.conns = newConnManager(c)
+	return c
 }
 
 // Call 同步调用
-func (c *Client) Call(ctx context.Context, serviceMethod string, args interface{}, reply interface{}) error {
-	call := c.Go(serviceMethod, args, reply, make(chan *Call, 1))
+// ctx 的截止时间会通过 Header.Timeout 传递给服务端, ctx 结束时会通知服务端取消正在执行的请求,
+// 通过 metadata.NewOutgoingContext 设置的元数据会写入请求头
+func (c *Client) Call(ctx context.Context, serviceMethod string, args interface{}, reply interface{}, opts ...CallOption) error {
+	call := c.start(ctx, serviceMethod, args, reply, make(chan *Call, 1), opts)
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
+		// 设置了降级处理函数时等待调用链返回降级结果, 调用链在 ctx 结束后会很快返回
+		if c.lookupFallback(serviceMethod) == nil {
+			return ctx.Err()
+		}
+		return (<-call.Done).Error
 	case call := <-call.Done:
 		return call.Error
 	}
 }
 
 // Go 异步调用
-func (c *Client) Go(serviceMethod string, args interface{}, reply interface{}, done chan *Call) *Call {
+func (c *Client) Go(serviceMethod string, args interface{}, reply interface{}, done chan *Call, opts ...CallOption) *Call {
+	return c.start(context.Background(), serviceMethod, args, reply, done, opts)
+}
+
+func (c *Client) start(ctx context.Context, serviceMethod string, args interface{}, reply interface{}, done chan *Call, opts []CallOption) *Call {
+	call := c.newCall(ctx, serviceMethod, opts)
+	call.Args = args
+	call.Reply = reply
+	call.Done = done
+
+	// 同步调用与异步调用经过同一条拦截器链, 重试和降级在拦截器链内层进行
+	go func() {
+		call.Error = chainInterceptors(c.interceptors, c.invokeWithFallback)(ctx, call)
+		call.done()
+	}()
+	return call
+}
+
+// newCall 创建调用, 携带上下文中待发送的元数据并应用单次调用的配置项
+// 普通调用和流式调用共用
+func (c *Client) newCall(ctx context.Context, serviceMethod string, opts []CallOption) *Call {
 	call := &Call{
 		ServiceMethod: serviceMethod,
-		Args:         args,
-		Reply:        reply,
-		Done:         done,
+		Metadata:      make(map[string]string),
+		codec:         c.codec,
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		for k, v := range md {
+			call.Metadata[k] = v
+		}
+	}
+	for _, opt := range opts {
+		opt(call)
 	}
-
-	go c.send(call)
 	return call
 }
 
-func (c *Client) send(call *Call) {
-	ctx := context.Background()
-	// 生成请求ID
-	seq := atomic.AddUint64(&c.seq, 1)
-	
-	// 构造请求消息
-	req := &protocol.Message{
-		Header: &protocol.Header{
-			ID:          seq,
-			Type:        protocol.TypeRequest,
-			ServiceName: call.ServiceMethod,
-			MethodName:  getMethodFromServiceMethod(call.ServiceMethod),
-		},
+// Close 关闭客户端持有的连接
+func (c *Client) Close() error {
+	return c.conns.close()
+}
+
+// invoke 选择服务实例并完成一次请求, 是调用链的最内层
+func (c *Client) invoke(ctx context.Context, call *Call) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	// 编码参数
-	data, err := encode(call.Args)
+	req, err := c.newRequest(ctx, call)
 	if err != nil {
-		call.Error = err
-		call.done()
-		return
+		return err
 	}
-	req.Data = data
 
-	// 获取服务实例
-	instance, err := c.registry.SelectInstance(call.ServiceMethod, c.balancer)
+	instance, err := c.selectInstance(call, req.Header.ServiceName)
 	if err != nil {
-		call.Error = err
-		call.done()
-		return
+		return err
+	}
+	if err := c.compressRequest(instance, req); err != nil {
+		return err
+	}
+
+	// 放行之后必须通过 Done 报告结果, 因此在发送前最后申请
+	b, err := c.allowInstance(instance)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	span := c.startSpan(ctx, instance, req)
+	observe := c.metrics.Begin(req.Header.ServiceName, req.Header.MethodName)
+	var resp *protocol.Message
+	if c.multiplex {
+		resp, err = c.sendMux(ctx, instance, req)
+	} else {
+		resp, err = c.send(ctx, instance, req)
+	}
+	// 按压缩后的大小统计
+	var respSize int
+	if err == nil {
+		respSize = len(resp.Data)
+		err = c.handleResponse(call, resp)
+	}
+	endSpan(span, err)
+	observe(err, len(req.Data), respSize)
+	if b != nil {
+		b.Done(err, time.Since(start))
+	}
+	return err
+}
+
+// selectInstance 过滤熔断的实例和本次调用已失败的实例后选择服务实例
+func (c *Client) selectInstance(call *Call, serviceName string) (*registry.ServiceInstance, error) {
+	var balancer registry.LoadBalancer = c.balancer
+	if c.breakers != nil {
+		balancer = &breakerBalancer{LoadBalancer: balancer, breakers: c.breakers}
+	}
+	if len(call.excluded) > 0 {
+		balancer = &excludeBalancer{LoadBalancer: balancer, excluded: call.excluded}
+	}
+	instance, err := c.registry.SelectInstance(serviceName, balancer)
+	if err != nil {
+		return nil, err
+	}
+	call.Instance = instance
+	c.conns.watch(serviceName)
+	return instance, nil
+}
+
+// allowInstance 申请实例熔断器的放行, 放行后调用方需要通过 Done 报告结果; 未开启熔断时返回 nil
+// 过滤之后熔断器可能已经打开或半开状态的探测名额已被占用
+func (c *Client) allowInstance(instance *registry.ServiceInstance) (*breaker.Breaker, error) {
+	if c.breakers == nil {
+		return nil, nil
+	}
+	b := c.breakers.Get(instance.ID)
+	if !b.Allow() {
+		return nil, breaker.ErrOpen
+	}
+	return b, nil
+}
+
+// send 通过选中实例的连接池发送请求
+// 上下文结束时由 transport.Client 关闭连接, 服务端随之取消请求
+func (c *Client) send(ctx context.Context, instance *registry.ServiceInstance, req *protocol.Message) (*protocol.Message, error) {
+	pool, err := c.conns.pool(instance.Endpoints[0])
+	if err != nil {
+		return nil, err
+	}
+	return pool.Send(ctx, req)
+}
+
+// sendMux 通过多路复用连接发送请求并等待读协程分发的响应
+func (c *Client) sendMux(ctx context.Context, instance *registry.ServiceInstance, req *protocol.Message) (*protocol.Message, error) {
+	conn, err := c.conns.mux(ctx, instance.Endpoints[0])
+	if err != nil {
+		return nil, err
+	}
+
+	seq := req.Header.ID
+	p := newPendingCall(conn)
+	if err := conn.write(seq, p, req); err != nil {
+		// 连接关闭时可能已由读协程完成该请求
+		if c.removePending(seq) != nil {
+			return nil, err
+		}
 	}
 
-	// 建立连接
-	if c.transport == nil {
-		c.transport, err = transport.NewClient("tcp", instance.Endpoints[0])
-		if err != nil {
-			call.Error = err
-			call.done()
-			return
+	select {
+	case <-p.done:
+		return p.resp, p.err
+	case <-ctx.Done():
+		// 放弃请求并通知服务端取消
+		if c.removePending(seq) != nil {
+			conn.writeCancel(seq)
+			return nil, ctx.Err()
 		}
+		<-p.done
+		return p.resp, p.err
 	}
+}
 
-	// 存储调用信息
-	c.pendingMap.Store(seq, call)
+// newRequest 构造请求消息, 每次发送使用新的请求ID
+func (c *Client) newRequest(ctx context.Context, call *Call) (*protocol.Message, error) {
+	req := &protocol.Message{Header: newHeader(ctx, call, protocol.TypeRequest)}
+	req.Header.ID = atomic.AddUint64(&c.seq, 1)
 
-	// 发送请求
-	resp, err := c.transport.Send(ctx, req)
+	// 编码参数
+	data, err := encode(call.codec, call.Args)
 	if err != nil {
-		c.pendingMap.Delete(seq)
-		call.Error = err
-		call.done()
-		return
+		return nil, err
 	}
+	req.Data = data
+	return req, nil
+}
 
-	// 处理响应
-	if resp.Header.Error != "" {
-		call.Error = ErrorFromString(resp.Header.Error)
-		call.done()
-		return
+// newHeader 构造请求头, ctx 的截止时间通过 Timeout 传递给服务端
+func newHeader(ctx context.Context, call *Call, typ protocol.MessageType) *protocol.Header {
+	serviceName, methodName := splitServiceMethod(call.ServiceMethod)
+	header := &protocol.Header{
+		Type:        typ,
+		Codec:       call.codec.ContentType(),
+		ServiceName: serviceName,
+		MethodName:  methodName,
+		Metadata:    call.Metadata,
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		header.Timeout = time.Until(deadline)
+	}
+	return header
+}
+
+// copyMetadata 将请求头的元数据替换为副本并返回, extra 为之后要写入的键数
+// 请求头的元数据与 call.Metadata 共用, 直接写入会影响重试时发送的其他请求
+func copyMetadata(header *protocol.Header, extra int) map[string]string {
+	md := make(map[string]string, len(header.Metadata)+extra)
+	for k, v := range header.Metadata {
+		md[k] = v
+	}
+	header.Metadata = md
+	return md
+}
+
+// handleResponse 处理响应并解码到 call.Reply
+func (c *Client) handleResponse(call *Call, resp *protocol.Message) error {
+	if err := status.FromHeader(resp.Header); err != nil {
+		return err
+	}
+	if err := c.decompressResponse(call.Instance, resp); err != nil {
+		return err
 	}
 
 	// 解码响应
-	err = decode(resp.Data, call.Reply)
-	if err != nil {
-		call.Error = err
+	return decode(call.codec, resp.Data, call.Reply)
+}
+
+// removePending 移除并返回等待响应的请求, 请求不存在时返回 nil
+func (c *Client) removePending(seq uint64) *pendingCall {
+	v, ok := c.pendingMap.LoadAndDelete(seq)
+	if !ok {
+		return nil
+	}
+	return v.(*pendingCall)
+}
+
+// exclude 记录失败的实例
+func (call *Call) exclude(instanceID string) {
+	if call.excluded == nil {
+		call.excluded = make(map[string]bool)
 	}
-	call.done()
+	call.excluded[instanceID] = true
 }
 
 func (call *Call) done() {
@@ -135,10 +398,10 @@ func (call *Call) done() {
 	}
 }
 
-// 从 ServiceMethod 中解析出方法名
-func getMethodFromServiceMethod(serviceMethod string) string {
+// 将 ServiceMethod 拆分为服务名和方法名
+func splitServiceMethod(serviceMethod string) (string, string) {
 	if i := strings.LastIndex(serviceMethod, "."); i >= 0 {
-		return serviceMethod[i+1:]
+		return serviceMethod[:i], serviceMethod[i+1:]
 	}
-	return serviceMethod
-}
\ No newline at end of file
+	return serviceMethod, ""
+}
@@ -0,0 +1,46 @@
+package client
+
+import (
+	"github.com/eason-lee/l-rpc/protocol"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/transport"
+)
+
+// WithCompressor 开启消息体压缩, types 为偏好顺序
+// 请求会声明客户端可以解压的算法, 服务端同样开启压缩时压缩响应;
+// 收到服务端声明的算法之后, 客户端按偏好顺序选择服务端支持的算法压缩请求
+func WithCompressor(types ...transport.CompressType) Option {
+	return func(c *Client) {
+		c.compressors = types
+	}
+}
+
+// WithCompressMinSize 设置最小压缩大小, 更小的消息体不压缩, 默认 transport.DefaultCompressMinSize
+func WithCompressMinSize(size int) Option {
+	return func(c *Client) {
+		c.compressMinSize = size
+	}
+}
+
+// compressRequest 声明客户端可以解压的算法, 并使用与服务端协商的算法压缩请求
+func (c *Client) compressRequest(instance *registry.ServiceInstance, req *protocol.Message) error {
+	if len(c.compressors) == 0 {
+		return nil
+	}
+
+	copyMetadata(req.Header, 1)[transport.AcceptCompressKey] = transport.FormatCompressTypes(transport.CompressTypes())
+
+	var accepted []transport.CompressType
+	if v, ok := c.serverCompressors.Load(instance.Endpoints[0]); ok {
+		accepted = v.([]transport.CompressType)
+	}
+	return transport.CompressMessage(req, transport.NegotiateCompress(c.compressors, accepted), c.compressMinSize)
+}
+
+// decompressResponse 记录服务端可以解压的算法并解压响应, 解压后的长度不能超过连接上允许的消息体最大长度
+func (c *Client) decompressResponse(instance *registry.ServiceInstance, resp *protocol.Message) error {
+	if accept := resp.Header.Metadata[transport.AcceptCompressKey]; accept != "" && instance != nil {
+		c.serverCompressors.Store(instance.Endpoints[0], transport.ParseCompressTypes(accept))
+	}
+	return transport.DecompressMessage(resp, c.maxBodySize)
+}
@@ -0,0 +1,263 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/eason-lee/l-rpc/protocol"
+	"github.com/eason-lee/l-rpc/transport"
+)
+
+// pendingCall 多路复用连接上等待响应的请求
+type pendingCall struct {
+	conn *muxConn
+	resp *protocol.Message
+	err  error
+	done chan struct{}
+}
+
+func newPendingCall(conn *muxConn) *pendingCall {
+	return &pendingCall{
+		conn: conn,
+		done: make(chan struct{}),
+	}
+}
+
+// muxConn 多路复用连接
+// 同一连接上可以同时存在多个未完成的请求, 由单个读协程按 Header.ID 将响应分发给对应的请求
+type muxConn struct {
+	client *Client
+	trans  *transport.TCPTransport
+
+	streams sync.Map // 流ID -> *Stream
+
+	mu       sync.Mutex
+	closed   bool
+	draining bool // 服务端即将关闭, 不再在该连接上发送新请求
+}
+
+func dialMux(ctx context.Context, c *Client, network, addr string) (*muxConn, error) {
+	trans, err := transport.DialContext(ctx, network, addr, c.transportOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &muxConn{
+		client: c,
+		trans:  trans,
+	}
+	go m.readLoop()
+	return m, nil
+}
+
+// write 登记请求并写出
+func (m *muxConn) write(seq uint64, p *pendingCall, req *protocol.Message) error {
+	// 先登记再检查连接状态, 保证连接关闭时该请求一定能被读协程或调用方之一完成
+	m.client.pendingMap.Store(seq, p)
+	if m.isClosed() {
+		return ErrShutdown
+	}
+
+	if err := m.trans.WriteMessage(req); err != nil {
+		m.writeFailed(err)
+		return err
+	}
+	return nil
+}
+
+// writeCancel 通知服务端取消指定ID的请求
+func (m *muxConn) writeCancel(seq uint64) {
+	m.writeMessage(&protocol.Message{
+		Header: &protocol.Header{
+			ID:   seq,
+			Type: protocol.TypeCancel,
+		},
+	})
+}
+
+// writeMessage 编码并写出一条消息, 写失败时关闭连接
+func (m *muxConn) writeMessage(msg *protocol.Message) error {
+	if err := m.trans.WriteMessage(msg); err != nil {
+		m.writeFailed(err)
+		return err
+	}
+	return nil
+}
+
+// writeFailed 写出失败时关闭连接, 消息超过大小限制或无法编码时没有写出任何数据, 连接仍然可用
+func (m *muxConn) writeFailed(err error) {
+	if errors.Is(err, protocol.ErrFrameTooLarge) || errors.Is(err, protocol.ErrInvalidMessage) {
+		return
+	}
+	m.close(err)
+}
+
+// addStream 登记流, 连接已关闭时返回 ErrShutdown
+func (m *muxConn) addStream(s *Stream) error {
+	// 先登记再检查连接状态, 与 write 相同
+	m.streams.Store(s.core.ID(), s)
+	if m.isClosed() {
+		m.removeStream(s.core.ID())
+		return ErrShutdown
+	}
+	return nil
+}
+
+// cancelStream 以错误中止流并通知服务端取消, 流已结束时不做处理
+func (m *muxConn) cancelStream(id uint64, err error) {
+	s := m.removeStream(id)
+	if s == nil {
+		return
+	}
+	m.writeMessage(&protocol.Message{
+		Header: &protocol.Header{
+			StreamID: id,
+			Type:     protocol.TypeCancel,
+		},
+	})
+	s.core.Abort(err)
+	s.finish()
+}
+
+// removeStream 移除并返回流, 流不存在时返回 nil
+func (m *muxConn) removeStream(id uint64) *Stream {
+	v, ok := m.streams.LoadAndDelete(id)
+	if !ok {
+		return nil
+	}
+	return v.(*Stream)
+}
+
+// readLoop 读取响应并分发给等待中的请求和流
+func (m *muxConn) readLoop() {
+	for {
+		msg, err := m.trans.ReadMessage()
+		if errors.Is(err, protocol.ErrInvalidMessage) || errors.Is(err, protocol.ErrUnsupportedVersion) {
+			// 无法解码的消息直接忽略
+			continue
+		}
+		if err != nil {
+			m.close(err)
+			return
+		}
+		switch msg.Header.Type {
+		case protocol.TypeResponse:
+			// 请求可能已经被取消
+			if p := m.client.removePending(msg.Header.ID); p != nil {
+				p.resp = msg
+				close(p.done)
+			}
+		case protocol.TypeGoAway:
+			m.drain()
+		case protocol.TypeHeartbeat:
+			if err := m.trans.HandleHeartbeat(msg); err != nil {
+				m.close(err)
+				return
+			}
+		case protocol.TypeStreamData, protocol.TypeStreamWindow:
+			if v, ok := m.streams.Load(msg.Header.StreamID); ok {
+				if err := v.(*Stream).core.Deliver(msg); err != nil {
+					// 服务端不遵守流控, 放弃该流并通知服务端取消
+					m.cancelStream(msg.Header.StreamID, err)
+				}
+			}
+		case protocol.TypeStreamClose:
+			// 服务端关闭发送即流结束
+			if s := m.removeStream(msg.Header.StreamID); s != nil {
+				s.core.Deliver(msg)
+				s.core.End()
+				s.finish()
+			}
+		}
+	}
+}
+
+func (m *muxConn) isClosed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.closed
+}
+
+// drain 标记连接不再接收新请求, 已发送的请求继续等待响应
+func (m *muxConn) drain() {
+	m.mu.Lock()
+	m.draining = true
+	m.mu.Unlock()
+}
+
+// retire 不再在连接上发送新请求, 已发送的请求和流全部结束后关闭连接
+func (m *muxConn) retire() {
+	m.drain()
+	go func() {
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for range ticker.C {
+			if m.isClosed() {
+				return
+			}
+			if !m.busy() {
+				m.close(ErrShutdown)
+				return
+			}
+		}
+	}()
+}
+
+// busy 连接上是否还有未完成的请求或流
+func (m *muxConn) busy() bool {
+	busy := false
+	m.streams.Range(func(key, value interface{}) bool {
+		busy = true
+		return false
+	})
+	if busy {
+		return true
+	}
+	m.client.pendingMap.Range(func(key, value interface{}) bool {
+		busy = value.(*pendingCall).conn == m
+		return !busy
+	})
+	return busy
+}
+
+// usable 连接是否可以发送新请求
+func (m *muxConn) usable() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return !m.closed && !m.draining
+}
+
+// close 关闭连接并以错误结束该连接上所有未完成的请求
+func (m *muxConn) close(err error) {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return
+	}
+	m.closed = true
+	m.mu.Unlock()
+
+	m.trans.Close()
+
+	m.streams.Range(func(key, value interface{}) bool {
+		if s := m.removeStream(key.(uint64)); s != nil {
+			s.core.Abort(ErrShutdown)
+			s.finish()
+		}
+		return true
+	})
+
+	m.client.pendingMap.Range(func(key, value interface{}) bool {
+		p := value.(*pendingCall)
+		if p.conn != m {
+			return true
+		}
+		if m.client.removePending(key.(uint64)) != nil {
+			p.err = ErrShutdown
+			close(p.done)
+		}
+		return true
+	})
+}
@@ -0,0 +1,262 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/transport"
+)
+
+// connManager 按服务端地址管理连接
+// 为负载均衡选中的实例按需建立连接, 并订阅服务变更, 实例从注册中心移除后关闭其地址上的连接
+type connManager struct {
+	client *Client
+
+	mu       sync.Mutex
+	pools    map[string]*transport.Client // 地址 -> 连接池
+	muxes    map[string]*muxConn          // 地址 -> 多路复用连接
+	dialing  map[string]*muxDial          // 地址 -> 正在建立的多路复用连接
+	services map[string]map[string]bool   // 已订阅的服务 -> 实例地址
+	ids      map[string]map[string]bool   // 已订阅的服务 -> 实例ID, 实例移除后释放其熔断器
+	closed   bool
+	done     chan struct{}
+}
+
+func newConnManager(c *Client) *connManager {
+	return &connManager{
+		client:   c,
+		pools:    make(map[string]*transport.Client),
+		muxes:    make(map[string]*muxConn),
+		dialing:  make(map[string]*muxDial),
+		services: make(map[string]map[string]bool),
+		ids:      make(map[string]map[string]bool),
+		done:     make(chan struct{}),
+	}
+}
+
+// pool 获取地址对应的连接池, 不存在时创建
+func (m *connManager) pool(addr string) (*transport.Client, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrShutdown
+	}
+	if pool, ok := m.pools[addr]; ok {
+		return pool, nil
+	}
+
+	pool, err := transport.NewClient("tcp", addr, m.client.transportOpts...)
+	if err != nil {
+		return nil, err
+	}
+	m.pools[addr] = pool
+	return pool, nil
+}
+
+// muxDial 一次正在进行的多路复用连接建立, 同一地址同时只建立一个连接, 其他调用等待结果
+type muxDial struct {
+	done     chan struct{}
+	conn     *muxConn
+	err      error
+	canceled bool // 建立连接的调用在完成前被取消, 等待的调用需要重新建立
+}
+
+// mux 获取地址对应的多路复用连接, 不存在、已关闭或服务端即将关闭时重新建立
+// 建立连接时不持有锁, 不阻塞其他地址的调用; ctx 结束时放弃建立或等待
+func (m *connManager) mux(ctx context.Context, addr string) (*muxConn, error) {
+	for {
+		m.mu.Lock()
+		if m.closed {
+			m.mu.Unlock()
+			return nil, ErrShutdown
+		}
+		if conn, ok := m.muxes[addr]; ok && conn.usable() {
+			m.mu.Unlock()
+			return conn, nil
+		}
+
+		if d, ok := m.dialing[addr]; ok {
+			m.mu.Unlock()
+			select {
+			case <-d.done:
+				if d.canceled && ctx.Err() == nil {
+					continue
+				}
+				return d.conn, d.err
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		d := &muxDial{done: make(chan struct{})}
+		m.dialing[addr] = d
+		m.mu.Unlock()
+
+		d.conn, d.err = dialMux(ctx, m.client, "tcp", addr)
+		d.canceled = d.err != nil && ctx.Err() != nil
+
+		m.mu.Lock()
+		delete(m.dialing, addr)
+		if d.err == nil {
+			if m.closed {
+				d.conn.close(ErrShutdown)
+				d.conn, d.err = nil, ErrShutdown
+			} else {
+				m.muxes[addr] = d.conn
+			}
+		}
+		m.mu.Unlock()
+		close(d.done)
+		return d.conn, d.err
+	}
+}
+
+// watch 订阅服务变更, 每个服务只订阅一次
+func (m *connManager) watch(serviceName string) {
+	m.mu.Lock()
+	if m.closed || m.services[serviceName] != nil {
+		m.mu.Unlock()
+		return
+	}
+	m.services[serviceName] = make(map[string]bool)
+	m.mu.Unlock()
+
+	ch, err := m.client.registry.Subscribe(serviceName)
+	if err != nil {
+		// 下次调用时重新订阅
+		m.mu.Lock()
+		delete(m.services, serviceName)
+		m.mu.Unlock()
+		return
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ch:
+				m.update(serviceName)
+			case <-m.done:
+				return
+			}
+		}
+	}()
+}
+
+// update 重新获取服务的实例列表, 关闭不再属于任何已订阅服务的地址上的连接, 并释放已移除实例的熔断器
+// 注册中心在通道已满时会丢弃通知, 因此以通知为信号重新获取最新的实例列表
+func (m *connManager) update(serviceName string) {
+	instances, err := m.client.registry.GetService(serviceName)
+	if err != nil && !errors.Is(err, registry.ErrServiceNotFound) {
+		return
+	}
+	endpoints := make(map[string]bool)
+	ids := make(map[string]bool, len(instances))
+	for _, instance := range instances {
+		ids[instance.ID] = true
+		for _, addr := range instance.Endpoints {
+			endpoints[addr] = true
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return
+	}
+
+	old := m.services[serviceName]
+	m.services[serviceName] = endpoints
+	for addr := range old {
+		if !endpoints[addr] && !m.inUse(addr) {
+			m.evict(addr)
+		}
+	}
+
+	oldIDs := m.ids[serviceName]
+	m.ids[serviceName] = ids
+	if breakers := m.client.breakers; breakers != nil {
+		for id := range oldIDs {
+			if !ids[id] {
+				breakers.Remove(id)
+			}
+		}
+	}
+}
+
+// inUse 地址是否仍属于某个已订阅的服务, 调用方需要持有锁
+func (m *connManager) inUse(addr string) bool {
+	for _, endpoints := range m.services {
+		if endpoints[addr] {
+			return true
+		}
+	}
+	return false
+}
+
+// evict 关闭地址上的连接, 调用方需要持有锁
+// 连接池只关闭空闲连接, 多路复用连接等已发送的请求全部完成后再关闭, 不影响服务端的优雅关闭
+func (m *connManager) evict(addr string) {
+	if pool, ok := m.pools[addr]; ok {
+		delete(m.pools, addr)
+		pool.Close()
+	}
+	if conn, ok := m.muxes[addr]; ok {
+		delete(m.muxes, addr)
+		conn.retire()
+	}
+	m.client.serverCompressors.Delete(addr)
+}
+
+// stats 返回所有连接池的状态
+func (m *connManager) stats() map[string]transport.PoolStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make(map[string]transport.PoolStats, len(m.pools))
+	for addr, pool := range m.pools {
+		stats[addr] = pool.Stats()
+	}
+	return stats
+}
+
+// connStats 返回所有可用的多路复用连接的状态
+func (m *connManager) connStats() map[string]transport.ConnStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make(map[string]transport.ConnStats, len(m.muxes))
+	for addr, conn := range m.muxes {
+		if conn.usable() {
+			stats[addr] = conn.trans.Stats()
+		}
+	}
+	return stats
+}
+
+// close 停止订阅并关闭所有连接
+func (m *connManager) close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	close(m.done)
+	pools, muxes := m.pools, m.muxes
+	m.pools, m.muxes = nil, nil
+	m.mu.Unlock()
+
+	var err error
+	for _, conn := range muxes {
+		conn.close(ErrShutdown)
+	}
+	for _, pool := range pools {
+		if cerr := pool.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -2,12 +2,23 @@ package client
 
 import (
 	"errors"
+
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/status"
 )
 
 var (
 	ErrShutdown = errors.New("connection is shut down")
 )
 
+func init() {
+	status.RegisterError(ErrShutdown, status.Unavailable, "CONNECTION_SHUTDOWN")
+	status.RegisterError(registry.ErrNoAvailableInstances, status.Unavailable, "NO_AVAILABLE_INSTANCES")
+}
+
+// ErrorString 服务端返回的错误信息
+//
+// Deprecated: 服务端错误以 *status.Status 返回, 使用 status.FromError 获取错误码和详情
 type ErrorString string
 
 func (e ErrorString) Error() string {
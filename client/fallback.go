@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"errors"
+
+	"github.com/eason-lee/l-rpc/breaker"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/status"
+)
+
+// FallbackFunc 降级处理函数, 将降级结果写入 call.Reply, 如缓存值、默认值或调用其他服务的结果
+// err 为调用失败的原因; 返回 nil 时调用成功并标记为降级, 返回错误时该错误作为调用结果.
+// 超时触发时 ctx 已经不再有截止时间, 需要发起其他调用时应自行设置超时
+type FallbackFunc func(ctx context.Context, call *Call, err error) error
+
+// WithFallback 设置降级处理函数
+// name 为 "服务.方法" 时只作用于该方法, 为服务名时作用于该服务的所有方法;
+// 服务未注册或没有可用实例、熔断器打开或调用超时时执行
+func WithFallback(name string, fn FallbackFunc) Option {
+	return func(c *Client) {
+		if c.fallbacks == nil {
+			c.fallbacks = make(map[string]FallbackFunc)
+		}
+		c.fallbacks[name] = fn
+	}
+}
+
+// OnDegraded 调用降级时回调, 参数为触发降级的错误
+// 同步调用可以通过该回调得知结果来自降级处理函数
+func OnDegraded(fn func(err error)) CallOption {
+	return func(call *Call) {
+		call.onDegraded = fn
+	}
+}
+
+// invokeWithFallback 调用失败时执行降级处理函数, 是拦截器链的最内层, 拦截器可以通过 call.Degraded 得知结果是否降级
+func (c *Client) invokeWithFallback(ctx context.Context, call *Call) error {
+	err := c.invokeWithRetry(ctx, call)
+	if err == nil {
+		return nil
+	}
+
+	fn := c.lookupFallback(call.ServiceMethod)
+	if fn == nil || !shouldFallback(ctx, err) {
+		return err
+	}
+	if ferr := fn(context.WithoutCancel(ctx), call, err); ferr != nil {
+		return ferr
+	}
+
+	call.Degraded = true
+	c.metrics.Degraded(splitServiceMethod(call.ServiceMethod))
+	if call.onDegraded != nil {
+		call.onDegraded(err)
+	}
+	return nil
+}
+
+// lookupFallback 依次查找方法和服务的降级处理函数
+func (c *Client) lookupFallback(serviceMethod string) FallbackFunc {
+	if fn, ok := c.fallbacks[serviceMethod]; ok {
+		return fn
+	}
+	serviceName, _ := splitServiceMethod(serviceMethod)
+	return c.fallbacks[serviceName]
+}
+
+// shouldFallback 是否需要降级: 服务未注册或没有可用实例、熔断器打开或调用超时
+func shouldFallback(ctx context.Context, err error) bool {
+	if errors.Is(err, registry.ErrServiceNotFound) || errors.Is(err, registry.ErrNoAvailableInstances) ||
+		errors.Is(err, breaker.ErrOpen) {
+		return true
+	}
+	// 连接池模式下超时会关闭连接, 返回的可能是连接错误
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded) || status.CodeOf(err) == status.DeadlineExceeded
+}
@@ -0,0 +1,29 @@
+package client
+
+import "context"
+
+// Invoker 执行一次调用
+type Invoker func(ctx context.Context, call *Call) error
+
+// Interceptor 客户端拦截器
+// call 中包含服务方法、参数、响应和待发送的元数据, 调用 next 继续执行后续拦截器并发送请求,
+// next 返回后可以通过 call.Instance 获取本次选中的服务实例;
+// 不调用 next 直接返回错误即可拒绝本次调用, 多次调用 next 即可实现重试
+type Interceptor func(ctx context.Context, call *Call, next Invoker) error
+
+// Use 添加拦截器, 按添加顺序由外向内执行, 需要在发起调用之前调用
+func (c *Client) Use(interceptors ...Interceptor) {
+	c.interceptors = append(c.interceptors, interceptors...)
+}
+
+// chainInterceptors 将拦截器与最终的调用函数组合成调用链
+func chainInterceptors(interceptors []Interceptor, final Invoker) Invoker {
+	invoker := final
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(ctx context.Context, call *Call) error {
+			return interceptor(ctx, call, next)
+		}
+	}
+	return invoker
+}
@@ -0,0 +1,25 @@
+package client
+
+import (
+	"github.com/eason-lee/l-rpc/metrics"
+	"github.com/eason-lee/l-rpc/transport"
+)
+
+// WithMetrics 设置监控指标, 统计每次发送请求的次数、耗时、消息大小和错误码, 以及降级的调用
+// 指标需要注册到 metrics.Registry 后才会输出
+func WithMetrics(m *metrics.RPCMetrics) Option {
+	return func(c *Client) {
+		c.metrics = m
+	}
+}
+
+// PoolStats 返回连接池状态, 服务端地址 -> 连接池状态, 用于 metrics.NewPoolCollector
+func (c *Client) PoolStats() map[string]transport.PoolStats {
+	return c.conns.stats()
+}
+
+// ConnStats 返回多路复用连接的状态, 服务端地址 -> 连接状态, 用于 metrics.NewConnCollector
+// 心跳的往返时间只在多路复用连接上统计, 连接池中空闲的连接不读取心跳回复
+func (c *Client) ConnStats() map[string]transport.ConnStats {
+	return c.conns.connStats()
+}
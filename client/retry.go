@@ -0,0 +1,250 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/eason-lee/l-rpc/breaker"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/status"
+)
+
+// RetryPolicy 重试策略
+type RetryPolicy struct {
+	// 最大尝试次数, 包含首次调用; 小于等于 1 时不重试
+	MaxAttempts int
+	// 首次重试前的等待时间, 之后每次乘以 Multiplier, 不超过 MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// 等待时间的随机浮动比例, 取值 0~1, 避免大量客户端同时重试
+	Jitter float64
+	// 可重试的状态码, 为空时只重试 Unavailable
+	RetryableCodes []status.Code
+	// 自定义是否可重试, 设置后忽略 RetryableCodes
+	Retryable func(err error) bool
+	// 方法是否幂等; 非幂等方法只在确定请求未被服务端处理时重试
+	Idempotent bool
+}
+
+// DefaultRetryPolicy 默认重试策略, 最多尝试 3 次
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 50 * time.Millisecond,
+	MaxBackoff:     time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// WithRetryPolicy 设置所有方法的重试策略
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retryPolicy = &policy
+	}
+}
+
+// WithMethodRetryPolicy 设置指定服务或方法的重试策略, 优先于 WithRetryPolicy
+// name 为 "服务.方法" 时只作用于该方法, 为服务名时作用于该服务的所有方法
+func WithMethodRetryPolicy(name string, policy RetryPolicy) Option {
+	return func(c *Client) {
+		if c.methodRetryPolicies == nil {
+			c.methodRetryPolicies = make(map[string]*RetryPolicy)
+		}
+		c.methodRetryPolicies[name] = &policy
+	}
+}
+
+// WithRetryBudget 设置全局重试预算, 避免服务端故障时重试放大请求量
+// 每次可重试的失败消耗 1 个令牌, 每次成功归还 ratio 个令牌, 令牌少于 maxTokens 的一半时不再重试;
+// 未设置时使用 maxTokens=10, ratio=0.1
+func WithRetryBudget(maxTokens, ratio float64) Option {
+	return func(c *Client) {
+		c.retryBudget = newRetryBudget(maxTokens, ratio)
+	}
+}
+
+// invokeWithRetry 按重试策略执行调用, 每次重试重新选择实例并排除本次调用中已经失败的实例
+func (c *Client) invokeWithRetry(ctx context.Context, call *Call) error {
+	policy := c.lookupRetryPolicy(call.ServiceMethod)
+	if policy == nil || policy.MaxAttempts <= 1 {
+		return c.invoke(ctx, call)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := c.invoke(ctx, call)
+		if err == nil {
+			c.retryBudget.onSuccess()
+			return nil
+		}
+		// 只有可重试的失败消耗预算, 业务错误等不会重试的失败不影响其他调用的重试
+		if !policy.shouldRetry(err) {
+			return err
+		}
+		c.retryBudget.onFailure()
+		if attempt >= policy.MaxAttempts || !c.retryBudget.allow() {
+			return err
+		}
+		if call.Instance != nil {
+			call.exclude(call.Instance.ID)
+		}
+
+		// 剩余时间不足以等待时直接返回, 不发起注定超时的重试
+		backoff := policy.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= backoff {
+			return err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// lookupRetryPolicy 依次查找方法、服务和全局的重试策略
+func (c *Client) lookupRetryPolicy(serviceMethod string) *RetryPolicy {
+	if policy, ok := c.methodRetryPolicies[serviceMethod]; ok {
+		return policy
+	}
+	serviceName, _ := splitServiceMethod(serviceMethod)
+	if policy, ok := c.methodRetryPolicies[serviceName]; ok {
+		return policy
+	}
+	return c.retryPolicy
+}
+
+// shouldRetry 错误是否可以重试
+func (p *RetryPolicy) shouldRetry(err error) bool {
+	// 调用方主动取消或超时
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	// 非幂等方法的请求可能已经执行过
+	if !p.Idempotent && !unprocessed(err) {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+
+	code := retryCode(err)
+	if len(p.RetryableCodes) == 0 {
+		return code == status.Unavailable
+	}
+	for _, c := range p.RetryableCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff 第 attempt 次失败后的等待时间
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		backoff *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(backoff)
+}
+
+// unprocessedReasons 服务端在执行请求之前拒绝时返回的错误原因
+var unprocessedReasons = map[string]bool{
+	"SERVER_CLOSED":       true,
+	"RATE_LIMITED":        true,
+	"CONCURRENCY_LIMITED": true,
+	"OVERLOADED":          true,
+}
+
+// unprocessed 请求是否确定没有被服务端处理: 连接建立失败、没有可用实例、熔断或服务端拒绝
+func unprocessed(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	if errors.Is(err, registry.ErrNoAvailableInstances) || errors.Is(err, breaker.ErrOpen) {
+		return true
+	}
+	st, ok := status.FromError(err)
+	return ok && unprocessedReasons[st.Reason]
+}
+
+// retryCode 用于判断是否重试的状态码, 网络错误视为 Unavailable
+func retryCode(err error) status.Code {
+	if _, ok := status.FromError(err); ok {
+		return status.CodeOf(err)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return status.Unavailable
+	}
+	return status.CodeOf(err)
+}
+
+// retryBudget 全局重试预算
+type retryBudget struct {
+	mu        sync.Mutex
+	tokens    float64
+	maxTokens float64
+	ratio     float64
+}
+
+func newRetryBudget(maxTokens, ratio float64) *retryBudget {
+	return &retryBudget{
+		tokens:    maxTokens,
+		maxTokens: maxTokens,
+		ratio:     ratio,
+	}
+}
+
+func (b *retryBudget) onSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.tokens+b.ratio, b.maxTokens)
+}
+
+func (b *retryBudget) onFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Max(b.tokens-1, 0)
+}
+
+func (b *retryBudget) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tokens > b.maxTokens/2
+}
+
+// excludeBalancer 过滤掉本次调用中已经失败的实例, 全部被排除时仍从所有实例中选择
+type excludeBalancer struct {
+	registry.LoadBalancer
+	excluded map[string]bool
+}
+
+func (b *excludeBalancer) Select(instances []*registry.ServiceInstance) (*registry.ServiceInstance, error) {
+	var candidates []*registry.ServiceInstance
+	for _, instance := range instances {
+		if !b.excluded[instance.ID] {
+			candidates = append(candidates, instance)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = instances
+	}
+	return b.LoadBalancer.Select(candidates)
+}
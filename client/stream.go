@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eason-lee/l-rpc/codec"
+	"github.com/eason-lee/l-rpc/protocol"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/stream"
+)
+
+// Stream 客户端的流
+// Send 和 Recv 可以在不同协程中同时调用, 但同一方法不能并发调用;
+// 对端来不及读取时 Send 阻塞, 本端最多缓存 stream.DefaultWindow 条未读取的消息
+type Stream struct {
+	core  *stream.Stream
+	conn  *muxConn
+	codec codec.Codec
+
+	once sync.Once
+	done chan struct{} // 流结束时关闭
+}
+
+// NewStream 建立流式调用
+// 流总是使用多路复用连接, 不经过 Interceptor; ctx 结束时通知服务端取消该流,
+// ctx 的截止时间和元数据与 Call 一样通过请求头传递给服务端
+func (c *Client) NewStream(ctx context.Context, serviceMethod string, opts ...CallOption) (*Stream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	call := c.newCall(ctx, serviceMethod, opts)
+	open := &protocol.Message{Header: newHeader(ctx, call, protocol.TypeStreamOpen)}
+
+	// 与普通调用一样经过熔断过滤, 以流是否建立成功作为熔断器的调用结果
+	instance, err := c.selectInstance(call, open.Header.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	b, err := c.allowInstance(instance)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	s, err := c.openStream(ctx, instance, call, open)
+	if b != nil {
+		b.Done(err, time.Since(start))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	go s.watch(ctx)
+	return s, nil
+}
+
+// openStream 在选中实例的多路复用连接上注册流并发送建立流的消息
+func (c *Client) openStream(ctx context.Context, instance *registry.ServiceInstance, call *Call, open *protocol.Message) (*Stream, error) {
+	conn, err := c.conns.mux(ctx, instance.Endpoints[0])
+	if err != nil {
+		return nil, err
+	}
+
+	id := atomic.AddUint64(&c.seq, 1)
+	s := &Stream{
+		core:  stream.New(ctx, id, conn.writeMessage),
+		conn:  conn,
+		codec: call.codec,
+		done:  make(chan struct{}),
+	}
+	if err := conn.addStream(s); err != nil {
+		return nil, err
+	}
+
+	open.Header.StreamID = id
+	if err := conn.writeMessage(open); err != nil {
+		conn.removeStream(id)
+		s.finish()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Context 返回流的上下文
+func (s *Stream) Context() context.Context {
+	return s.core.Context()
+}
+
+// Send 发送一条消息, 服务端结束流后返回 io.EOF, 调用结果通过 Recv 获取
+func (s *Stream) Send(msg interface{}) error {
+	data, err := encode(s.codec, msg)
+	if err != nil {
+		return err
+	}
+	return s.core.Send(data)
+}
+
+// Recv 读取一条消息
+// 服务端方法正常返回且消息读完后返回 io.EOF, 方法返回错误时返回对应的状态错误
+func (s *Stream) Recv(msg interface{}) error {
+	data, err := s.core.Recv()
+	if err != nil {
+		return err
+	}
+	return decode(s.codec, data, msg)
+}
+
+// CloseSend 通知服务端不再发送消息, 之后仍可以继续 Recv
+func (s *Stream) CloseSend() error {
+	return s.core.CloseSend(nil)
+}
+
+// watch 上下文结束时放弃流并通知服务端取消
+func (s *Stream) watch(ctx context.Context) {
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		s.conn.cancelStream(s.core.ID(), ctx.Err())
+	}
+}
+
+// finish 流结束, 由读协程或 watch 在移除流之后调用
+func (s *Stream) finish() {
+	s.once.Do(func() {
+		close(s.done)
+	})
+}
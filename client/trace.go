@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+
+	"github.com/eason-lee/l-rpc/protocol"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/status"
+	"github.com/eason-lee/l-rpc/trace"
+)
+
+// WithTracer 设置链路追踪, 每次发送请求创建一个客户端调用, 并通过请求头的 traceparent/tracestate 传递给服务端
+// 重试时每次发送都是独立的调用
+func WithTracer(tracer *trace.Tracer) Option {
+	return func(c *Client) {
+		c.tracer = tracer
+	}
+}
+
+// startSpan 创建客户端调用并将调用上下文注入请求头, 未配置链路追踪时返回 nil
+func (c *Client) startSpan(ctx context.Context, instance *registry.ServiceInstance, req *protocol.Message) *trace.Span {
+	if c.tracer == nil {
+		return nil
+	}
+
+	header := req.Header
+	_, span := c.tracer.Start(ctx, header.ServiceName+"/"+header.MethodName, trace.SpanKindClient)
+	span.SetAttribute(trace.AttrService, header.ServiceName)
+	span.SetAttribute(trace.AttrMethod, header.MethodName)
+	span.SetAttribute(trace.AttrInstance, instance.ID)
+	span.SetAttribute(trace.AttrPeer, instance.Endpoints[0])
+
+	trace.Inject(span.SpanContext(), copyMetadata(header, 2))
+	return span
+}
+
+// endSpan 记录调用结果并结束调用
+func endSpan(span *trace.Span, err error) {
+	if span == nil {
+		return
+	}
+	span.SetAttribute(trace.AttrStatusCode, status.CodeOf(err).String())
+	span.RecordError(err)
+	span.End()
+}
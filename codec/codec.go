@@ -1,6 +1,10 @@
 package codec
 
-import "io"
+import (
+	"fmt"
+	"io"
+	"sync"
+)
 
 // Codec 定义序列化接口
 type Codec interface {
@@ -17,16 +21,43 @@ type Codec interface {
 // DefaultCodec 默认的序列化方式
 var DefaultCodec = NewJSONCodec()
 
-// GetCodec 根据内容类型获取对应的序列化器
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{
+		"application/json":       NewJSONCodec(),
+		"application/x-protobuf": NewProtobufCodec(),
+		"application/x-msgpack":  NewMsgpackCodec(),
+	}
+)
+
+// RegisterCodec 注册序列化器, 相同内容类型的序列化器会被覆盖
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[c.ContentType()] = c
+}
+
+// LookupCodec 根据内容类型查找序列化器
+// 内容类型为空时返回默认序列化器, 未注册的内容类型返回 ErrUnsupportedCodec
+func LookupCodec(contentType string) (Codec, error) {
+	if contentType == "" {
+		return DefaultCodec, nil
+	}
+
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[contentType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedCodec, contentType)
+	}
+	return c, nil
+}
+
+// GetCodec 根据内容类型获取对应的序列化器, 未注册的内容类型返回默认序列化器
 func GetCodec(contentType string) Codec {
-	switch contentType {
-	case "application/json":
-		return NewJSONCodec()
-	case "application/x-protobuf":
-		return NewProtobufCodec()
-	case "application/x-msgpack":
-		return NewMsgpackCodec()
-	default:
+	c, err := LookupCodec(contentType)
+	if err != nil {
 		return DefaultCodec
 	}
-}
\ No newline at end of file
+	return c
+}
@@ -80,6 +80,31 @@ func (s *CodecTestSuite) TestGetCodec() {
 	}
 }
 
+type customCodec struct {
+	JSONCodec
+}
+
+func (c *customCodec) ContentType() string {
+	return "application/x-custom"
+}
+
+func (s *CodecTestSuite) TestLookupCodec() {
+	// 未注册的内容类型
+	_, err := LookupCodec("application/x-custom")
+	s.ErrorIs(err, ErrUnsupportedCodec)
+
+	// 空内容类型使用默认序列化方式
+	codec, err := LookupCodec("")
+	s.NoError(err)
+	s.Equal(DefaultCodec.ContentType(), codec.ContentType())
+
+	// 注册后可以查找到
+	RegisterCodec(&customCodec{})
+	codec, err = LookupCodec("application/x-custom")
+	s.NoError(err)
+	s.Equal("application/x-custom", codec.ContentType())
+}
+
 func TestCodecSuite(t *testing.T) {
 	suite.Run(t, new(CodecTestSuite))
 }
\ No newline at end of file
@@ -27,23 +27,17 @@ func main() {
 	// 创建注册中心
 	reg := registry.NewInMemoryRegistry()
 
-	// 创建 RPC 服务器
-	srv := server.NewServer()
+	// 创建 RPC 服务器, 启动后自动将服务注册到注册中心
+	srv := server.NewServer(
+		server.WithRegistry(reg),
+		server.WithVersion("1.0.0"),
+	)
 
 	// 注册服务
 	if err := srv.Register(new(UserService)); err != nil {
 		log.Fatalf("注册服务失败: %v", err)
 	}
 
-	// 注册服务实例
-	instance := &registry.ServiceInstance{
-		Name:      "UserService",
-		Endpoints: []string{"127.0.0.1:8080"},
-	}
-	if err := reg.Register(instance); err != nil {
-		log.Fatalf("注册实例失败: %v", err)
-	}
-
 	// 启动服务
 	log.Println("启动 RPC 服务器...")
 	if err := srv.Start(":8080"); err != nil {
@@ -4,6 +4,7 @@ go 1.23.3
 
 require (
 	github.com/hashicorp/consul/api v1.31.2
+	github.com/klauspost/compress v1.18.0
 	github.com/stretchr/testify v1.10.0
 	github.com/vmihailenco/msgpack/v5 v5.4.1
 	google.golang.org/protobuf v1.36.5
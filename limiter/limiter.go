@@ -0,0 +1,184 @@
+package limiter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// TokenBucket 令牌桶限流器
+// 令牌按 rate 每秒匀速生成, 最多积累 burst 个, 每次请求消耗一个令牌
+type TokenBucket struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket 创建令牌桶, 初始时桶是满的; burst 小于 1 时取 1
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		now:    time.Now,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Allow 是否有可用的令牌, 有则消耗一个
+func (b *TokenBucket) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Return 归还一个 Allow 消耗的令牌, 用于请求随后被其他限流器拒绝的情况
+func (b *TokenBucket) Return() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
+
+// Concurrency 并发数限制
+type Concurrency struct {
+	max int
+
+	mu       sync.Mutex
+	inflight int
+}
+
+// NewConcurrency 创建并发数限制, 最多同时执行 max 个请求
+func NewConcurrency(max int) *Concurrency {
+	return &Concurrency{max: max}
+}
+
+// Acquire 占用一个名额, 名额用完时返回 false
+func (c *Concurrency) Acquire() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.inflight >= c.max {
+		return false
+	}
+	c.inflight++
+	return true
+}
+
+// Release 释放 Acquire 占用的名额
+func (c *Concurrency) Release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inflight--
+}
+
+// Inflight 返回正在执行的请求数
+func (c *Concurrency) Inflight() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.inflight
+}
+
+// AdaptiveConfig 自适应限流配置, 未设置的字段使用默认值
+type AdaptiveConfig struct {
+	// 期望的请求延迟, 平均延迟超过该值时降低并发上限
+	TargetLatency time.Duration
+	// 并发上限的取值范围和初始值, 默认 1、1000、100
+	MinLimit     int
+	MaxLimit     int
+	InitialLimit int
+	// 平均延迟的平滑系数, 取值 0~1, 越大越偏向最近的请求, 默认 0.2
+	Smoothing float64
+	// 超过期望延迟时并发上限的缩小比例, 默认 0.9
+	Backoff float64
+}
+
+// Adaptive 根据观测到的延迟调整并发上限
+// 平均延迟不超过期望值时每个请求将上限增加 1/limit(约每轮增加 1), 超过时按 Backoff 缩小,
+// 过载时排队的请求被直接拒绝, 而不是继续拉高延迟
+type Adaptive struct {
+	config AdaptiveConfig
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	latency  float64 // 平均延迟, 纳秒
+}
+
+// NewAdaptive 创建自适应限流器
+func NewAdaptive(config AdaptiveConfig) *Adaptive {
+	if config.MinLimit <= 0 {
+		config.MinLimit = 1
+	}
+	if config.MaxLimit <= 0 {
+		config.MaxLimit = 1000
+	}
+	if config.InitialLimit <= 0 {
+		config.InitialLimit = 100
+	}
+	if config.Smoothing <= 0 || config.Smoothing > 1 {
+		config.Smoothing = 0.2
+	}
+	if config.Backoff <= 0 || config.Backoff >= 1 {
+		config.Backoff = 0.9
+	}
+	return &Adaptive{
+		config: config,
+		limit:  math.Max(float64(config.MinLimit), math.Min(float64(config.InitialLimit), float64(config.MaxLimit))),
+	}
+}
+
+// Acquire 占用一个名额, 并发数达到当前上限时返回 false
+func (a *Adaptive) Acquire() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if float64(a.inflight) >= math.Floor(a.limit) {
+		return false
+	}
+	a.inflight++
+	return true
+}
+
+// Release 释放名额并记录本次请求的延迟
+func (a *Adaptive) Release(latency time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.inflight--
+	if a.latency == 0 {
+		a.latency = float64(latency)
+	} else {
+		a.latency += a.config.Smoothing * (float64(latency) - a.latency)
+	}
+
+	if a.config.TargetLatency > 0 && a.latency > float64(a.config.TargetLatency) {
+		a.limit = math.Max(float64(a.config.MinLimit), a.limit*a.config.Backoff)
+	} else {
+		a.limit = math.Min(float64(a.config.MaxLimit), a.limit+1/a.limit)
+	}
+}
+
+// Limit 返回当前的并发上限
+func (a *Adaptive) Limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return int(a.limit)
+}
@@ -0,0 +1,92 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type LimiterTestSuite struct {
+	suite.Suite
+}
+
+func (s *LimiterTestSuite) TestTokenBucket() {
+	now := time.Now()
+	b := NewTokenBucket(10, 2)
+	b.now = func() time.Time { return now }
+	b.last = now
+
+	// 初始可以突发 burst 个请求
+	s.True(b.Allow())
+	s.True(b.Allow())
+	s.False(b.Allow())
+
+	// 每 100ms 生成一个令牌
+	now = now.Add(100 * time.Millisecond)
+	s.True(b.Allow())
+	s.False(b.Allow())
+
+	// 令牌不超过 burst
+	now = now.Add(time.Hour)
+	s.True(b.Allow())
+	s.True(b.Allow())
+	s.False(b.Allow())
+
+	// 归还的令牌可以再次使用, 但不超过 burst
+	b.Return()
+	s.True(b.Allow())
+	b.Return()
+	b.Return()
+	b.Return()
+	s.True(b.Allow())
+	s.True(b.Allow())
+	s.False(b.Allow())
+}
+
+func (s *LimiterTestSuite) TestConcurrency() {
+	c := NewConcurrency(2)
+	s.True(c.Acquire())
+	s.True(c.Acquire())
+	s.False(c.Acquire())
+
+	s.Equal(2, c.Inflight())
+
+	c.Release()
+	s.Equal(1, c.Inflight())
+	s.True(c.Acquire())
+}
+
+func (s *LimiterTestSuite) TestAdaptive() {
+	a := NewAdaptive(AdaptiveConfig{
+		TargetLatency: 10 * time.Millisecond,
+		MinLimit:      2,
+		MaxLimit:      20,
+		InitialLimit:  10,
+		Smoothing:     1,
+	})
+	s.Equal(10, a.Limit())
+
+	// 延迟低于期望值时逐步放大上限
+	for i := 0; i < 50; i++ {
+		s.True(a.Acquire())
+		a.Release(time.Millisecond)
+	}
+	s.Greater(a.Limit(), 10)
+
+	// 延迟超过期望值时缩小上限, 不低于 MinLimit
+	for i := 0; i < 50; i++ {
+		s.True(a.Acquire())
+		a.Release(100 * time.Millisecond)
+	}
+	s.Equal(2, a.Limit())
+
+	// 达到上限后拒绝
+	s.True(a.Acquire())
+	s.True(a.Acquire())
+	s.False(a.Acquire())
+}
+
+func TestLimiterSuite(t *testing.T) {
+	suite.Run(t, new(LimiterTestSuite))
+}
@@ -0,0 +1,92 @@
+package metadata
+
+import "context"
+
+// MD 请求元数据, 随 protocol.Header.Metadata 在客户端和服务端之间传递
+type MD map[string]string
+
+// New 根据 map 创建元数据
+func New(m map[string]string) MD {
+	md := make(MD, len(m))
+	for k, v := range m {
+		md[k] = v
+	}
+	return md
+}
+
+// Pairs 根据键值对创建元数据, 参数个数为奇数时最后一个键的值为空
+func Pairs(kv ...string) MD {
+	md := make(MD, len(kv)/2)
+	for i := 0; i < len(kv); i += 2 {
+		if i+1 < len(kv) {
+			md[kv[i]] = kv[i+1]
+		} else {
+			md[kv[i]] = ""
+		}
+	}
+	return md
+}
+
+// Get 获取指定键的值
+func (md MD) Get(key string) string {
+	return md[key]
+}
+
+// Set 设置指定键的值
+func (md MD) Set(key, value string) {
+	md[key] = value
+}
+
+// Copy 复制元数据
+func (md MD) Copy() MD {
+	return New(md)
+}
+
+// Join 合并多个元数据, 相同的键以后面的值为准
+func Join(mds ...MD) MD {
+	out := MD{}
+	for _, md := range mds {
+		for k, v := range md {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+type outgoingKey struct{}
+
+type incomingKey struct{}
+
+// NewOutgoingContext 创建携带待发送元数据的上下文, 客户端调用时会将其写入请求头
+func NewOutgoingContext(ctx context.Context, md MD) context.Context {
+	return context.WithValue(ctx, outgoingKey{}, md)
+}
+
+// AppendToOutgoingContext 向上下文中追加待发送的元数据
+func AppendToOutgoingContext(ctx context.Context, kv ...string) context.Context {
+	md, _ := FromOutgoingContext(ctx)
+	return NewOutgoingContext(ctx, Join(md, Pairs(kv...)))
+}
+
+// FromOutgoingContext 获取上下文中待发送的元数据
+func FromOutgoingContext(ctx context.Context) (MD, bool) {
+	md, ok := ctx.Value(outgoingKey{}).(MD)
+	if !ok {
+		return nil, false
+	}
+	return md.Copy(), true
+}
+
+// NewIncomingContext 创建携带接收到的元数据的上下文, 服务端会将请求头中的元数据注入处理函数的上下文
+func NewIncomingContext(ctx context.Context, md MD) context.Context {
+	return context.WithValue(ctx, incomingKey{}, md)
+}
+
+// FromIncomingContext 获取上下文中接收到的元数据
+func FromIncomingContext(ctx context.Context) (MD, bool) {
+	md, ok := ctx.Value(incomingKey{}).(MD)
+	if !ok {
+		return nil, false
+	}
+	return md.Copy(), true
+}
@@ -0,0 +1,61 @@
+package metadata
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type MetadataTestSuite struct {
+	suite.Suite
+}
+
+func (s *MetadataTestSuite) TestPairs() {
+	md := Pairs("tenant", "t1", "trace_id")
+	s.Equal("t1", md.Get("tenant"))
+	s.Equal("", md.Get("trace_id"))
+	s.Len(md, 2)
+}
+
+func (s *MetadataTestSuite) TestJoin() {
+	md := Join(Pairs("a", "1", "b", "2"), Pairs("b", "3"))
+	s.Equal(MD{"a": "1", "b": "3"}, md)
+}
+
+func (s *MetadataTestSuite) TestOutgoingContext() {
+	ctx := context.Background()
+	_, ok := FromOutgoingContext(ctx)
+	s.False(ok)
+
+	ctx = NewOutgoingContext(ctx, Pairs("tenant", "t1"))
+	ctx = AppendToOutgoingContext(ctx, "token", "secret")
+
+	md, ok := FromOutgoingContext(ctx)
+	s.True(ok)
+	s.Equal(MD{"tenant": "t1", "token": "secret"}, md)
+
+	// 修改返回值不影响上下文中的元数据
+	md.Set("tenant", "t2")
+	md, _ = FromOutgoingContext(ctx)
+	s.Equal("t1", md.Get("tenant"))
+
+	// 出站元数据与入站元数据互不影响
+	_, ok = FromIncomingContext(ctx)
+	s.False(ok)
+}
+
+func (s *MetadataTestSuite) TestIncomingContext() {
+	ctx := NewIncomingContext(context.Background(), Pairs("trace_id", "123"))
+
+	md, ok := FromIncomingContext(ctx)
+	s.True(ok)
+	s.Equal("123", md.Get("trace_id"))
+
+	_, ok = FromOutgoingContext(ctx)
+	s.False(ok)
+}
+
+func TestMetadataSuite(t *testing.T) {
+	suite.Run(t, new(MetadataTestSuite))
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/transport"
+)
+
+// NewPoolCollector 输出连接池状态, stats 在抓取时调用, 返回服务端地址 -> 连接池状态
+func NewPoolCollector(stats func() map[string]transport.PoolStats) Collector {
+	return CollectorFunc(func() []Family {
+		active := NewGaugeVec("lrpc_pool_active_connections", "Number of connections in use.", "endpoint")
+		idle := NewGaugeVec("lrpc_pool_idle_connections", "Number of idle connections in the pool.", "endpoint")
+		maxIdle := NewGaugeVec("lrpc_pool_max_idle_connections", "Maximum number of idle connections in the pool.", "endpoint")
+		maxActive := NewGaugeVec("lrpc_pool_max_active_connections", "Maximum number of active connections in the pool.", "endpoint")
+		waits := NewCounterVec("lrpc_pool_waits_total", "Total number of times a caller waited for a connection.", "endpoint")
+		waitSeconds := NewCounterVec("lrpc_pool_wait_seconds_total", "Total time spent waiting for a connection.", "endpoint")
+		dials := NewCounterVec("lrpc_pool_dials_total", "Total number of connections dialed.", "endpoint")
+		dialErrors := NewCounterVec("lrpc_pool_dial_errors_total", "Total number of failed dials.", "endpoint")
+		for endpoint, s := range stats() {
+			active.Set(float64(s.Active), endpoint)
+			idle.Set(float64(s.Idle), endpoint)
+			maxIdle.Set(float64(s.MaxIdle), endpoint)
+			maxActive.Set(float64(s.MaxActive), endpoint)
+			waits.Add(float64(s.WaitCount), endpoint)
+			waitSeconds.Add(s.WaitDuration.Seconds(), endpoint)
+			dials.Add(float64(s.Dials), endpoint)
+			dialErrors.Add(float64(s.DialErrors), endpoint)
+		}
+		return collectAll(active, idle, maxIdle, maxActive, waits, waitSeconds, dials, dialErrors)
+	})
+}
+
+// NewConnCollector 输出多路复用连接的状态, stats 在抓取时调用, 返回服务端地址 -> 连接状态
+func NewConnCollector(stats func() map[string]transport.ConnStats) Collector {
+	return CollectorFunc(func() []Family {
+		rtt := NewGaugeVec("lrpc_conn_rtt_seconds", "Round-trip time of the last heartbeat on the connection.", "endpoint")
+		for endpoint, s := range stats() {
+			rtt.Set(s.RTT.Seconds(), endpoint)
+		}
+		return rtt.Collect()
+	})
+}
+
+// NewRegistryCollector 输出注册中心中各服务的实例数和实例的健康检查状态
+func NewRegistryCollector(reg registry.Registry) Collector {
+	return CollectorFunc(func() []Family {
+		instances, err := reg.ListServices()
+		if err != nil {
+			return nil
+		}
+
+		count := NewGaugeVec("lrpc_registry_instances", "Number of registered service instances by status.", "service", "status")
+		up := NewGaugeVec("lrpc_registry_instance_up", "Whether the service instance is up (1) or down (0).", "service", "instance")
+		age := NewGaugeVec("lrpc_registry_instance_heartbeat_age_seconds", "Seconds since the last heartbeat of the service instance.", "service", "instance")
+
+		for _, inst := range instances {
+			count.Add(1, inst.Name, statusName(inst.Status))
+			if inst.Status == registry.StatusUp {
+				up.Set(1, inst.Name, inst.ID)
+			} else {
+				up.Set(0, inst.Name, inst.ID)
+			}
+			if !inst.LastHeartbeat.IsZero() {
+				age.Set(time.Since(inst.LastHeartbeat).Seconds(), inst.Name, inst.ID)
+			}
+		}
+		return collectAll(count, up, age)
+	})
+}
+
+func statusName(s registry.ServiceStatus) string {
+	if s == registry.StatusUp {
+		return "up"
+	}
+	return "down"
+}
+
+func collectAll(collectors ...Collector) []Family {
+	var families []Family
+	for _, c := range collectors {
+		families = append(families, c.Collect()...)
+	}
+	return families
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+)
+
+// Type 指标类型
+type Type string
+
+const (
+	CounterType   Type = "counter"
+	GaugeType     Type = "gauge"
+	HistogramType Type = "histogram"
+)
+
+// Label 标签
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Bucket 直方图的桶, Count 为小于等于 UpperBound 的累计观测数
+type Bucket struct {
+	UpperBound float64
+	Count      uint64
+}
+
+// Sample 一组标签对应的指标值
+type Sample struct {
+	Labels []Label
+	// 计数器和仪表盘的值
+	Value float64
+	// 直方图的桶、观测总数和观测值之和
+	Buckets []Bucket
+	Count   uint64
+	Sum     float64
+}
+
+// Family 同名指标
+type Family struct {
+	Name    string
+	Help    string
+	Type    Type
+	Samples []Sample
+}
+
+// Collector 在抓取时输出指标
+type Collector interface {
+	Collect() []Family
+}
+
+// CollectorFunc 函数形式的 Collector, 用于抓取时才读取的状态, 如连接池和注册中心
+type CollectorFunc func() []Family
+
+func (f CollectorFunc) Collect() []Family {
+	return f()
+}
+
+// Registry 汇总多个 Collector 的指标
+type Registry struct {
+	mu         sync.RWMutex
+	collectors []Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register 注册 Collector
+func (r *Registry) Register(collectors ...Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, collectors...)
+}
+
+// Gather 收集所有指标, 按名称排序, 同名指标合并, 没有值的指标被忽略
+func (r *Registry) Gather() []Family {
+	r.mu.RLock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.RUnlock()
+
+	byName := make(map[string]*Family)
+	for _, c := range collectors {
+		for _, f := range c.Collect() {
+			if len(f.Samples) == 0 {
+				continue
+			}
+			if existing, ok := byName[f.Name]; ok {
+				existing.Samples = append(existing.Samples, f.Samples...)
+				continue
+			}
+			f := f
+			byName[f.Name] = &f
+		}
+	}
+
+	families := make([]Family, 0, len(byName))
+	for _, f := range byName {
+		families = append(families, *f)
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].Name < families[j].Name
+	})
+	return families
+}
@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/status"
+	"github.com/eason-lee/l-rpc/transport"
+	"github.com/stretchr/testify/suite"
+)
+
+type MetricsTestSuite struct {
+	suite.Suite
+}
+
+func (s *MetricsTestSuite) text(r *Registry) string {
+	var b strings.Builder
+	s.Require().NoError(WriteText(&b, r.Gather()))
+	return b.String()
+}
+
+func (s *MetricsTestSuite) TestCounterAndGauge() {
+	counter := NewCounterVec("requests_total", "Total requests.", "service", "method")
+	counter.Inc("Echo", "Upper")
+	counter.Add(2, "Echo", "Upper")
+	counter.Add(-1, "Echo", "Upper")
+	counter.Inc("Arith", "Add")
+
+	gauge := NewGaugeVec("in_flight", "In flight\nrequests.", "path")
+	gauge.Set(3, `a"b\c`)
+	gauge.Add(-1, `a"b\c`)
+
+	r := NewRegistry()
+	r.Register(counter, gauge)
+	s.Equal(`# HELP in_flight In flight\nrequests.
+# TYPE in_flight gauge
+in_flight{path="a\"b\\c"} 2
+# HELP requests_total Total requests.
+# TYPE requests_total counter
+requests_total{service="Arith",method="Add"} 1
+requests_total{service="Echo",method="Upper"} 3
+`, s.text(r))
+}
+
+func (s *MetricsTestSuite) TestHistogram() {
+	h := NewHistogramVec("latency_seconds", "", []float64{0.1, 1}, "method")
+	h.Observe(0.05, "Echo")
+	h.Observe(0.1, "Echo")
+	h.Observe(0.5, "Echo")
+	h.Observe(5, "Echo")
+
+	r := NewRegistry()
+	r.Register(h)
+	s.Equal(`# TYPE latency_seconds histogram
+latency_seconds_bucket{method="Echo",le="0.1"} 2
+latency_seconds_bucket{method="Echo",le="1"} 3
+latency_seconds_bucket{method="Echo",le="+Inf"} 4
+latency_seconds_sum{method="Echo"} 5.65
+latency_seconds_count{method="Echo"} 4
+`, s.text(r))
+}
+
+func (s *MetricsTestSuite) TestGather() {
+	a := NewGaugeVec("pool_idle", "", "endpoint")
+	a.Set(1, "a")
+	b := NewGaugeVec("pool_idle", "", "endpoint")
+	b.Set(2, "b")
+	empty := NewCounterVec("empty_total", "")
+
+	r := NewRegistry()
+	r.Register(a, b, empty)
+	families := r.Gather()
+	s.Require().Len(families, 1)
+	s.Len(families[0].Samples, 2)
+}
+
+func (s *MetricsTestSuite) TestHandler() {
+	c := NewCounterVec("requests_total", "")
+	c.Inc()
+	r := NewRegistry()
+	r.Register(c)
+
+	rec := httptest.NewRecorder()
+	Handler(r).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	s.Equal(ContentType, rec.Header().Get("Content-Type"))
+	s.Equal("# TYPE requests_total counter\nrequests_total 1\n", rec.Body.String())
+}
+
+func (s *MetricsTestSuite) TestRPCMetrics() {
+	m := NewServerMetrics()
+	m.Begin("EchoService", "Echo")(nil, 10, 20)
+	m.Begin("EchoService", "Echo")(status.Error(status.InvalidArgument, "bad"), 10, 0)
+	m.Begin("EchoService", "Echo")(errors.New("plain"), 10, 0)
+	end := m.Begin("EchoService", "Delay")
+
+	r := NewRegistry()
+	r.Register(m)
+	text := s.text(r)
+	s.Contains(text, `lrpc_server_requests_total{service="EchoService",method="Echo"} 3`)
+	s.Contains(text, `lrpc_server_errors_total{service="EchoService",method="Echo",code="InvalidArgument"} 1`)
+	s.Contains(text, `lrpc_server_errors_total{service="EchoService",method="Echo",code="Unknown"} 1`)
+	s.Contains(text, `lrpc_server_requests_in_flight{service="EchoService",method="Delay"} 1`)
+	s.Contains(text, `lrpc_server_request_size_bytes_count{service="EchoService",method="Echo"} 3`)
+	s.Contains(text, `lrpc_server_response_size_bytes_sum{service="EchoService",method="Echo"} 20`)
+	s.NotContains(text, "lrpc_server_degraded_total")
+
+	end(nil, 0, 0)
+	s.Contains(s.text(r), `lrpc_server_requests_in_flight{service="EchoService",method="Delay"} 0`)
+
+	// 未配置监控指标
+	var nilMetrics *RPCMetrics
+	s.NotPanics(func() {
+		nilMetrics.Begin("EchoService", "Echo")(nil, 0, 0)
+		nilMetrics.Degraded("EchoService", "Echo")
+	})
+}
+
+func (s *MetricsTestSuite) TestCollectors() {
+	reg := registry.NewInMemoryRegistry()
+	s.Require().NoError(reg.Register(&registry.ServiceInstance{ID: "echo-1", Name: "EchoService"}))
+	s.Require().NoError(reg.Register(&registry.ServiceInstance{ID: "echo-2", Name: "EchoService", Status: registry.StatusDown}))
+
+	r := NewRegistry()
+	r.Register(NewRegistryCollector(reg), NewPoolCollector(func() map[string]transport.PoolStats {
+		return map[string]transport.PoolStats{"127.0.0.1:8080": {Active: 3, Idle: 2, MaxIdle: 5, MaxActive: 20, WaitCount: 4, Dials: 6, DialErrors: 1}}
+	}))
+	text := s.text(r)
+	s.Contains(text, `lrpc_registry_instances{service="EchoService",status="up"} 1`)
+	s.Contains(text, `lrpc_registry_instances{service="EchoService",status="down"} 1`)
+	s.Contains(text, `lrpc_registry_instance_up{service="EchoService",instance="echo-1"} 1`)
+	s.Contains(text, `lrpc_registry_instance_up{service="EchoService",instance="echo-2"} 0`)
+	s.Contains(text, `lrpc_registry_instance_heartbeat_age_seconds{service="EchoService",instance="echo-1"}`)
+	s.Contains(text, `lrpc_pool_idle_connections{endpoint="127.0.0.1:8080"} 2`)
+	s.Contains(text, `lrpc_pool_max_active_connections{endpoint="127.0.0.1:8080"} 20`)
+	s.Contains(text, `lrpc_pool_active_connections{endpoint="127.0.0.1:8080"} 3`)
+	s.Contains(text, `lrpc_pool_waits_total{endpoint="127.0.0.1:8080"} 4`)
+	s.Contains(text, `lrpc_pool_dials_total{endpoint="127.0.0.1:8080"} 6`)
+	s.Contains(text, `lrpc_pool_dial_errors_total{endpoint="127.0.0.1:8080"} 1`)
+
+	r = NewRegistry()
+	r.Register(NewConnCollector(func() map[string]transport.ConnStats {
+		return map[string]transport.ConnStats{"127.0.0.1:8080": {RTT: 1500 * time.Microsecond}}
+	}))
+	s.Contains(s.text(r), `lrpc_conn_rtt_seconds{endpoint="127.0.0.1:8080"} 0.0015`)
+}
+
+func TestMetricsSuite(t *testing.T) {
+	suite.Run(t, new(MetricsTestSuite))
+}
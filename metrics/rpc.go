@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/eason-lee/l-rpc/status"
+)
+
+// RPCMetrics 按服务和方法统计的调用指标
+// 方法可以在 nil 上调用, 未配置监控指标时调用方无需判断
+type RPCMetrics struct {
+	requests     *CounterVec
+	errors       *CounterVec
+	degraded     *CounterVec
+	inflight     *GaugeVec
+	latency      *HistogramVec
+	requestSize  *HistogramVec
+	responseSize *HistogramVec
+}
+
+// NewClientMetrics 创建客户端调用指标, 指标名以 lrpc_client_ 开头, 每次发送请求统计一次
+func NewClientMetrics() *RPCMetrics {
+	return newRPCMetrics("lrpc_client")
+}
+
+// NewServerMetrics 创建服务端调用指标, 指标名以 lrpc_server_ 开头
+func NewServerMetrics() *RPCMetrics {
+	return newRPCMetrics("lrpc_server")
+}
+
+func newRPCMetrics(prefix string) *RPCMetrics {
+	return &RPCMetrics{
+		requests:     NewCounterVec(prefix+"_requests_total", "Total number of RPC requests.", "service", "method"),
+		errors:       NewCounterVec(prefix+"_errors_total", "Total number of failed RPC requests by status code.", "service", "method", "code"),
+		degraded:     NewCounterVec(prefix+"_degraded_total", "Total number of RPC calls answered by a fallback handler.", "service", "method"),
+		inflight:     NewGaugeVec(prefix+"_requests_in_flight", "Number of RPC requests currently in flight.", "service", "method"),
+		latency:      NewHistogramVec(prefix+"_request_duration_seconds", "RPC request latency in seconds.", DefBuckets, "service", "method"),
+		requestSize:  NewHistogramVec(prefix+"_request_size_bytes", "RPC request payload size in bytes.", SizeBuckets, "service", "method"),
+		responseSize: NewHistogramVec(prefix+"_response_size_bytes", "RPC response payload size in bytes.", SizeBuckets, "service", "method"),
+	}
+}
+
+// Begin 开始统计一次请求, 请求结束时调用返回的函数
+// err 为 nil 时视为成功, 否则按错误码计入错误数; 大小为参数和响应编码后的字节数
+func (m *RPCMetrics) Begin(service, method string) func(err error, requestSize, responseSize int) {
+	if m == nil {
+		return func(error, int, int) {}
+	}
+
+	start := time.Now()
+	m.inflight.Add(1, service, method)
+	return func(err error, requestSize, responseSize int) {
+		m.inflight.Add(-1, service, method)
+		m.requests.Inc(service, method)
+		m.latency.Observe(time.Since(start).Seconds(), service, method)
+		m.requestSize.Observe(float64(requestSize), service, method)
+		if err != nil {
+			m.errors.Inc(service, method, status.CodeOf(err).String())
+			return
+		}
+		m.responseSize.Observe(float64(responseSize), service, method)
+	}
+}
+
+// Degraded 记录一次由降级处理函数产生结果的调用
+func (m *RPCMetrics) Degraded(service, method string) {
+	if m == nil {
+		return
+	}
+	m.degraded.Inc(service, method)
+}
+
+func (m *RPCMetrics) Collect() []Family {
+	return collectAll(m.requests, m.errors, m.degraded, m.inflight, m.latency, m.requestSize, m.responseSize)
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ContentType Prometheus 文本格式的 Content-Type
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Handler 返回以 Prometheus 文本格式输出指标的 HTTP 处理函数, 通常挂载在 /metrics
+// 指标先完整输出到缓冲区, 输出失败时返回 500, 不会发送不完整的指标
+func Handler(r *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var buf bytes.Buffer
+		if err := WriteText(&buf, r.Gather()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", ContentType)
+		w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		w.Write(buf.Bytes())
+	})
+}
+
+// WriteText 以 Prometheus 文本格式输出指标
+func WriteText(w io.Writer, families []Family) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		if f.Help != "" {
+			bw.WriteString("# HELP " + f.Name + " " + helpEscaper.Replace(f.Help) + "\n")
+		}
+		bw.WriteString("# TYPE " + f.Name + " " + string(f.Type) + "\n")
+
+		for _, s := range f.Samples {
+			if f.Type != HistogramType {
+				writeSample(bw, f.Name, s.Labels, s.Value)
+				continue
+			}
+			for _, b := range s.Buckets {
+				writeSample(bw, f.Name+"_bucket", withLe(s.Labels, b.UpperBound), float64(b.Count))
+			}
+			writeSample(bw, f.Name+"_bucket", withLe(s.Labels, math.Inf(1)), float64(s.Count))
+			writeSample(bw, f.Name+"_sum", s.Labels, s.Sum)
+			writeSample(bw, f.Name+"_count", s.Labels, float64(s.Count))
+		}
+	}
+	return bw.Flush()
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func writeSample(w *bufio.Writer, name string, labels []Label, value float64) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(l.Name + `="` + labelEscaper.Replace(l.Value) + `"`)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+// withLe 追加直方图桶的上界标签
+func withLe(labels []Label, bound float64) []Label {
+	out := make([]Label, len(labels), len(labels)+1)
+	copy(out, labels)
+	return append(out, Label{Name: "le", Value: formatFloat(bound)})
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,188 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// vec 按标签值区分的一组指标
+type vec[T any] struct {
+	name       string
+	help       string
+	labelNames []string
+
+	mu      sync.Mutex
+	entries map[string]*entry[T]
+}
+
+type entry[T any] struct {
+	labels []Label
+	value  T
+}
+
+func newVec[T any](name, help string, labelNames []string) vec[T] {
+	return vec[T]{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		entries:    make(map[string]*entry[T]),
+	}
+}
+
+// with 返回标签值对应的指标, 不存在时创建; 调用方需要持有锁
+// 标签值个数与标签名不一致时, 缺少的标签值为空, 多余的被忽略
+func (v *vec[T]) with(labelValues []string, init func() T) *entry[T] {
+	values := make([]string, len(v.labelNames))
+	copy(values, labelValues)
+	key := strings.Join(values, "\xff")
+
+	e, ok := v.entries[key]
+	if !ok {
+		e = &entry[T]{labels: make([]Label, len(values)), value: init()}
+		for i, name := range v.labelNames {
+			e.labels[i] = Label{Name: name, Value: values[i]}
+		}
+		v.entries[key] = e
+	}
+	return e
+}
+
+// collect 按标签值排序输出
+func (v *vec[T]) collect(typ Type, sample func(labels []Label, value T) Sample) []Family {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	keys := make([]string, 0, len(v.entries))
+	for key := range v.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	f := Family{Name: v.name, Help: v.help, Type: typ}
+	for _, key := range keys {
+		e := v.entries[key]
+		f.Samples = append(f.Samples, sample(e.labels, e.value))
+	}
+	return []Family{f}
+}
+
+// CounterVec 只增不减的计数器
+type CounterVec struct {
+	vec[float64]
+}
+
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{newVec[float64](name, help, labelNames)}
+}
+
+// Inc 加 1
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add 增加 delta, 负数被忽略
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.with(labelValues, zero).value += delta
+}
+
+func (c *CounterVec) Collect() []Family {
+	return c.collect(CounterType, valueSample)
+}
+
+// GaugeVec 可增可减的仪表盘
+type GaugeVec struct {
+	vec[float64]
+}
+
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{newVec[float64](name, help, labelNames)}
+}
+
+// Set 设置为 value
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.with(labelValues, zero).value = value
+}
+
+// Add 增加 delta, delta 可以为负数
+func (g *GaugeVec) Add(delta float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.with(labelValues, zero).value += delta
+}
+
+func (g *GaugeVec) Collect() []Family {
+	return g.collect(GaugeType, valueSample)
+}
+
+// DefBuckets 默认的耗时直方图桶, 单位秒
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// SizeBuckets 默认的消息大小直方图桶, 单位字节
+var SizeBuckets = []float64{64, 256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20}
+
+// HistogramVec 直方图
+type HistogramVec struct {
+	vec[*histogram]
+	buckets []float64
+}
+
+type histogram struct {
+	counts []uint64 // 每个桶的非累计观测数
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec 创建直方图, buckets 为升序的桶上界, 为空时使用 DefBuckets
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	return &HistogramVec{
+		vec:     newVec[*histogram](name, help, labelNames),
+		buckets: buckets,
+	}
+}
+
+// Observe 记录一次观测
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	e := h.with(labelValues, func() *histogram {
+		return &histogram{counts: make([]uint64, len(h.buckets))}
+	})
+	i := sort.SearchFloat64s(h.buckets, value)
+	if i < len(h.buckets) {
+		e.value.counts[i]++
+	}
+	e.value.count++
+	e.value.sum += value
+}
+
+func (h *HistogramVec) Collect() []Family {
+	return h.collect(HistogramType, func(labels []Label, value *histogram) Sample {
+		s := Sample{Labels: labels, Count: value.count, Sum: value.sum}
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += value.counts[i]
+			s.Buckets = append(s.Buckets, Bucket{UpperBound: bound, Count: cumulative})
+		}
+		return s
+	})
+}
+
+func zero() float64 {
+	return 0
+}
+
+func valueSample(labels []Label, value float64) Sample {
+	return Sample{Labels: labels, Value: value}
+}
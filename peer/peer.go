@@ -0,0 +1,38 @@
+package peer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+)
+
+// Peer 连接对端的信息, 服务端会将其注入处理函数的上下文
+type Peer struct {
+	// 对端地址
+	Addr net.Addr
+	// TLS 连接状态, 未使用 TLS 时为 nil
+	TLS *tls.ConnectionState
+}
+
+// Certificate 返回对端提供的证书, 未使用 TLS 或对端没有提供证书时返回 nil
+// 服务端要求校验客户端证书 (mTLS) 时, 该证书已通过 CA 校验, 可以作为客户端身份
+func (p *Peer) Certificate() *x509.Certificate {
+	if p.TLS == nil || len(p.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+	return p.TLS.PeerCertificates[0]
+}
+
+type peerKey struct{}
+
+// NewContext 创建携带对端信息的上下文
+func NewContext(ctx context.Context, p *Peer) context.Context {
+	return context.WithValue(ctx, peerKey{}, p)
+}
+
+// FromContext 获取上下文中的对端信息
+func FromContext(ctx context.Context) (*Peer, bool) {
+	p, ok := ctx.Value(peerKey{}).(*Peer)
+	return p, ok
+}
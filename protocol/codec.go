@@ -20,45 +20,48 @@ func NewDefaultCodec() *DefaultCodec {
 	return &DefaultCodec{}
 }
 
-// 消息格式: 
+// 消息格式:
 // | 魔数 4字节 | 头部长度 4字节 | 消息长度 4字节 | 头部数据 | 消息数据 |
+// 魔数为 magicNumber 时头部数据为带版本号的二进制格式 (见 header.go),
+// 为 legacyMagicNumber 时为旧版本使用的 gob 格式, 只用于解码
 const (
-	magicNumber = 0x11223344
+	magicNumber       = 0x4c525043 // "LRPC"
+	legacyMagicNumber = 0x11223344
 )
 
 func (c *DefaultCodec) Encode(message *Message) ([]byte, error) {
-	// 编码消息头
-	headerData, err := encode(message.Header)
+	if message == nil || message.Header == nil {
+		return nil, ErrInvalidMessage
+	}
+
+	// 帧头、消息头和消息体写入同一个缓冲区
+	buf := make([]byte, FrameHeaderSize, FrameHeaderSize+headerSize(message.Header)+len(message.Data))
+	buf, err := appendHeader(buf, message.Header)
 	if err != nil {
 		return nil, err
 	}
-
-	// 计算总长度
-	totalLen := 12 + len(headerData) + len(message.Data)
-	buf := make([]byte, totalLen)
+	headerLen := len(buf) - FrameHeaderSize
 
 	// 写入魔数
 	binary.BigEndian.PutUint32(buf[0:4], magicNumber)
 	// 写入头部长度
-	binary.BigEndian.PutUint32(buf[4:8], uint32(len(headerData)))
+	binary.BigEndian.PutUint32(buf[4:8], uint32(headerLen))
 	// 写入消息体长度
 	binary.BigEndian.PutUint32(buf[8:12], uint32(len(message.Data)))
-	// 写入头部数据
-	copy(buf[12:12+len(headerData)], headerData)
 	// 写入消息数据
-	copy(buf[12+len(headerData):], message.Data)
+	buf = append(buf, message.Data...)
 
 	return buf, nil
 }
 
 func (c *DefaultCodec) Decode(data []byte) (*Message, error) {
-	if len(data) < 12 {
+	if len(data) < FrameHeaderSize {
 		return nil, ErrInvalidMessage
 	}
 
 	// 验证魔数
 	magic := binary.BigEndian.Uint32(data[0:4])
-	if magic != magicNumber {
+	if magic != magicNumber && magic != legacyMagicNumber {
 		return nil, ErrInvalidMagic
 	}
 
@@ -66,13 +69,13 @@ func (c *DefaultCodec) Decode(data []byte) (*Message, error) {
 	headerLen := binary.BigEndian.Uint32(data[4:8])
 	dataLen := binary.BigEndian.Uint32(data[8:12])
 
-	if uint32(len(data)) < 12+headerLen+dataLen {
+	// 使用 uint64 计算, 避免长度相加后溢出
+	if uint64(len(data)) < FrameHeaderSize+uint64(headerLen)+uint64(dataLen) {
 		return nil, ErrInvalidMessage
 	}
 
 	// 解码消息头
-	header := &Header{}
-	err := decode(data[12:12+headerLen], header)
+	header, err := decodeFrameHeader(magic, data[FrameHeaderSize:FrameHeaderSize+headerLen])
 	if err != nil {
 		return nil, err
 	}
@@ -80,8 +83,20 @@ func (c *DefaultCodec) Decode(data []byte) (*Message, error) {
 	// 构造消息
 	message := &Message{
 		Header: header,
-		Data:   data[12+headerLen : 12+headerLen+dataLen],
+		Data:   data[FrameHeaderSize+headerLen : FrameHeaderSize+headerLen+dataLen],
 	}
 
 	return message, nil
-}
\ No newline at end of file
+}
+
+// decodeFrameHeader 按魔数选择消息头格式解码
+func decodeFrameHeader(magic uint32, data []byte) (*Header, error) {
+	if magic == legacyMagicNumber {
+		header := &Header{}
+		if err := decode(data, header); err != nil {
+			return nil, err
+		}
+		return header, nil
+	}
+	return decodeHeader(data)
+}
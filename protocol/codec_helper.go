@@ -5,6 +5,7 @@ import (
 	"encoding/gob"
 )
 
+// encode 使用 gob 编码, 旧版本的消息头格式, 帧头魔数为 legacyMagicNumber
 func encode(v interface{}) ([]byte, error) {
 	var buf bytes.Buffer
 	encoder := gob.NewEncoder(&buf)
@@ -14,7 +15,8 @@ func encode(v interface{}) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// decode 解码旧版本 gob 格式的消息头
 func decode(data []byte, v interface{}) error {
 	decoder := gob.NewDecoder(bytes.NewReader(data))
 	return decoder.Decode(v)
-}
\ No newline at end of file
+}
@@ -20,6 +20,7 @@ func (s *CodecTestSuite) TestEncodeAndDecode() {
 	// 准备测试数据
 	header := &Header{
 		ID:          1,
+		StreamID:    7,
 		Type:        TypeRequest,
 		Compress:    0,
 		Codec:       "json",
@@ -29,6 +30,12 @@ func (s *CodecTestSuite) TestEncodeAndDecode() {
 			"trace_id": "123456",
 		},
 		Timeout: time.Second * 2,
+		Error:   "user not found",
+		Code:    5,
+		Reason:  "USER_NOT_FOUND",
+		Details: map[string]string{
+			"user_id": "1",
+		},
 	}
 
 	message := &Message{
@@ -48,6 +55,7 @@ func (s *CodecTestSuite) TestEncodeAndDecode() {
 
 	// 验证解码结果
 	s.Equal(message.Header.ID, decoded.Header.ID)
+	s.Equal(message.Header.StreamID, decoded.Header.StreamID)
 	s.Equal(message.Header.Type, decoded.Header.Type)
 	s.Equal(message.Header.Compress, decoded.Header.Compress)
 	s.Equal(message.Header.Codec, decoded.Header.Codec)
@@ -55,6 +63,10 @@ func (s *CodecTestSuite) TestEncodeAndDecode() {
 	s.Equal(message.Header.MethodName, decoded.Header.MethodName)
 	s.Equal(message.Header.Metadata, decoded.Header.Metadata)
 	s.Equal(message.Header.Timeout, decoded.Header.Timeout)
+	s.Equal(message.Header.Error, decoded.Header.Error)
+	s.Equal(message.Header.Code, decoded.Header.Code)
+	s.Equal(message.Header.Reason, decoded.Header.Reason)
+	s.Equal(message.Header.Details, decoded.Header.Details)
 	s.Equal(message.Data, decoded.Data)
 }
 
@@ -79,6 +91,11 @@ func (s *CodecTestSuite) TestDecodeInvalidData() {
 			data:    make([]byte, 12),
 			wantErr: ErrInvalidMagic,
 		},
+		{
+			name:    "长度相加溢出",
+			data:    []byte{0x11, 0x22, 0x33, 0x44, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 1},
+			wantErr: ErrInvalidMessage,
+		},
 	}
 
 	for _, tt := range tests {
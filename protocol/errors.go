@@ -5,4 +5,8 @@ import "errors"
 var (
 	ErrInvalidMessage = errors.New("invalid message")
 	ErrInvalidMagic   = errors.New("invalid magic number")
+	// ErrFrameTooLarge 消息头或消息体超过允许的最大长度
+	ErrFrameTooLarge = errors.New("frame too large")
+	// ErrUnsupportedVersion 不支持的消息头版本
+	ErrUnsupportedVersion = errors.New("unsupported header version")
 )
\ No newline at end of file
@@ -0,0 +1,346 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"slices"
+	"sync"
+)
+
+const (
+	// FrameHeaderSize 帧头长度: 魔数 4字节 | 头部长度 4字节 | 消息长度 4字节
+	FrameHeaderSize = 12
+	// DefaultMaxHeaderSize 默认的消息头最大长度
+	DefaultMaxHeaderSize = 1 << 20
+	// DefaultMaxBodySize 默认的消息体最大长度
+	DefaultMaxBodySize = 16 << 20
+
+	// readChunkSize 读取消息体时每次扩容的最大长度, 消息体的内存随实际收到的数据增长,
+	// 避免对端只发送帧头就让本端按声明的长度分配内存
+	readChunkSize = 64 << 10
+	// maxPooledBufferSize 超过该容量的缓冲区不放回缓冲池
+	maxPooledBufferSize = 1 << 20
+
+	// envelopeMagicNumber 信封帧的魔数 "LRPE", 信封帧的消息头长度为 0, 消息体为 Envelope.Seal 处理后的整帧
+	envelopeMagicNumber = 0x4c525045
+	// envelopeOverhead 加密在整帧之外增加的最大长度 (nonce 和认证标签)
+	envelopeOverhead = 1024
+)
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBufferSize {
+		bufferPool.Put(buf)
+	}
+}
+
+// Envelope 对整帧进行的加密或压缩, 加密或压缩后的帧作为信封帧收发
+// Seal 和 Open 返回的数据不能与传入的数据共享内存, 传入的缓冲区会被放回缓冲池;
+// Open 还原出的帧超过 maxSize 时返回 ErrFrameTooLarge
+type Envelope interface {
+	Seal(frame []byte) ([]byte, error)
+	Open(data []byte, maxSize int) ([]byte, error)
+}
+
+// frameLimits 帧大小限制, 小于等于 0 时使用默认值
+type frameLimits struct {
+	maxHeaderSize uint32
+	maxBodySize   uint32
+}
+
+func newFrameLimits(maxHeaderSize, maxBodySize int) frameLimits {
+	if maxHeaderSize <= 0 {
+		maxHeaderSize = DefaultMaxHeaderSize
+	}
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+	return frameLimits{maxHeaderSize: uint32(maxHeaderSize), maxBodySize: uint32(maxBodySize)}
+}
+
+// parse 校验帧头并返回消息头和消息体的长度
+func (l frameLimits) parse(prefix []byte) (int, int, error) {
+	if magic := binary.BigEndian.Uint32(prefix[0:4]); magic != magicNumber && magic != legacyMagicNumber {
+		return 0, 0, ErrInvalidMagic
+	}
+	headerLen := binary.BigEndian.Uint32(prefix[4:8])
+	bodyLen := binary.BigEndian.Uint32(prefix[8:12])
+	if headerLen > l.maxHeaderSize || bodyLen > l.maxBodySize {
+		return 0, 0, ErrFrameTooLarge
+	}
+	return int(headerLen), int(bodyLen), nil
+}
+
+// check 校验完整的帧, 声明的长度需要与实际长度一致
+func (l frameLimits) check(frame []byte) error {
+	if len(frame) < FrameHeaderSize {
+		return ErrInvalidMessage
+	}
+	headerLen, bodyLen, err := l.parse(frame)
+	if err != nil {
+		return err
+	}
+	if len(frame) != FrameHeaderSize+headerLen+bodyLen {
+		return ErrInvalidMessage
+	}
+	return nil
+}
+
+// frameSize 帧的最大长度
+func (l frameLimits) frameSize() uint64 {
+	return FrameHeaderSize + uint64(l.maxHeaderSize) + uint64(l.maxBodySize)
+}
+
+// envelopeSize 信封帧消息体的最大长度, 无法压缩的数据压缩后会变长, 为此预留 1/4 的余量
+func (l frameLimits) envelopeSize() uint64 {
+	return l.frameSize() + l.frameSize()/4 + envelopeOverhead
+}
+
+// parseEnvelope 校验信封帧的帧头并返回信封数据的长度
+func (l frameLimits) parseEnvelope(prefix []byte) (int, error) {
+	if binary.BigEndian.Uint32(prefix[0:4]) != envelopeMagicNumber {
+		return 0, ErrInvalidMagic
+	}
+	if binary.BigEndian.Uint32(prefix[4:8]) != 0 {
+		return 0, ErrInvalidMessage
+	}
+	n := binary.BigEndian.Uint32(prefix[8:12])
+	if uint64(n) > l.envelopeSize() {
+		return 0, ErrFrameTooLarge
+	}
+	return int(n), nil
+}
+
+// FrameReader 从字节流中逐帧读取消息, 不是并发安全的
+type FrameReader struct {
+	r        io.Reader
+	limits   frameLimits
+	envelope Envelope
+	prefix   [FrameHeaderSize]byte
+}
+
+// NewFrameReader 创建帧读取器, 消息头或消息体超过最大长度时返回 ErrFrameTooLarge,
+// 最大长度小于等于 0 时使用 DefaultMaxHeaderSize 和 DefaultMaxBodySize
+func NewFrameReader(r io.Reader, maxHeaderSize, maxBodySize int) *FrameReader {
+	return &FrameReader{r: r, limits: newFrameLimits(maxHeaderSize, maxBodySize)}
+}
+
+// NewEnvelopeReader 创建读取信封帧的帧读取器, 每个信封帧由 envelope 还原后按 NewFrameReader 的限制校验
+func NewEnvelopeReader(r io.Reader, envelope Envelope, maxHeaderSize, maxBodySize int) *FrameReader {
+	fr := NewFrameReader(r, maxHeaderSize, maxBodySize)
+	fr.envelope = envelope
+	return fr
+}
+
+// ReadFrame 读取一个完整的帧, 返回的数据包含帧头, 可以交给 DefaultCodec.Decode 解码
+// 帧读取到一半时连接断开返回 io.ErrUnexpectedEOF
+func (fr *FrameReader) ReadFrame() ([]byte, error) {
+	if fr.envelope != nil {
+		return fr.readEnvelope()
+	}
+
+	headerLen, bodyLen, err := fr.readPrefix()
+	if err != nil {
+		return nil, err
+	}
+
+	frame := make([]byte, FrameHeaderSize, FrameHeaderSize+min(headerLen+bodyLen, readChunkSize))
+	copy(frame, fr.prefix[:])
+	return readN(fr.r, frame, headerLen+bodyLen)
+}
+
+// ReadMessage 读取并解码一条消息, 消息头使用缓冲池中的缓冲区读取, 消息体由调用方持有, 不使用缓冲池
+// 消息头无法解码时返回 ErrInvalidMessage 或 ErrUnsupportedVersion, 该帧已被完整读取, 可以继续读取下一帧
+func (fr *FrameReader) ReadMessage() (*Message, error) {
+	if fr.envelope != nil {
+		frame, err := fr.readEnvelope()
+		if err != nil {
+			return nil, err
+		}
+		return NewDefaultCodec().Decode(frame)
+	}
+
+	headerLen, bodyLen, err := fr.readPrefix()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if _, err := io.CopyN(buf, fr.r, int64(headerLen)); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	header, err := decodeFrameHeader(binary.BigEndian.Uint32(fr.prefix[0:4]), buf.Bytes())
+	if err != nil {
+		// 跳过消息体, 之后仍然可以继续读取下一帧
+		if _, discardErr := io.CopyN(io.Discard, fr.r, int64(bodyLen)); discardErr != nil {
+			return nil, unexpectedEOF(discardErr)
+		}
+		return nil, err
+	}
+
+	data, err := readN(fr.r, make([]byte, 0, min(bodyLen, readChunkSize)), bodyLen)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Header: header, Data: data}, nil
+}
+
+// readPrefix 读取并校验帧头
+func (fr *FrameReader) readPrefix() (int, int, error) {
+	if _, err := io.ReadFull(fr.r, fr.prefix[:]); err != nil {
+		// 帧之间连接正常关闭时返回 io.EOF
+		return 0, 0, err
+	}
+	return fr.limits.parse(fr.prefix[:])
+}
+
+// readEnvelope 读取信封帧并还原出协议帧, 信封数据读取到缓冲池中的缓冲区, 随收到的数据逐步扩容
+func (fr *FrameReader) readEnvelope() ([]byte, error) {
+	if _, err := io.ReadFull(fr.r, fr.prefix[:]); err != nil {
+		return nil, err
+	}
+	n, err := fr.limits.parseEnvelope(fr.prefix[:])
+	if err != nil {
+		return nil, err
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if _, err := io.CopyN(buf, fr.r, int64(n)); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	frame, err := fr.envelope.Open(buf.Bytes(), int(min(fr.limits.frameSize(), math.MaxInt)))
+	if err != nil {
+		return nil, err
+	}
+	if err := fr.limits.check(frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// readN 读取 n 字节追加到 buf 之后, 按 readChunkSize 分块读取并逐步扩容
+func readN(r io.Reader, buf []byte, n int) ([]byte, error) {
+	for n > 0 {
+		chunk := min(n, readChunkSize)
+		buf = slices.Grow(buf, chunk)
+		read, err := io.ReadFull(r, buf[len(buf):len(buf)+chunk])
+		buf = buf[:len(buf)+read]
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		n -= chunk
+	}
+	return buf, nil
+}
+
+// unexpectedEOF 帧已经开始读取后遇到的 io.EOF 转换为 io.ErrUnexpectedEOF
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// FrameWriter 向字节流中逐帧写入消息, 不是并发安全的
+type FrameWriter struct {
+	w        io.Writer
+	limits   frameLimits
+	envelope Envelope
+}
+
+// NewFrameWriter 创建帧写入器, 超过最大长度的消息不会写出, 返回 ErrFrameTooLarge,
+// 最大长度小于等于 0 时使用 DefaultMaxHeaderSize 和 DefaultMaxBodySize
+func NewFrameWriter(w io.Writer, maxHeaderSize, maxBodySize int) *FrameWriter {
+	return &FrameWriter{w: w, limits: newFrameLimits(maxHeaderSize, maxBodySize)}
+}
+
+// NewEnvelopeWriter 创建写出信封帧的帧写入器, 每个帧按 NewFrameWriter 的限制校验后由 envelope 处理
+func NewEnvelopeWriter(w io.Writer, envelope Envelope, maxHeaderSize, maxBodySize int) *FrameWriter {
+	fw := NewFrameWriter(w, maxHeaderSize, maxBodySize)
+	fw.envelope = envelope
+	return fw
+}
+
+// WriteMessage 编码并写出一条消息, 帧头、消息头和消息体在缓冲池中的缓冲区中合并为一次写入
+func (fw *FrameWriter) WriteMessage(message *Message) error {
+	if message == nil {
+		return ErrInvalidMessage
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	// 预留帧头, 消息头直接编码到缓冲池中缓冲区的空闲空间
+	var prefix [FrameHeaderSize]byte
+	frame, err := appendHeader(append(buf.AvailableBuffer(), prefix[:]...), message.Header)
+	if err != nil {
+		return err
+	}
+	headerLen := len(frame) - FrameHeaderSize
+	if uint64(headerLen) > uint64(fw.limits.maxHeaderSize) || uint64(len(message.Data)) > uint64(fw.limits.maxBodySize) {
+		return ErrFrameTooLarge
+	}
+	binary.BigEndian.PutUint32(frame[0:4], magicNumber)
+	binary.BigEndian.PutUint32(frame[4:8], uint32(headerLen))
+	binary.BigEndian.PutUint32(frame[8:12], uint32(len(message.Data)))
+	buf.Write(frame)
+	buf.Write(message.Data)
+
+	if fw.envelope != nil {
+		return fw.writeEnvelope(buf.Bytes())
+	}
+	_, err = fw.w.Write(buf.Bytes())
+	return err
+}
+
+// WriteFrame 写出 DefaultCodec 编码好的帧, 写出前校验帧头和长度
+func (fw *FrameWriter) WriteFrame(frame []byte) error {
+	if err := fw.limits.check(frame); err != nil {
+		return err
+	}
+	if fw.envelope != nil {
+		return fw.writeEnvelope(frame)
+	}
+
+	_, err := fw.w.Write(frame)
+	return err
+}
+
+// writeEnvelope 将 Envelope.Seal 处理后的帧作为信封帧写出, 帧头和信封数据在缓冲池中的缓冲区中合并为一次写入
+func (fw *FrameWriter) writeEnvelope(frame []byte) error {
+	data, err := fw.envelope.Seal(frame)
+	if err != nil {
+		return err
+	}
+	if uint64(len(data)) > fw.limits.envelopeSize() {
+		return ErrFrameTooLarge
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	var prefix [FrameHeaderSize]byte
+	binary.BigEndian.PutUint32(prefix[0:4], envelopeMagicNumber)
+	binary.BigEndian.PutUint32(prefix[8:12], uint32(len(data)))
+	buf.Write(prefix[:])
+	buf.Write(data)
+
+	_, err = fw.w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,195 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FrameTestSuite struct {
+	suite.Suite
+}
+
+func (s *FrameTestSuite) message(id uint64, data string) *Message {
+	return &Message{
+		Header: &Header{ID: id, Type: TypeRequest, ServiceName: "EchoService", MethodName: "Echo"},
+		Data:   []byte(data),
+	}
+}
+
+func (s *FrameTestSuite) TestReadWrite() {
+	var buf bytes.Buffer
+	w := NewFrameWriter(&buf, 0, 0)
+	s.Require().NoError(w.WriteMessage(s.message(1, "hello")))
+	s.Require().NoError(w.WriteMessage(s.message(2, string(bytes.Repeat([]byte("x"), 3*readChunkSize+1)))))
+
+	// 编码好的帧与 DefaultCodec 的格式相同
+	frame, err := NewDefaultCodec().Encode(s.message(3, ""))
+	s.Require().NoError(err)
+	s.Require().NoError(w.WriteFrame(frame))
+
+	// 每次只返回一个字节, 模拟分段到达的数据
+	r := NewFrameReader(iotest.OneByteReader(&buf), 0, 0)
+	msg, err := r.ReadMessage()
+	s.Require().NoError(err)
+	s.Equal(uint64(1), msg.Header.ID)
+	s.Equal("EchoService", msg.Header.ServiceName)
+	s.Equal([]byte("hello"), msg.Data)
+
+	data, err := r.ReadFrame()
+	s.Require().NoError(err)
+	msg, err = NewDefaultCodec().Decode(data)
+	s.Require().NoError(err)
+	s.Equal(uint64(2), msg.Header.ID)
+	s.Len(msg.Data, 3*readChunkSize+1)
+
+	msg, err = r.ReadMessage()
+	s.Require().NoError(err)
+	s.Equal(uint64(3), msg.Header.ID)
+	s.Empty(msg.Data)
+
+	// 帧之间的连接关闭
+	_, err = r.ReadFrame()
+	s.Equal(io.EOF, err)
+}
+
+func (s *FrameTestSuite) TestLimits() {
+	var buf bytes.Buffer
+	s.Require().NoError(NewFrameWriter(&buf, 0, 0).WriteMessage(s.message(1, "0123456789")))
+	frame := bytes.Clone(buf.Bytes())
+
+	// 消息体超过最大长度
+	_, err := NewFrameReader(bytes.NewReader(frame), 0, 9).ReadFrame()
+	s.Equal(ErrFrameTooLarge, err)
+	_, err = NewFrameReader(bytes.NewReader(frame), 0, 10).ReadFrame()
+	s.NoError(err)
+
+	// 消息头超过最大长度
+	_, err = NewFrameReader(bytes.NewReader(frame), 8, 0).ReadMessage()
+	s.Equal(ErrFrameTooLarge, err)
+
+	// 发送超过最大长度的消息
+	s.Equal(ErrFrameTooLarge, NewFrameWriter(io.Discard, 0, 9).WriteMessage(s.message(1, "0123456789")))
+	s.Equal(ErrFrameTooLarge, NewFrameWriter(io.Discard, 0, 9).WriteFrame(frame))
+
+	// 声明的长度与实际长度不一致
+	s.Equal(ErrInvalidMessage, NewFrameWriter(io.Discard, 0, 0).WriteFrame(frame[:len(frame)-1]))
+}
+
+func (s *FrameTestSuite) TestInvalidFrame() {
+	// 魔数错误
+	_, err := NewFrameReader(bytes.NewReader(make([]byte, FrameHeaderSize)), 0, 0).ReadFrame()
+	s.Equal(ErrInvalidMagic, err)
+
+	// 帧头不完整
+	_, err = NewFrameReader(bytes.NewReader(make([]byte, 4)), 0, 0).ReadFrame()
+	s.Equal(io.ErrUnexpectedEOF, err)
+
+	// 声明了很大的消息体但连接在发送完之前关闭
+	prefix := make([]byte, FrameHeaderSize)
+	binary.BigEndian.PutUint32(prefix[0:4], magicNumber)
+	binary.BigEndian.PutUint32(prefix[8:12], DefaultMaxBodySize)
+	_, err = NewFrameReader(bytes.NewReader(append(prefix, "partial"...)), 0, 0).ReadFrame()
+	s.Equal(io.ErrUnexpectedEOF, err)
+
+	frame, err := NewDefaultCodec().Encode(s.message(1, "partial"))
+	s.Require().NoError(err)
+	binary.BigEndian.PutUint32(frame[8:12], DefaultMaxBodySize)
+	_, err = NewFrameReader(bytes.NewReader(frame), 0, 0).ReadMessage()
+	s.Equal(io.ErrUnexpectedEOF, err)
+}
+
+func (s *FrameTestSuite) TestSkipInvalidHeader() {
+	frame, err := NewDefaultCodec().Encode(s.message(1, "invalid"))
+	s.Require().NoError(err)
+	frame[FrameHeaderSize] = HeaderVersion + 1
+	next, err := NewDefaultCodec().Encode(s.message(2, "next"))
+	s.Require().NoError(err)
+
+	// 消息头无法解码的帧被完整跳过, 不影响下一帧
+	r := NewFrameReader(bytes.NewReader(append(frame, next...)), 0, 0)
+	_, err = r.ReadMessage()
+	s.Equal(ErrUnsupportedVersion, err)
+	msg, err := r.ReadMessage()
+	s.Require().NoError(err)
+	s.Equal(uint64(2), msg.Header.ID)
+}
+
+// xorEnvelope 按字节取反的信封, 用于测试
+type xorEnvelope struct{}
+
+func (xorEnvelope) Seal(frame []byte) ([]byte, error) {
+	data := make([]byte, len(frame))
+	for i, b := range frame {
+		data[i] = ^b
+	}
+	return data, nil
+}
+
+func (e xorEnvelope) Open(data []byte, maxSize int) ([]byte, error) {
+	if len(data) > maxSize {
+		return nil, ErrFrameTooLarge
+	}
+	return e.Seal(data)
+}
+
+func (s *FrameTestSuite) TestEnvelope() {
+	var buf bytes.Buffer
+	w := NewEnvelopeWriter(&buf, xorEnvelope{}, 0, 0)
+	s.Require().NoError(w.WriteMessage(s.message(1, "hello")))
+	frame, err := NewDefaultCodec().Encode(s.message(2, "world"))
+	s.Require().NoError(err)
+	s.Require().NoError(w.WriteFrame(frame))
+
+	// 信封帧使用与协议帧相同的帧头, 没有额外的长度前缀
+	data := buf.Bytes()
+	s.Equal(uint32(envelopeMagicNumber), binary.BigEndian.Uint32(data[0:4]))
+	s.Zero(binary.BigEndian.Uint32(data[4:8]))
+	s.Len(data, 2*FrameHeaderSize+int(binary.BigEndian.Uint32(data[8:12]))+len(frame))
+
+	r := NewEnvelopeReader(iotest.OneByteReader(&buf), xorEnvelope{}, 0, 0)
+	msg, err := r.ReadMessage()
+	s.Require().NoError(err)
+	s.Equal(uint64(1), msg.Header.ID)
+	s.Equal([]byte("hello"), msg.Data)
+	read, err := r.ReadFrame()
+	s.Require().NoError(err)
+	s.Equal(frame, read)
+	_, err = r.ReadFrame()
+	s.Equal(io.EOF, err)
+
+	// 超过限制的消息不会写出
+	s.Equal(ErrFrameTooLarge, NewEnvelopeWriter(io.Discard, xorEnvelope{}, 0, 9).WriteMessage(s.message(1, "0123456789")))
+
+	// 普通的协议帧不能按信封帧读取
+	_, err = NewEnvelopeReader(bytes.NewReader(frame), xorEnvelope{}, 0, 0).ReadFrame()
+	s.Equal(ErrInvalidMagic, err)
+}
+
+func (s *FrameTestSuite) TestEnvelopeLimits() {
+	// 声明了超大信封的帧头直接返回错误, 不等待数据
+	prefix := make([]byte, FrameHeaderSize)
+	binary.BigEndian.PutUint32(prefix[0:4], envelopeMagicNumber)
+	binary.BigEndian.PutUint32(prefix[8:12], 1<<31)
+	_, err := NewEnvelopeReader(bytes.NewReader(prefix), xorEnvelope{}, 0, 0).ReadFrame()
+	s.Equal(ErrFrameTooLarge, err)
+
+	// 信封数据不完整
+	binary.BigEndian.PutUint32(prefix[8:12], 1024)
+	_, err = NewEnvelopeReader(bytes.NewReader(append(prefix, "partial"...)), xorEnvelope{}, 0, 0).ReadFrame()
+	s.Equal(io.ErrUnexpectedEOF, err)
+
+	// 还原出的帧按协议帧的限制校验
+	var buf bytes.Buffer
+	s.Require().NoError(NewEnvelopeWriter(&buf, xorEnvelope{}, 0, 0).WriteMessage(s.message(1, "0123456789")))
+	_, err = NewEnvelopeReader(bytes.NewReader(buf.Bytes()), xorEnvelope{}, 0, 9).ReadFrame()
+	s.Equal(ErrFrameTooLarge, err)
+}
+
+func TestFrameSuite(t *testing.T) {
+	suite.Run(t, new(FrameTestSuite))
+}
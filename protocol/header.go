@@ -0,0 +1,235 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// 二进制消息头格式, 整数使用 varint 编码, 字符串为 uvarint 长度 + 内容, 方括号中的字段由标志位决定是否存在:
+// | 版本 1字节 | 消息类型 1字节 | 标志位 1字节 | 压缩类型 1字节 | 序列化类型编号 1字节 |
+// | 消息ID | [流ID] | 超时时间(纳秒) | 服务名 | 方法名 | [序列化类型名称] |
+// | [元数据: 数量 + 键值对] | [错误信息 | 错误码 | 错误原因 | 错误详情: 数量 + 键值对] |
+const (
+	// HeaderVersion 当前的消息头版本
+	HeaderVersion byte = 1
+
+	// headerFixedSize 消息头中固定长度部分的长度
+	headerFixedSize = 5
+)
+
+// 消息头标志位
+const (
+	flagStreamID byte = 1 << iota
+	flagMetadata
+	flagError
+)
+
+// codecIDs 常用序列化类型的编号, 其他序列化类型使用 codecCustom 并在消息头中携带名称
+var codecIDs = map[string]byte{
+	"":                       0,
+	"application/json":       1,
+	"application/x-protobuf": 2,
+	"application/x-msgpack":  3,
+}
+
+var codecNames = func() map[byte]string {
+	names := make(map[byte]string, len(codecIDs))
+	for name, id := range codecIDs {
+		names[id] = name
+	}
+	return names
+}()
+
+const codecCustom byte = 0xff
+
+// headerSize 估算消息头编码后的长度, 用于预分配缓冲区
+func headerSize(h *Header) int {
+	n := headerFixedSize + 3*binary.MaxVarintLen64 + 2*binary.MaxVarintLen32 +
+		len(h.ServiceName) + len(h.MethodName) + len(h.Codec)
+	for k, v := range h.Metadata {
+		n += 2*binary.MaxVarintLen32 + len(k) + len(v)
+	}
+	if h.Error != "" || h.Code != 0 || h.Reason != "" || len(h.Details) > 0 {
+		n += 4*binary.MaxVarintLen32 + len(h.Error) + len(h.Reason)
+		for k, v := range h.Details {
+			n += 2*binary.MaxVarintLen32 + len(k) + len(v)
+		}
+	}
+	return n
+}
+
+// appendHeader 将消息头按二进制格式追加到 buf 之后, 消息头为空时返回 ErrInvalidMessage
+func appendHeader(buf []byte, h *Header) ([]byte, error) {
+	if h == nil {
+		return nil, ErrInvalidMessage
+	}
+
+	var flags byte
+	if h.StreamID != 0 {
+		flags |= flagStreamID
+	}
+	if len(h.Metadata) > 0 {
+		flags |= flagMetadata
+	}
+	if h.Error != "" || h.Code != 0 || h.Reason != "" || len(h.Details) > 0 {
+		flags |= flagError
+	}
+	codecID, ok := codecIDs[h.Codec]
+	if !ok {
+		codecID = codecCustom
+	}
+
+	buf = append(buf, HeaderVersion, byte(h.Type), flags, h.Compress, codecID)
+	buf = binary.AppendUvarint(buf, h.ID)
+	if flags&flagStreamID != 0 {
+		buf = binary.AppendUvarint(buf, h.StreamID)
+	}
+	buf = binary.AppendVarint(buf, int64(h.Timeout))
+	buf = appendString(buf, h.ServiceName)
+	buf = appendString(buf, h.MethodName)
+	if codecID == codecCustom {
+		buf = appendString(buf, h.Codec)
+	}
+	if flags&flagMetadata != 0 {
+		buf = appendMap(buf, h.Metadata)
+	}
+	if flags&flagError != 0 {
+		buf = appendString(buf, h.Error)
+		buf = binary.AppendUvarint(buf, uint64(h.Code))
+		buf = appendString(buf, h.Reason)
+		buf = appendMap(buf, h.Details)
+	}
+	return buf, nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendMap(buf []byte, m map[string]string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(m)))
+	for k, v := range m {
+		buf = appendString(buf, k)
+		buf = appendString(buf, v)
+	}
+	return buf
+}
+
+// decodeHeader 解码二进制格式的消息头, 版本不支持时返回 ErrUnsupportedVersion
+func decodeHeader(data []byte) (*Header, error) {
+	if len(data) < headerFixedSize {
+		return nil, ErrInvalidMessage
+	}
+	if data[0] != HeaderVersion {
+		return nil, ErrUnsupportedVersion
+	}
+
+	h := &Header{
+		Type:     MessageType(data[1]),
+		Compress: data[3],
+	}
+	flags, codecID := data[2], data[4]
+	r := headerReader{data: data[headerFixedSize:]}
+
+	h.ID = r.uvarint()
+	if flags&flagStreamID != 0 {
+		h.StreamID = r.uvarint()
+	}
+	h.Timeout = time.Duration(r.varint())
+	h.ServiceName = r.string()
+	h.MethodName = r.string()
+	if codecID == codecCustom {
+		h.Codec = r.string()
+	} else if name, ok := codecNames[codecID]; ok {
+		h.Codec = name
+	} else {
+		return nil, ErrInvalidMessage
+	}
+	if flags&flagMetadata != 0 {
+		h.Metadata = r.stringMap()
+	}
+	if flags&flagError != 0 {
+		h.Error = r.string()
+		h.Code = uint32(r.uvarint())
+		h.Reason = r.string()
+		h.Details = r.stringMap()
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	return h, nil
+}
+
+// headerReader 按顺序读取消息头中的字段, 数据不完整时记录错误, 之后的读取都返回零值
+type headerReader struct {
+	data []byte
+	err  error
+}
+
+func (r *headerReader) fail() {
+	r.err = ErrInvalidMessage
+	r.data = nil
+}
+
+func (r *headerReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *headerReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *headerReader) string() string {
+	n := r.uvarint()
+	if r.err != nil {
+		return ""
+	}
+	if n > uint64(len(r.data)) {
+		r.fail()
+		return ""
+	}
+	s := string(r.data[:n])
+	r.data = r.data[n:]
+	return s
+}
+
+func (r *headerReader) stringMap() map[string]string {
+	n := r.uvarint()
+	if r.err != nil {
+		return nil
+	}
+	// 每个键值对至少占用 2 字节, 避免按声明的数量分配过大的 map
+	if n > uint64(len(r.data))/2 {
+		r.fail()
+		return nil
+	}
+	if n == 0 {
+		return nil
+	}
+	m := make(map[string]string, n)
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		k := r.string()
+		m[k] = r.string()
+	}
+	return m
+}
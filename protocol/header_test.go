@@ -0,0 +1,177 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type HeaderTestSuite struct {
+	suite.Suite
+}
+
+// testHeader 包含所有字段的消息头
+func testHeader() *Header {
+	return &Header{
+		ID:          1<<40 + 1,
+		StreamID:    7,
+		Type:        TypeResponse,
+		Compress:    2,
+		Codec:       "application/x-msgpack",
+		ServiceName: "UserService",
+		MethodName:  "GetUser",
+		Metadata:    map[string]string{"trace_id": "123456", "tenant": "t1"},
+		Timeout:     2 * time.Second,
+		Error:       "user not found",
+		Code:        5,
+		Reason:      "USER_NOT_FOUND",
+		Details:     map[string]string{"user_id": "1"},
+	}
+}
+
+// legacyFrame 按旧版本的 gob 消息头格式编码消息
+func legacyFrame(message *Message) ([]byte, error) {
+	headerData, err := encode(message.Header)
+	if err != nil {
+		return nil, err
+	}
+	frame := make([]byte, FrameHeaderSize, FrameHeaderSize+len(headerData)+len(message.Data))
+	binary.BigEndian.PutUint32(frame[0:4], legacyMagicNumber)
+	binary.BigEndian.PutUint32(frame[4:8], uint32(len(headerData)))
+	binary.BigEndian.PutUint32(frame[8:12], uint32(len(message.Data)))
+	frame = append(frame, headerData...)
+	return append(frame, message.Data...), nil
+}
+
+func (s *HeaderTestSuite) TestRoundTrip() {
+	headers := []*Header{
+		testHeader(),
+		{},
+		{Type: TypeHeartbeat, ID: 3},
+		{Codec: "application/x-custom", Timeout: -time.Second},
+		{Code: 14},
+	}
+	for _, h := range headers {
+		data, err := appendHeader(nil, h)
+		s.Require().NoError(err)
+		decoded, err := decodeHeader(data)
+		s.Require().NoError(err)
+		s.Equal(h, decoded)
+	}
+}
+
+func (s *HeaderTestSuite) TestSize() {
+	h := testHeader()
+	binaryHeader, err := appendHeader(nil, h)
+	s.Require().NoError(err)
+	gobHeader, err := encode(h)
+	s.Require().NoError(err)
+
+	s.LessOrEqual(len(binaryHeader), headerSize(h))
+	s.Less(len(binaryHeader), len(gobHeader)/2)
+}
+
+func (s *HeaderTestSuite) TestLegacyHeader() {
+	message := &Message{Header: testHeader(), Data: []byte("legacy")}
+	frame, err := legacyFrame(message)
+	s.Require().NoError(err)
+
+	decoded, err := NewDefaultCodec().Decode(frame)
+	s.Require().NoError(err)
+	s.Equal(message, decoded)
+
+	decoded, err = NewFrameReader(bytes.NewReader(frame), 0, 0).ReadMessage()
+	s.Require().NoError(err)
+	s.Equal(message, decoded)
+}
+
+func (s *HeaderTestSuite) TestInvalidHeader() {
+	data, err := appendHeader(nil, testHeader())
+	s.Require().NoError(err)
+
+	// 不支持的版本
+	unknown := bytes.Clone(data)
+	unknown[0] = HeaderVersion + 1
+	_, err = decodeHeader(unknown)
+	s.Equal(ErrUnsupportedVersion, err)
+
+	// 未知的序列化类型编号
+	unknown = bytes.Clone(data)
+	unknown[4] = 100
+	_, err = decodeHeader(unknown)
+	s.Equal(ErrInvalidMessage, err)
+
+	// 任意位置截断的消息头都返回错误
+	for i := 0; i < len(data); i++ {
+		_, err := decodeHeader(data[:i])
+		s.Equal(ErrInvalidMessage, err, "截断位置: %d", i)
+	}
+
+	// 声明了过多的元数据
+	h, err := appendHeader(nil, &Header{})
+	s.Require().NoError(err)
+	h[2] |= flagMetadata
+	h = binary.AppendUvarint(h, 1<<40)
+	_, err = decodeHeader(h)
+	s.Equal(ErrInvalidMessage, err)
+}
+
+func (s *HeaderTestSuite) TestNilHeader() {
+	_, err := appendHeader(nil, nil)
+	s.Equal(ErrInvalidMessage, err)
+
+	// 没有消息头的消息编码时返回错误
+	_, err = NewDefaultCodec().Encode(&Message{Data: []byte("hello")})
+	s.Equal(ErrInvalidMessage, err)
+	_, err = NewDefaultCodec().Encode(nil)
+	s.Equal(ErrInvalidMessage, err)
+}
+
+func TestHeaderSuite(t *testing.T) {
+	suite.Run(t, new(HeaderTestSuite))
+}
+
+func BenchmarkEncode(b *testing.B) {
+	message := &Message{Header: testHeader(), Data: []byte("hello")}
+
+	b.Run("binary", func(b *testing.B) {
+		codec := NewDefaultCodec()
+		frame, _ := codec.Encode(message)
+		b.ReportMetric(float64(len(frame)), "frame-bytes")
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			codec.Encode(message)
+		}
+	})
+	b.Run("gob", func(b *testing.B) {
+		frame, _ := legacyFrame(message)
+		b.ReportMetric(float64(len(frame)), "frame-bytes")
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			legacyFrame(message)
+		}
+	})
+}
+
+func BenchmarkDecode(b *testing.B) {
+	message := &Message{Header: testHeader(), Data: []byte("hello")}
+	codec := NewDefaultCodec()
+
+	b.Run("binary", func(b *testing.B) {
+		frame, _ := codec.Encode(message)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			codec.Decode(frame)
+		}
+	})
+	b.Run("gob", func(b *testing.B) {
+		frame, _ := legacyFrame(message)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			codec.Decode(frame)
+		}
+	})
+}
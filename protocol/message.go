@@ -10,8 +10,26 @@ const (
 	TypeRequest MessageType = iota
 	// 响应消息类型
 	TypeResponse
-	// 心跳消息类型
+	// 心跳消息类型, 消息体第一个字节为 HeartbeatPing 或 HeartbeatPong, pong 的消息ID与对应的 ping 相同
 	TypeHeartbeat
+	// 取消消息类型, 通知服务端取消指定ID的请求
+	TypeCancel
+	// 关闭通知消息类型, 服务端关闭前通知客户端不再在该连接上发送新请求
+	TypeGoAway
+	// 建立流消息类型, 携带服务名、方法名和元数据, 不携带消息体
+	TypeStreamOpen
+	// 流数据消息类型, 每条消息体是一个序列化后的流消息
+	TypeStreamData
+	// 关闭流消息类型, 发送方不再发送数据; 服务端发送时携带调用结果
+	TypeStreamClose
+	// 流控消息类型, 消息体为大端序 uint32, 表示对端可以继续发送的消息数
+	TypeStreamWindow
+)
+
+// 心跳消息的消息体
+const (
+	HeartbeatPing byte = iota
+	HeartbeatPong
 )
 
 // Message RPC消息结构
@@ -26,6 +44,8 @@ type Message struct {
 type Header struct {
 	// 消息ID
 	ID uint64
+	// 流ID, 仅流消息使用; 取消消息携带流ID时表示取消该流
+	StreamID uint64
 	// 消息类型
 	Type MessageType
 	// 压缩类型
@@ -42,4 +62,10 @@ type Header struct {
 	Timeout time.Duration
 	// 错误信息
 	Error string
+	// 错误码, 取值参见 status.Code
+	Code uint32
+	// 错误原因, 标识服务端的预定义错误
+	Reason string
+	// 错误详情
+	Details map[string]string
 }
\ No newline at end of file
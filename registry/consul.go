@@ -45,11 +45,13 @@ func (r *ConsulRegistry) Register(instance *ServiceInstance) error {
 		Port:    r.getPort(instance.Endpoints[0]),
 		Address: r.getHost(instance.Endpoints[0]),
 		Meta:    instance.Metadata,
-		Check: &api.AgentServiceCheck{
+	}
+	if instance.HealthCheck != nil && instance.HealthCheck.URL != "" {
+		registration.Check = &api.AgentServiceCheck{
 			HTTP:     instance.HealthCheck.URL,
 			Interval: instance.HealthCheck.Interval.String(),
 			Timeout:  instance.HealthCheck.Timeout.String(),
-		},
+		}
 	}
 
 	if err := r.client.Agent().ServiceRegister(registration); err != nil {
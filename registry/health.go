@@ -11,6 +11,13 @@ type RegistryNotifier interface {
     NotifyStatusChange(serviceName string, instance *ServiceInstance)
 }
 
+// instanceGuard 注册中心实现该接口时, 健康检查在注册中心的锁内读取和更新实例,
+// 避免与注册、心跳以及读取实例状态的调用方产生数据竞争
+type instanceGuard interface {
+    snapshot(instance *ServiceInstance) ServiceInstance
+    setStatus(instance *ServiceInstance, status ServiceStatus) bool
+}
+
 type HealthChecker struct {
     notifier    RegistryNotifier
     stopCh      chan struct{}
@@ -47,6 +54,11 @@ func (h *HealthChecker) AddInstance(instance *ServiceInstance) {
         }
     }
 
+    // 重复注册时停止旧的检查任务
+    if old, ok := h.checkTasks[instance.ID]; ok {
+        close(old.stopCh)
+    }
+
     task := &checkTask{
         instance: instance,
         stopCh:   make(chan struct{}),
@@ -72,9 +84,7 @@ func (h *HealthChecker) runCheck(task *checkTask) {
     for {
         select {
         case <-ticker.C:
-            status := h.check(task.instance)
-            if status != task.instance.Status {
-                task.instance.Status = status
+            if h.update(task.instance) {
                 h.notifier.NotifyStatusChange(task.instance.Name, task.instance)
             }
         case <-task.stopCh:
@@ -85,6 +95,21 @@ func (h *HealthChecker) runCheck(task *checkTask) {
     }
 }
 
+// update 检查实例并更新状态, 状态变化时返回 true
+func (h *HealthChecker) update(instance *ServiceInstance) bool {
+    guard, ok := h.notifier.(instanceGuard)
+    if !ok {
+        status := h.check(instance)
+        if status == instance.Status {
+            return false
+        }
+        instance.Status = status
+        return true
+    }
+    snapshot := guard.snapshot(instance)
+    return guard.setStatus(instance, h.check(&snapshot))
+}
+
 func (h *HealthChecker) check(instance *ServiceInstance) ServiceStatus {
     if instance.HealthCheck.URL == "" {
         // 如果没有配置健康检查URL，使用最后心跳时间判断
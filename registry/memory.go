@@ -24,9 +24,29 @@ func NewInMemoryRegistry() *MemoryRegistry {
 
 // 实现 RegistryNotifier 接口
 func (r *MemoryRegistry) NotifyStatusChange(serviceName string, instance *ServiceInstance) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	r.notifySubscribers(serviceName)
 }
 
+// snapshot 在锁内复制实例, 供健康检查读取心跳时间
+func (r *MemoryRegistry) snapshot(instance *ServiceInstance) ServiceInstance {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return *instance
+}
+
+// setStatus 在锁内更新实例的健康状态, 状态变化时返回 true
+func (r *MemoryRegistry) setStatus(instance *ServiceInstance, status ServiceStatus) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if instance.Status == status {
+		return false
+	}
+	instance.Status = status
+	return true
+}
+
 func (r *MemoryRegistry) Register(instance *ServiceInstance) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -63,7 +83,26 @@ func (r *MemoryRegistry) Deregister(instanceID string) error {
 			}
 		}
 	}
-	return ErrServiceNotFound
+	return ErrInstanceNotFound
+}
+
+func (r *MemoryRegistry) Heartbeat(instanceID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name, instances := range r.services {
+		for _, inst := range instances {
+			if inst.ID == instanceID {
+				inst.LastHeartbeat = time.Now()
+				if inst.Status != StatusUp {
+					inst.Status = StatusUp
+					r.notifySubscribers(name)
+				}
+				return nil
+			}
+		}
+	}
+	return ErrInstanceNotFound
 }
 
 func (r *MemoryRegistry) GetService(name string) ([]*ServiceInstance, error) {
@@ -81,9 +120,13 @@ func (r *MemoryRegistry) ListServices() ([]*ServiceInstance, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
+	// 返回实例的副本, 调用方读取状态和心跳时间时不与健康检查和心跳产生数据竞争
 	var result []*ServiceInstance
 	for _, instances := range r.services {
-		result = append(result, instances...)
+		for _, inst := range instances {
+			snapshot := *inst
+			result = append(result, &snapshot)
+		}
 	}
 	return result, nil
 }
@@ -182,6 +182,27 @@ func (s *RegistryTestSuite) TestDeregister() {
 	})
 }
 
+func (s *RegistryTestSuite) TestHeartbeat() {
+	instance := &ServiceInstance{
+		ID:        "instance-1",
+		Name:      "test-service",
+		Endpoints: []string{"localhost:8080"},
+		Status:    StatusDown,
+	}
+	s.NoError(s.registry.Register(instance))
+	before := instance.LastHeartbeat
+
+	time.Sleep(10 * time.Millisecond)
+	s.NoError(s.registry.Heartbeat(instance.ID))
+
+	services, err := s.registry.GetService(instance.Name)
+	s.NoError(err)
+	s.True(services[0].LastHeartbeat.After(before))
+	s.Equal(StatusUp, services[0].Status)
+
+	s.Equal(ErrInstanceNotFound, s.registry.Heartbeat("non-exist"))
+}
+
 func TestRegistrySuite(t *testing.T) {
 	suite.Run(t, new(RegistryTestSuite))
 }
@@ -43,7 +43,7 @@ type Registry interface {
 	// GetService 获取服务实例列表
 	GetService(name string) ([]*ServiceInstance, error)
 	
-	// ListServices 获取所有服务
+	// ListServices 获取所有服务, 返回的实例是调用时的快照, 可以在不持有注册中心锁的情况下读取
 	ListServices() ([]*ServiceInstance, error)
 	
 	// Subscribe 订阅服务变更
@@ -56,3 +56,9 @@ type Registry interface {
 	SelectInstance(serviceName string, balancer LoadBalancer) (*ServiceInstance, error)
 }
 
+// Heartbeater 支持实例续约的注册中心
+// 服务端定期调用 Heartbeat 刷新实例的最后心跳时间, 避免实例被健康检查判定为下线
+// 不支持续约的注册中心由服务端在每个续约周期重新注册实例, ConsulRegistry 依赖 Consul 自身的健康检查, 不实现该接口
+type Heartbeater interface {
+	Heartbeat(instanceID string) error
+}
@@ -2,19 +2,18 @@ package server
 
 import (
 	"bytes"
-	"encoding/gob"
+
+	"github.com/eason-lee/l-rpc/codec"
 )
 
-func encode(v interface{}) ([]byte, error) {
+func encode(cc codec.Codec, v interface{}) ([]byte, error) {
 	var buf bytes.Buffer
-	encoder := gob.NewEncoder(&buf)
-	if err := encoder.Encode(v); err != nil {
+	if err := cc.Encode(&buf, v); err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
 }
 
-func decode(data []byte, v interface{}) error {
-	decoder := gob.NewDecoder(bytes.NewReader(data))
-	return decoder.Decode(v)
-}
\ No newline at end of file
+func decode(cc codec.Codec, data []byte, v interface{}) error {
+	return cc.Decode(bytes.NewReader(data), v)
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/eason-lee/l-rpc/protocol"
+	"github.com/eason-lee/l-rpc/status"
+	"github.com/eason-lee/l-rpc/transport"
+)
+
+// WithCompressor 开启压缩, types 为偏好顺序
+// 只压缩声明了可以解压的算法的客户端的响应; 只接受使用开启的算法压缩的请求, 其他算法返回 Unimplemented
+func WithCompressor(types ...transport.CompressType) Option {
+	return func(s *Server) {
+		s.compressors = types
+	}
+}
+
+// WithCompressMinSize 设置最小压缩大小, 更小的响应不压缩, 默认 transport.DefaultCompressMinSize
+func WithCompressMinSize(size int) Option {
+	return func(s *Server) {
+		s.compressMinSize = size
+	}
+}
+
+// decompressRequest 按请求头记录的算法解压请求, 解压后的长度不能超过连接上允许的消息体最大长度
+func (s *Server) decompressRequest(req *protocol.Message) error {
+	if t := transport.CompressType(req.Header.Compress); t != transport.CompressNone && !slices.Contains(s.compressors, t) {
+		return fmt.Errorf("%w: %s", transport.ErrUnsupportedCompress, t)
+	}
+	err := transport.DecompressMessage(req, s.maxBodySize)
+	if err == nil || errors.Is(err, transport.ErrUnsupportedCompress) {
+		return err
+	}
+	return status.Errorf(status.InvalidArgument, "decompress request: %v", err)
+}
+
+// compressResponse 向声明了可以解压的算法的客户端回复服务端开启的算法, 并按协商的算法压缩响应
+func (s *Server) compressResponse(req, resp *protocol.Message) error {
+	accept := req.Header.Metadata[transport.AcceptCompressKey]
+	if accept == "" || len(s.compressors) == 0 {
+		return nil
+	}
+
+	resp.Header.Metadata = map[string]string{
+		transport.AcceptCompressKey: transport.FormatCompressTypes(s.compressors),
+	}
+	t := transport.NegotiateCompress(s.compressors, transport.ParseCompressTypes(accept))
+	return transport.CompressMessage(resp, t, s.compressMinSize)
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/eason-lee/l-rpc/protocol"
+	"github.com/eason-lee/l-rpc/status"
+	"github.com/eason-lee/l-rpc/transport"
+	"github.com/stretchr/testify/suite"
+)
+
+type CompressTestSuite struct {
+	suite.Suite
+}
+
+func (s *CompressTestSuite) request(t transport.CompressType, size int) *protocol.Message {
+	msg := &protocol.Message{Header: &protocol.Header{}, Data: bytes.Repeat([]byte("a"), size)}
+	s.Require().NoError(transport.CompressMessage(msg, t, 0))
+	s.Require().Equal(uint8(t), msg.Header.Compress)
+	return msg
+}
+
+func (s *CompressTestSuite) TestDecompressRequest() {
+	srv := NewServer(
+		WithCompressor(transport.CompressGzip),
+		WithTransportOptions(transport.WithMaxFrameSize(0, 1024)),
+	)
+
+	req := s.request(transport.CompressGzip, 1024)
+	s.Require().NoError(srv.decompressRequest(req))
+	s.Len(req.Data, 1024)
+
+	// 解压后超过连接上允许的消息体最大长度
+	err := srv.decompressRequest(s.request(transport.CompressGzip, 1025))
+	s.Equal(status.InvalidArgument, status.CodeOf(err))
+
+	// 服务端没有开启的算法
+	err = srv.decompressRequest(s.request(transport.CompressZstd, 1024))
+	s.ErrorIs(err, transport.ErrUnsupportedCompress)
+	s.Equal(status.Unimplemented, status.CodeOf(err))
+}
+
+func TestCompressSuite(t *testing.T) {
+	suite.Run(t, new(CompressTestSuite))
+}
@@ -1,9 +1,72 @@
 package server
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/eason-lee/l-rpc/codec"
+	"github.com/eason-lee/l-rpc/status"
+)
 
 var (
 	ErrNoAvailableMethods = errors.New("no available methods")
 	ErrServiceNotFound    = errors.New("service not found")
 	ErrMethodNotFound     = errors.New("method not found")
-)
\ No newline at end of file
+	ErrServerClosed       = errors.New("server closed")
+	ErrServiceExists      = errors.New("service already registered")
+	ErrInvalidServiceName = errors.New("invalid service name")
+	ErrCodecNotAllowed    = errors.New("codec not allowed by service")
+	ErrStreamMismatch     = errors.New("method call type mismatch")
+	ErrNilReply           = errors.New("handler returned nil reply")
+
+	// 限流拒绝的请求没有被执行, 客户端可以退避后重试
+	ErrRateLimited        = errors.New("rate limit exceeded")
+	ErrConcurrencyLimited = errors.New("concurrency limit exceeded")
+	ErrOverloaded         = errors.New("server overloaded")
+)
+
+func init() {
+	status.RegisterError(ErrServiceNotFound, status.NotFound, "SERVICE_NOT_FOUND")
+	status.RegisterError(ErrMethodNotFound, status.NotFound, "METHOD_NOT_FOUND")
+	status.RegisterError(ErrServerClosed, status.Unavailable, "SERVER_CLOSED")
+	status.RegisterError(ErrCodecNotAllowed, status.InvalidArgument, "CODEC_NOT_ALLOWED")
+	status.RegisterError(ErrStreamMismatch, status.Unimplemented, "STREAM_MISMATCH")
+	status.RegisterError(ErrNilReply, status.Internal, "NIL_REPLY")
+	status.RegisterError(ErrRateLimited, status.ResourceExhausted, "RATE_LIMITED")
+	status.RegisterError(ErrConcurrencyLimited, status.ResourceExhausted, "CONCURRENCY_LIMITED")
+	status.RegisterError(ErrOverloaded, status.ResourceExhausted, "OVERLOADED")
+	status.RegisterError(codec.ErrUnsupportedCodec, status.InvalidArgument, "UNSUPPORTED_CODEC")
+}
+
+// MethodError 方法不满足 RPC 方法签名的原因
+type MethodError struct {
+	Method string
+	Reason string
+}
+
+func (e *MethodError) Error() string {
+	return fmt.Sprintf("method %s %s", e.Method, e.Reason)
+}
+
+// RegisterError 服务中没有可用的方法, 列出每个方法被跳过的原因
+type RegisterError struct {
+	Service string
+	Skipped []*MethodError
+}
+
+func (e *RegisterError) Error() string {
+	reasons := make([]string, 0, len(e.Skipped))
+	for _, m := range e.Skipped {
+		reasons = append(reasons, m.Error())
+	}
+	msg := fmt.Sprintf("%s: service %s", ErrNoAvailableMethods, e.Service)
+	if len(reasons) > 0 {
+		msg += ": " + strings.Join(reasons, "; ")
+	}
+	return msg
+}
+
+func (e *RegisterError) Unwrap() error {
+	return ErrNoAvailableMethods
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"context"
+
+	"github.com/eason-lee/l-rpc/protocol"
+	"github.com/eason-lee/l-rpc/status"
+)
+
+// UnaryHandler 处理一次调用, 返回方法的响应
+type UnaryHandler func(ctx context.Context, args interface{}) (interface{}, error)
+
+// UnaryInterceptor 服务端拦截器
+// header 中包含服务名、方法名和元数据, 调用 next 继续执行后续拦截器和方法,
+// 不调用 next 直接返回错误即可中断本次调用
+type UnaryInterceptor func(ctx context.Context, header *protocol.Header, args interface{}, next UnaryHandler) (interface{}, error)
+
+// Use 添加拦截器, 按添加顺序由外向内执行, 需要在 Start 之前调用
+func (s *Server) Use(interceptors ...UnaryInterceptor) {
+	s.interceptors = append(s.interceptors, interceptors...)
+}
+
+// chainInterceptors 将拦截器与最终的处理函数组合成调用链
+func chainInterceptors(interceptors []UnaryInterceptor, header *protocol.Header, final UnaryHandler) UnaryHandler {
+	handler := final
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, args interface{}) (interface{}, error) {
+			return interceptor(ctx, header, args, next)
+		}
+	}
+	return handler
+}
+
+// Recovery 捕获方法执行过程中的 panic 并转换为错误返回给调用方
+func Recovery() UnaryInterceptor {
+	return func(ctx context.Context, header *protocol.Header, args interface{}, next UnaryHandler) (reply interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = status.Errorf(status.Internal, "panic in %s.%s: %v", header.ServiceName, header.MethodName, r)
+			}
+		}()
+		return next(ctx, args)
+	}
+}
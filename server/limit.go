@@ -0,0 +1,184 @@
+package server
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/eason-lee/l-rpc/limiter"
+	"github.com/eason-lee/l-rpc/protocol"
+)
+
+// LimitRule 限流规则
+// 请求依次经过所有匹配的规则, 任一规则拒绝即返回 ResourceExhausted 错误
+type LimitRule struct {
+	// 规则作用的服务, 为空时匹配所有服务
+	Service string
+	// 规则作用的方法, 为空时匹配服务的所有方法
+	Method string
+	// 不为空时按请求元数据中该键的值(如调用方标识)分别限流
+	MetadataKey string
+	// 按元数据值限流时最多保留的限流器数量, 超出后淘汰最久未使用的空闲限流器;
+	// 为 0 时使用 DefaultMaxLimitKeys
+	MaxKeys int
+	// 每秒允许的请求数和允许的突发请求数, Rate 为 0 时不限制
+	Rate  float64
+	Burst int
+	// 最大并发数, 为 0 时不限制
+	MaxConcurrency int
+}
+
+// DefaultMaxLimitKeys 每条规则默认最多保留的按元数据值区分的限流器数量
+const DefaultMaxLimitKeys = 10000
+
+// WithLimit 添加限流规则
+func WithLimit(rules ...LimitRule) Option {
+	return func(s *Server) {
+		for _, rule := range rules {
+			if rule.MaxKeys <= 0 {
+				rule.MaxKeys = DefaultMaxLimitKeys
+			}
+			s.limits = append(s.limits, &limitRule{
+				LimitRule: rule,
+				keys:      make(map[string]*list.Element),
+				lru:       list.New(),
+			})
+		}
+	}
+}
+
+// WithAdaptiveLimit 开启自适应限流, 根据请求延迟调整整个服务端的并发上限
+func WithAdaptiveLimit(config limiter.AdaptiveConfig) Option {
+	return func(s *Server) {
+		s.adaptive = limiter.NewAdaptive(config)
+	}
+}
+
+// limitRule 限流规则及其按元数据值区分的限流器
+// 元数据值由客户端决定, 限流器按最近使用的顺序保存, 数量超过 MaxKeys 时淘汰最久未使用的
+type limitRule struct {
+	LimitRule
+
+	mu   sync.Mutex
+	keys map[string]*list.Element // 元数据值 -> lru 中的 *keyLimiters
+	lru  *list.List               // 最近使用的在前
+}
+
+// keyLimiters 一个元数据值对应的限流器, 未配置的限流器为 nil
+type keyLimiters struct {
+	key         string
+	bucket      *limiter.TokenBucket
+	concurrency *limiter.Concurrency
+}
+
+func (r *limitRule) match(header *protocol.Header) bool {
+	return (r.Service == "" || r.Service == header.ServiceName) &&
+		(r.Method == "" || r.Method == header.MethodName)
+}
+
+// allow 按规则检查请求, 通过时返回消耗了令牌的令牌桶和获取了名额的并发限制器, 未配置的限流器为 nil
+// 在持有锁时获取并发名额, 获取之前限流器不会被 evict 淘汰, 同一元数据值始终使用同一个并发限制器
+func (r *limitRule) allow(header *protocol.Header) (*limiter.TokenBucket, *limiter.Concurrency, error) {
+	var key string
+	if r.MetadataKey != "" {
+		key = header.Metadata[r.MetadataKey]
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l := r.limiters(key)
+	if l.bucket != nil && !l.bucket.Allow() {
+		return nil, nil, ErrRateLimited
+	}
+	if l.concurrency != nil && !l.concurrency.Acquire() {
+		if l.bucket != nil {
+			l.bucket.Return()
+		}
+		return nil, nil, ErrConcurrencyLimited
+	}
+	return l.bucket, l.concurrency, nil
+}
+
+// limiters 返回元数据值对应的限流器, 不存在时创建, 调用方需要持有锁
+func (r *limitRule) limiters(key string) *keyLimiters {
+	if e, ok := r.keys[key]; ok {
+		r.lru.MoveToFront(e)
+		return e.Value.(*keyLimiters)
+	}
+
+	r.evict()
+	l := &keyLimiters{key: key}
+	if r.Rate > 0 {
+		l.bucket = limiter.NewTokenBucket(r.Rate, r.Burst)
+	}
+	if r.MaxConcurrency > 0 {
+		l.concurrency = limiter.NewConcurrency(r.MaxConcurrency)
+	}
+	r.keys[key] = r.lru.PushFront(l)
+	return l
+}
+
+// evict 限流器数量达到上限时从最久未使用的开始淘汰, 调用方需要持有锁
+// 仍有请求在执行的限流器不淘汰, 避免同一元数据值的并发数限制被重置
+func (r *limitRule) evict() {
+	for e := r.lru.Back(); e != nil && len(r.keys) >= r.MaxKeys; {
+		prev := e.Prev()
+		l := e.Value.(*keyLimiters)
+		if l.concurrency == nil || l.concurrency.Inflight() == 0 {
+			r.lru.Remove(e)
+			delete(r.keys, l.key)
+		}
+		e = prev
+	}
+}
+
+// acquire 按限流规则检查请求, 通过时返回请求结束后需要调用的 release
+// 流的持续时间不代表处理延迟, 只有 adaptive 为 true 的普通请求参与自适应限流
+func (s *Server) acquire(header *protocol.Header, adaptive bool) (release func(), err error) {
+	var (
+		consumed []*limiter.TokenBucket
+		acquired []*limiter.Concurrency
+	)
+	releaseAll := func() {
+		for _, c := range acquired {
+			c.Release()
+		}
+	}
+	// 被之后的规则拒绝的请求没有被执行, 归还之前的规则消耗的令牌
+	reject := func(err error) (func(), error) {
+		for _, b := range consumed {
+			b.Return()
+		}
+		releaseAll()
+		return nil, err
+	}
+
+	for _, rule := range s.limits {
+		if !rule.match(header) {
+			continue
+		}
+		bucket, concurrency, err := rule.allow(header)
+		if err != nil {
+			return reject(err)
+		}
+		if bucket != nil {
+			consumed = append(consumed, bucket)
+		}
+		if concurrency != nil {
+			acquired = append(acquired, concurrency)
+		}
+	}
+
+	if s.adaptive == nil || !adaptive {
+		return releaseAll, nil
+	}
+	if !s.adaptive.Acquire() {
+		return reject(ErrOverloaded)
+	}
+	start := time.Now()
+	return func() {
+		s.adaptive.Release(time.Since(start))
+		releaseAll()
+	}, nil
+}
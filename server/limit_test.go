@@ -0,0 +1,109 @@
+package server
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/eason-lee/l-rpc/protocol"
+	"github.com/stretchr/testify/suite"
+)
+
+type LimitTestSuite struct {
+	suite.Suite
+}
+
+func (s *LimitTestSuite) header(caller string) *protocol.Header {
+	return &protocol.Header{
+		ServiceName: "Arith",
+		MethodName:  "Add",
+		Metadata:    map[string]string{"caller": caller},
+	}
+}
+
+func (s *LimitTestSuite) TestRefundOnReject() {
+	srv := NewServer(WithLimit(
+		LimitRule{Service: "Arith", Rate: 0.001, Burst: 2},
+		LimitRule{Service: "Arith", Method: "Add", MaxConcurrency: 1},
+	))
+
+	release, err := srv.acquire(s.header(""), false)
+	s.Require().NoError(err)
+
+	// 被第二条规则拒绝的请求归还第一条规则的令牌, 多次拒绝不会耗尽令牌
+	for i := 0; i < 3; i++ {
+		r, err := srv.acquire(s.header(""), false)
+		s.Nil(r)
+		s.Equal(ErrConcurrencyLimited, err)
+	}
+
+	release()
+	release, err = srv.acquire(s.header(""), false)
+	s.Require().NoError(err)
+	release()
+	_, err = srv.acquire(s.header(""), false)
+	s.Equal(ErrRateLimited, err)
+}
+
+func (s *LimitTestSuite) TestEvictKeys() {
+	srv := NewServer(WithLimit(LimitRule{MetadataKey: "caller", Rate: 0.001, Burst: 1, MaxConcurrency: 1, MaxKeys: 2}))
+	rule := srv.limits[0]
+
+	// 执行中的调用方不会被淘汰
+	release, err := srv.acquire(s.header("busy"), false)
+	s.Require().NoError(err)
+	for i := 0; i < 10; i++ {
+		r, err := srv.acquire(s.header(fmt.Sprint(i)), false)
+		s.Require().NoError(err)
+		r()
+	}
+	s.Len(rule.keys, 2)
+	s.Contains(rule.keys, "busy")
+	s.Contains(rule.keys, "9")
+	_, err = srv.acquire(s.header("busy"), false)
+	s.Equal(ErrRateLimited, err)
+	release()
+
+	// 被淘汰的调用方重新创建限流器, 淘汰最久未使用的调用方
+	_, err = srv.acquire(s.header("0"), false)
+	s.NoError(err)
+	s.Len(rule.keys, 2)
+	s.Contains(rule.keys, "busy")
+	s.NotContains(rule.keys, "9")
+}
+
+func (s *LimitTestSuite) TestConcurrencyWithEviction() {
+	srv := NewServer(WithLimit(LimitRule{MetadataKey: "caller", MaxConcurrency: 1, MaxKeys: 1}))
+
+	// 不同调用方交替请求, 频繁淘汰限流器时同一调用方的并发数也不超过限制
+	var inflight [2]atomic.Int32
+	var exceeded atomic.Bool
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				caller := (g + i) % 2
+				release, err := srv.acquire(s.header(fmt.Sprint(caller)), false)
+				if err != nil {
+					continue
+				}
+				if inflight[caller].Add(1) > 1 {
+					exceeded.Store(true)
+				}
+				runtime.Gosched()
+				inflight[caller].Add(-1)
+				release()
+			}
+		}(g)
+	}
+	wg.Wait()
+	s.False(exceeded.Load())
+}
+
+func TestLimitSuite(t *testing.T) {
+	suite.Run(t, new(LimitTestSuite))
+}
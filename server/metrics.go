@@ -0,0 +1,30 @@
+package server
+
+import (
+	"github.com/eason-lee/l-rpc/metrics"
+	"github.com/eason-lee/l-rpc/protocol"
+)
+
+// unknownLabel 未注册的服务或方法在指标中使用的标签值
+const unknownLabel = "unknown"
+
+// WithMetrics 设置监控指标, 统计每个请求的次数、耗时、消息大小和错误码, 包括被限流和关闭过程中拒绝的请求
+// 指标需要注册到 metrics.Registry 后才会输出
+func WithMetrics(m *metrics.RPCMetrics) Option {
+	return func(s *Server) {
+		s.metrics = m
+	}
+}
+
+// metricLabels 返回请求在指标中的服务名和方法名
+// 服务名和方法名由客户端传入, 未注册的统一记为 unknown, 避免任意名称使指标无限增长
+func (s *Server) metricLabels(header *protocol.Header) (string, string) {
+	v, ok := s.serviceMap.Load(header.ServiceName)
+	if !ok {
+		return unknownLabel, unknownLabel
+	}
+	if v.(*Service).methods[header.MethodName] == nil {
+		return header.ServiceName, unknownLabel
+	}
+	return header.ServiceName, header.MethodName
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/transport"
+)
+
+// Option 服务端配置项
+type Option func(*Server)
+
+// WithRegistry 设置注册中心, 服务启动时自动为每个已注册的服务注册实例, 关闭时注销
+func WithRegistry(reg registry.Registry) Option {
+	return func(s *Server) {
+		s.registry = reg
+	}
+}
+
+// WithVersion 设置注册到注册中心的服务版本
+func WithVersion(version string) Option {
+	return func(s *Server) {
+		s.version = version
+	}
+}
+
+// WithMetadata 设置注册到注册中心的实例元数据
+func WithMetadata(md map[string]string) Option {
+	return func(s *Server) {
+		s.metadata = md
+	}
+}
+
+// WithAdvertiseAddr 设置注册到注册中心的访问地址, 默认使用监听地址
+func WithAdvertiseAddr(addr string) Option {
+	return func(s *Server) {
+		s.advertiseAddr = addr
+	}
+}
+
+// WithHeartbeatInterval 设置向注册中心续约的间隔
+func WithHeartbeatInterval(interval time.Duration) Option {
+	return func(s *Server) {
+		s.heartbeatInterval = interval
+	}
+}
+
+// WithTLS 只接受 TLS 连接, config 设置 ClientAuth 和 ClientCAs 后校验客户端证书 (mTLS)
+// 可以使用 transport.TLSConfig 根据证书文件创建, 证书文件修改后自动重新加载
+func WithTLS(config *tls.Config) Option {
+	return func(s *Server) {
+		s.transportOpts = append(s.transportOpts, transport.WithTLS(config))
+	}
+}
+
+// WithTransportOptions 设置监听时的传输层配置, 如 transport.WithEncryptor
+func WithTransportOptions(opts ...transport.Option) Option {
+	return func(s *Server) {
+		s.transportOpts = append(s.transportOpts, opts...)
+	}
+}
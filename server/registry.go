@@ -0,0 +1,160 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/eason-lee/l-rpc/registry"
+)
+
+const defaultHeartbeatInterval = 5 * time.Second
+
+// registerInstances 为所有已注册的服务向注册中心注册实例并开始续约
+func (s *Server) registerInstances(addr net.Addr) error {
+	if s.registry == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	s.endpoint = s.advertiseAddr
+	if s.endpoint == "" {
+		s.endpoint = advertiseAddr(addr)
+	}
+	s.heartbeatStop = make(chan struct{})
+	s.mu.Unlock()
+
+	var err error
+	s.serviceMap.Range(func(_, v interface{}) bool {
+		err = s.registerInstance(v.(*Service))
+		return err == nil
+	})
+	if err != nil {
+		s.deregisterInstances()
+		return err
+	}
+
+	go s.heartbeat()
+	return nil
+}
+
+// registerInstance 为单个服务注册实例, 服务尚未启动时不做处理
+func (s *Server) registerInstance(service *Service) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.registry == nil || s.endpoint == "" || s.shutdown {
+		return nil
+	}
+
+	version := service.version
+	if version == "" {
+		version = s.version
+	}
+	md := make(map[string]string, len(s.metadata)+len(service.metadata))
+	for k, v := range s.metadata {
+		md[k] = v
+	}
+	for k, v := range service.metadata {
+		md[k] = v
+	}
+
+	instance := &registry.ServiceInstance{
+		ID:        fmt.Sprintf("%s-%s", service.name, s.endpoint),
+		Name:      service.name,
+		Version:   version,
+		Metadata:  md,
+		Endpoints: []string{s.endpoint},
+		Status:    registry.StatusUp,
+	}
+	if err := s.registry.Register(instance); err != nil {
+		return err
+	}
+	s.instances[service.name] = instance
+	return nil
+}
+
+// deregisterInstances 从注册中心注销所有实例并停止续约
+func (s *Server) deregisterInstances() {
+	s.mu.Lock()
+	instances := s.instances
+	s.instances = make(map[string]*registry.ServiceInstance)
+	if s.heartbeatStop != nil {
+		close(s.heartbeatStop)
+		s.heartbeatStop = nil
+	}
+	s.mu.Unlock()
+
+	for _, instance := range instances {
+		s.registry.Deregister(instance.ID)
+	}
+}
+
+// heartbeat 定期刷新实例的最后心跳时间
+// 注册中心不支持续约或实例已丢失时重新注册实例
+func (s *Server) heartbeat() {
+	interval := s.heartbeatInterval
+	if interval <= 0 {
+		interval = defaultHeartbeatInterval
+	}
+
+	s.mu.Lock()
+	stop := s.heartbeatStop
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			instances := make([]*registry.ServiceInstance, 0, len(s.instances))
+			for _, instance := range s.instances {
+				instances = append(instances, instance)
+			}
+			s.mu.Unlock()
+
+			for _, instance := range instances {
+				if hb, ok := s.registry.(registry.Heartbeater); ok && hb.Heartbeat(instance.ID) == nil {
+					continue
+				}
+				s.reregisterInstance(instance)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// reregisterInstance 重新注册续约失败的实例
+// 与 deregisterInstances 使用同一把锁, 实例已被注销或服务已关闭时不再注册, 避免关闭后留下过期的实例
+func (s *Server) reregisterInstance(instance *registry.ServiceInstance) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shutdown || s.instances[instance.Name] != instance {
+		return
+	}
+	s.registry.Register(instance)
+}
+
+// advertiseAddr 根据监听地址生成对外访问地址
+// 监听在未指定的地址(如 ":8080")时使用本机的第一个非回环 IPv4 地址
+func advertiseAddr(addr net.Addr) string {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok || !tcpAddr.IP.IsUnspecified() {
+		return addr.String()
+	}
+
+	host := "127.0.0.1"
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, a := range addrs {
+			if ipNet, ok := a.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+				host = ipNet.IP.String()
+				break
+			}
+		}
+	}
+	return net.JoinHostPort(host, fmt.Sprint(tcpAddr.Port))
+}
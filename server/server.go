@@ -2,181 +2,337 @@ package server
 
 import (
 	"context"
+	"fmt"
 	"reflect"
 	"sync"
+	"time"
 
+	"github.com/eason-lee/l-rpc/codec"
+	"github.com/eason-lee/l-rpc/limiter"
+	"github.com/eason-lee/l-rpc/metadata"
+	"github.com/eason-lee/l-rpc/metrics"
+	"github.com/eason-lee/l-rpc/peer"
 	"github.com/eason-lee/l-rpc/protocol"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/status"
+	"github.com/eason-lee/l-rpc/trace"
 	"github.com/eason-lee/l-rpc/transport"
 )
 
-// Service 表示一个服务
-type Service struct {
-	name     string
-	rcvr     reflect.Value
-	typ      reflect.Type
-	methods  map[string]*MethodType
-}
-
-// MethodType 表示一个方法
-type MethodType struct {
-	method    reflect.Method
-	ArgType   reflect.Type
-	ReplyType reflect.Type
-}
-
 // Server RPC 服务端
 type Server struct {
-	serviceMap sync.Map
-	transport  transport.Transport
-}
+	serviceMap   sync.Map
+	interceptors []UnaryInterceptor
 
-func NewServer() *Server {
-	return &Server{}
-}
+	// 限流
+	limits   []*limitRule
+	adaptive *limiter.Adaptive
 
-// Register 注册服务
-func (s *Server) Register(rcvr interface{}) error {
-	service := new(Service)
-	service.rcvr = reflect.ValueOf(rcvr)
-	service.typ = reflect.TypeOf(rcvr)
-	service.name = reflect.Indirect(service.rcvr).Type().Name()
-	service.methods = make(map[string]*MethodType)
-
-	// 注册方法
-	for i := 0; i < service.typ.NumMethod(); i++ {
-		method := service.typ.Method(i)
-		mtype := method.Type
-
-		// 方法必须是导出的
-		if method.PkgPath != "" {
-			continue
-		}
+	// 链路追踪和监控指标
+	tracer  *trace.Tracer
+	metrics *metrics.RPCMetrics
 
-		// 方法必须有三个入参: receiver, *args, *reply
-		if mtype.NumIn() != 3 {
-			continue
-		}
+	// 监听时的传输层配置
+	transportOpts []transport.Option
 
-		// 方法必须有一个出参: error
-		if mtype.NumOut() != 1 {
-			continue
-		}
+	// 响应压缩
+	compressors     []transport.CompressType
+	compressMinSize int
+	maxBodySize     int // 连接上允许接收的消息体最大长度, 同时限制解压后的请求
 
-		// 第一个参数必须是 context.Context
-		if !mtype.In(1).Implements(reflect.TypeOf((*context.Context)(nil)).Elem()) {
-			continue
-		}
+	// 服务注册
+	registry          registry.Registry
+	version           string
+	metadata          map[string]string
+	advertiseAddr     string
+	heartbeatInterval time.Duration
 
-		argType := mtype.In(1)
-		replyType := mtype.In(2)
+	mu            sync.Mutex
+	listener      *transport.Server
+	conns         map[transport.Transport]struct{}
+	inflight      sync.WaitGroup // 正在执行的请求
+	shutdown      bool
+	endpoint      string                               // 注册到注册中心的访问地址
+	instances     map[string]*registry.ServiceInstance // 服务名 -> 已注册的实例
+	heartbeatStop chan struct{}
+}
 
-		service.methods[method.Name] = &MethodType{
-			method:    method,
-			ArgType:   argType,
-			ReplyType: replyType,
-		}
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		conns:           make(map[transport.Transport]struct{}),
+		instances:       make(map[string]*registry.ServiceInstance),
+		compressMinSize: transport.DefaultCompressMinSize,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.maxBodySize = transport.MaxBodySize(s.transportOpts...)
+	return s
+}
+
+// Register 注册服务, 服务名为接收者的类型名
+// 服务中没有任何满足签名要求的方法时返回 *RegisterError, 其中列出了每个方法被跳过的原因
+func (s *Server) Register(rcvr interface{}, opts ...ServiceOption) error {
+	return s.RegisterName(reflect.Indirect(reflect.ValueOf(rcvr)).Type().Name(), rcvr, opts...)
+}
+
+// RegisterName 以指定的服务名注册服务, 服务名已存在时返回 ErrServiceExists
+func (s *Server) RegisterName(name string, rcvr interface{}, opts ...ServiceOption) error {
+	service, err := newService(name, rcvr, opts...)
+	if err != nil {
+		return err
 	}
 
-	if len(service.methods) == 0 {
-		return ErrNoAvailableMethods
+	if _, loaded := s.serviceMap.LoadOrStore(service.name, service); loaded {
+		return fmt.Errorf("%w: %s", ErrServiceExists, service.name)
 	}
 
-	s.serviceMap.Store(service.name, service)
-	return nil
+	// 服务已启动时立即注册实例
+	return s.registerInstance(service)
 }
 
 // Start 启动服务
+// 调用 Shutdown 或 Close 后返回 ErrServerClosed
 func (s *Server) Start(addr string) error {
-	server, err := transport.NewServer(addr)
+	server, err := transport.NewServer(addr, s.transportOpts...)
 	if err != nil {
 		return err
 	}
 
-	return server.Accept(s.handleRequest)
+	s.mu.Lock()
+	if s.shutdown {
+		s.mu.Unlock()
+		server.Close()
+		return ErrServerClosed
+	}
+	s.listener = server
+	s.mu.Unlock()
+
+	if err := s.registerInstances(server.Addr()); err != nil {
+		server.Close()
+		return err
+	}
+
+	err = server.Accept(s.handleRequest)
+	if s.isShutdown() {
+		return ErrServerClosed
+	}
+	return err
 }
 
 // handleRequest 处理请求
 func (s *Server) handleRequest(trans transport.Transport) {
+	if !s.trackConn(trans, true) {
+		return
+	}
+	defer s.trackConn(trans, false)
+
+	// 连接断开时取消该连接上所有正在执行的请求
+	connCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// 处理函数可以通过 peer.FromContext 获取客户端地址和证书
+	if p, ok := trans.(interface{ Peer() *peer.Peer }); ok {
+		connCtx = peer.NewContext(connCtx, p.Peer())
+	}
+
+	// 正在执行的请求, 请求ID -> context.CancelFunc
+	var cancels sync.Map
+	// 正在执行的流, 流ID -> *serverStream
+	var streams sync.Map
+
 	for {
-		// 接收请求
-		data, err := trans.Receive()
+		// 接收并解码请求
+		msg, err := trans.ReadMessage()
 		if err != nil {
 			return
 		}
 
-		// 解码请求
-		codec := protocol.NewDefaultCodec()
-		msg, err := codec.Decode(data)
-		if err != nil {
-			return
+		switch msg.Header.Type {
+		case protocol.TypeRequest:
+			// 关闭过程中拒绝新请求, 客户端可以切换到其他实例重试
+			if !s.beginRequest() {
+				s.rejectRequest(msg, ErrServerClosed, trans)
+				continue
+			}
+			// 限流在读协程中进行, 被拒绝的请求不会创建处理协程
+			release, err := s.acquire(msg.Header, true)
+			if err != nil {
+				s.inflight.Done()
+				s.rejectRequest(msg, err, trans)
+				continue
+			}
+
+			ctx, cancel := requestContext(connCtx, msg.Header)
+			cancels.Store(msg.Header.ID, cancel)
+
+			// 处理请求
+			go func() {
+				defer s.inflight.Done()
+				defer release()
+				defer cancels.Delete(msg.Header.ID)
+				defer cancel()
+				s.processRequest(ctx, msg, trans)
+			}()
+		case protocol.TypeStreamOpen:
+			s.openStream(connCtx, msg.Header, trans, &streams)
+		case protocol.TypeStreamData, protocol.TypeStreamClose, protocol.TypeStreamWindow:
+			// 流可能已经结束
+			if ss, ok := streams.Load(msg.Header.StreamID); ok {
+				if err := ss.(*serverStream).core.Deliver(msg); err != nil {
+					// 客户端不遵守流控时取消流式方法, 流以中止状态结束
+					ss.(*serverStream).cancel()
+				}
+			}
+		case protocol.TypeHeartbeat:
+			// 回复客户端的 ping, 或将 pong 交给服务端的心跳协程
+			if h, ok := trans.(interface {
+				HandleHeartbeat(*protocol.Message) error
+			}); ok {
+				if err := h.HandleHeartbeat(msg); err != nil {
+					return
+				}
+			}
+		case protocol.TypeCancel:
+			if msg.Header.StreamID != 0 {
+				if ss, ok := streams.Load(msg.Header.StreamID); ok {
+					ss.(*serverStream).cancel()
+				}
+				continue
+			}
+			if cancel, ok := cancels.LoadAndDelete(msg.Header.ID); ok {
+				cancel.(context.CancelFunc)()
+			}
 		}
+	}
+}
 
-		// 处理请求
-		go s.processRequest(msg, trans)
+// requestContext 根据请求头中的超时时间创建请求上下文
+func requestContext(parent context.Context, header *protocol.Header) (context.Context, context.CancelFunc) {
+	if header.Timeout > 0 {
+		return context.WithTimeout(parent, header.Timeout)
 	}
+	return context.WithCancel(parent)
 }
 
 // processRequest 处理单个请求
-func (s *Server) processRequest(req *protocol.Message, trans transport.Transport) {
+func (s *Server) processRequest(ctx context.Context, req *protocol.Message, trans transport.Transport) {
 	resp := &protocol.Message{
 		Header: &protocol.Header{
-			ID:   req.Header.ID,
-			Type: protocol.TypeResponse,
+			ID:    req.Header.ID,
+			Type:  protocol.TypeResponse,
+			Codec: req.Header.Codec,
 		},
 	}
 
-	svc, ok := s.serviceMap.Load(req.Header.ServiceName)
-	if !ok {
-		resp.Header.Error = ErrServiceNotFound.Error()
+	ctx, span := s.startSpan(ctx, req.Header)
+	defer endSpan(span, resp.Header)
+	// 按压缩后的大小统计
+	observe := s.metrics.Begin(s.metricLabels(req.Header))
+	reqSize := len(req.Data)
+	defer func() {
+		observe(status.FromHeader(resp.Header), reqSize, len(resp.Data))
+	}()
+
+	service, mtype, cc, err := s.lookupMethod(req.Header)
+	if err == nil && mtype.stream {
+		err = fmt.Errorf("%w: %s.%s is a streaming method", ErrStreamMismatch, req.Header.ServiceName, req.Header.MethodName)
+	}
+	if err != nil {
+		status.ToHeader(resp.Header, err)
 		s.sendResponse(resp, trans)
 		return
 	}
 
-	service := svc.(*Service)
-	mtype := service.methods[req.Header.MethodName]
-	if mtype == nil {
-		resp.Header.Error = ErrMethodNotFound.Error()
+	// 方法级别的超时时间
+	if timeout := service.timeouts[req.Header.MethodName]; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if err := s.decompressRequest(req); err != nil {
+		status.ToHeader(resp.Header, err)
 		s.sendResponse(resp, trans)
 		return
 	}
 
 	// 创建参数
-	argv := reflect.New(mtype.ArgType.Elem())
-	replyv := reflect.New(mtype.ReplyType.Elem())
+	argv := mtype.newArgs()
 
 	// 解码参数
-	if err := decode(req.Data, argv.Interface()); err != nil {
-		resp.Header.Error = err.Error()
+	if err := decode(cc, req.Data, argv.Interface()); err != nil {
+		status.ToHeader(resp.Header, status.Errorf(status.InvalidArgument, "decode args: %v", err))
 		s.sendResponse(resp, trans)
 		return
 	}
 
-	// 调用方法
-	ctx := context.Background()
-	returnValues := mtype.method.Func.Call([]reflect.Value{
-		service.rcvr,
-		reflect.ValueOf(ctx),
-		argv,
-		replyv,
-	})
+	// 将请求头中的元数据注入处理函数的上下文
+	ctx = metadata.NewIncomingContext(ctx, metadata.New(req.Header.Metadata))
 
-	// 处理返回值
-	if err := returnValues[0].Interface(); err != nil {
-		resp.Header.Error = err.(error).Error()
+	// 经过拦截器链调用方法
+	handler := func(ctx context.Context, args interface{}) (interface{}, error) {
+		return service.call(ctx, mtype, args)
+	}
+	reply, err := chainInterceptors(s.interceptors, req.Header, handler)(ctx, argv.Interface())
+	if err != nil {
+		status.ToHeader(resp.Header, err)
 		s.sendResponse(resp, trans)
 		return
 	}
 
 	// 编码响应
-	resp.Data, _ = encode(replyv.Interface())
+	data, err := encode(cc, reply)
+	if err != nil {
+		status.ToHeader(resp.Header, status.Errorf(status.Internal, "encode reply: %v", err))
+	}
+	resp.Data = data
+	if err := s.compressResponse(req, resp); err != nil {
+		status.ToHeader(resp.Header, status.Errorf(status.Internal, "compress reply: %v", err))
+		resp.Data = nil
+	}
 	s.sendResponse(resp, trans)
 }
 
-func (s *Server) sendResponse(resp *protocol.Message, trans transport.Transport) {
-	codec := protocol.NewDefaultCodec()
-	data, err := codec.Encode(resp)
+// lookupMethod 按请求头查找服务、方法和参数序列化方式
+func (s *Server) lookupMethod(header *protocol.Header) (*Service, *MethodType, codec.Codec, error) {
+	// 按请求头选择参数序列化方式
+	cc, err := codec.LookupCodec(header.Codec)
 	if err != nil {
-		return
+		return nil, nil, nil, err
+	}
+
+	svc, ok := s.serviceMap.Load(header.ServiceName)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("%w: %s", ErrServiceNotFound, header.ServiceName)
 	}
-	trans.Send(data)
+
+	service := svc.(*Service)
+	mtype := service.methods[header.MethodName]
+	if mtype == nil {
+		return nil, nil, nil, fmt.Errorf("%w: %s.%s", ErrMethodNotFound, header.ServiceName, header.MethodName)
+	}
+
+	if !service.allowCodec(cc.ContentType()) {
+		return nil, nil, nil, fmt.Errorf("%w: %s", ErrCodecNotAllowed, cc.ContentType())
+	}
+	return service, mtype, cc, nil
+}
+
+// rejectRequest 不执行请求, 直接返回错误
+func (s *Server) rejectRequest(req *protocol.Message, err error, trans transport.Transport) {
+	s.metrics.Begin(s.metricLabels(req.Header))(err, len(req.Data), 0)
+
+	resp := &protocol.Message{
+		Header: &protocol.Header{
+			ID:   req.Header.ID,
+			Type: protocol.TypeResponse,
+		},
+	}
+	status.ToHeader(resp.Header, err)
+	s.sendResponse(resp, trans)
+}
+
+func (s *Server) sendResponse(resp *protocol.Message, trans transport.Transport) {
+	// 响应可能乱序返回, 客户端按消息ID进行分发
+	trans.WriteMessage(resp)
 }
\ No newline at end of file
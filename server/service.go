@@ -0,0 +1,254 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"reflect"
+	"time"
+
+	"github.com/eason-lee/l-rpc/codec"
+)
+
+var (
+	typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
+	typeOfStream  = reflect.TypeOf((*Stream)(nil)).Elem()
+)
+
+// Service 表示一个服务
+type Service struct {
+	name    string
+	rcvr    reflect.Value
+	typ     reflect.Type
+	methods map[string]*MethodType
+
+	version  string                   // 服务版本, 为空时使用服务端的版本
+	metadata map[string]string        // 实例元数据, 与服务端的元数据合并
+	timeouts map[string]time.Duration // 方法名 -> 执行超时时间
+	codecs   map[string]bool          // 允许的参数序列化方式, 为空时不限制
+}
+
+// ServiceOption 服务配置项
+type ServiceOption func(*Service)
+
+// WithServiceVersion 设置服务版本, 优先于服务端的 WithVersion
+func WithServiceVersion(version string) ServiceOption {
+	return func(s *Service) {
+		s.version = version
+	}
+}
+
+// WithServiceMetadata 设置服务实例的元数据, 与服务端的 WithMetadata 合并
+func WithServiceMetadata(md map[string]string) ServiceOption {
+	return func(s *Service) {
+		s.metadata = md
+	}
+}
+
+// WithMethodTimeout 设置方法的执行超时时间, 与客户端传递的截止时间取较早者
+// 方法不存在时注册服务返回 ErrMethodNotFound
+func WithMethodTimeout(method string, timeout time.Duration) ServiceOption {
+	return func(s *Service) {
+		s.timeouts[method] = timeout
+	}
+}
+
+// WithAllowedCodecs 限制服务接受的参数序列化方式, 序列化方式未注册时注册服务返回 codec.ErrUnsupportedCodec
+func WithAllowedCodecs(contentTypes ...string) ServiceOption {
+	return func(s *Service) {
+		for _, contentType := range contentTypes {
+			s.codecs[contentType] = true
+		}
+	}
+}
+
+// MethodType 表示一个方法
+// 支持两种签名:
+//
+//	func (s *T) Method(ctx context.Context, args *Args, reply *Reply) error
+//	func (s *T) Method(ctx context.Context, args *Args) (*Reply, error)
+//
+// 以及流式方法, 参数和响应都通过 stream 收发:
+//
+//	func (s *T) Method(ctx context.Context, stream server.Stream) error
+type MethodType struct {
+	method       reflect.Method
+	ArgType      reflect.Type
+	ReplyType    reflect.Type
+	returnsReply bool // 响应通过返回值而不是参数传出
+	stream       bool // 流式方法, ArgType 和 ReplyType 为空
+}
+
+// newService 解析接收者的所有可导出方法
+func newService(name string, rcvr interface{}, opts ...ServiceOption) (*Service, error) {
+	if name == "" {
+		return nil, ErrInvalidServiceName
+	}
+
+	service := &Service{
+		name:     name,
+		rcvr:     reflect.ValueOf(rcvr),
+		typ:      reflect.TypeOf(rcvr),
+		methods:  make(map[string]*MethodType),
+		timeouts: make(map[string]time.Duration),
+		codecs:   make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(service)
+	}
+
+	var skipped []*MethodError
+	for i := 0; i < service.typ.NumMethod(); i++ {
+		method := service.typ.Method(i)
+		mtype, err := parseMethod(method)
+		if err != nil {
+			skipped = append(skipped, &MethodError{Method: method.Name, Reason: err.Error()})
+			continue
+		}
+		service.methods[method.Name] = mtype
+	}
+
+	if len(service.methods) == 0 {
+		return nil, &RegisterError{Service: service.name, Skipped: skipped}
+	}
+
+	// 配置项中的方法名和序列化方式必须有效, 避免拼写错误的配置被静默忽略
+	for method := range service.timeouts {
+		if _, ok := service.methods[method]; !ok {
+			return nil, fmt.Errorf("%w: %s.%s", ErrMethodNotFound, service.name, method)
+		}
+	}
+	for contentType := range service.codecs {
+		if _, err := codec.LookupCodec(contentType); err != nil {
+			return nil, err
+		}
+	}
+	return service, nil
+}
+
+// parseMethod 校验方法签名, 不满足要求时返回原因
+func parseMethod(method reflect.Method) (*MethodType, error) {
+	mtype := method.Type
+
+	// 方法必须是导出的
+	if method.PkgPath != "" {
+		return nil, fmt.Errorf("method is not exported")
+	}
+
+	// 入参: receiver, context.Context, args[, reply]
+	if mtype.NumIn() != 3 && mtype.NumIn() != 4 {
+		return nil, fmt.Errorf("has %d input parameters, want (ctx, args) or (ctx, args, reply)", mtype.NumIn()-1)
+	}
+
+	// 第一个参数必须是 context.Context
+	if mtype.In(1) != typeOfContext {
+		return nil, fmt.Errorf("first parameter %s is not context.Context", mtype.In(1))
+	}
+
+	// func(ctx, server.Stream) error
+	if mtype.NumIn() == 3 && mtype.In(2) == typeOfStream {
+		if mtype.NumOut() != 1 || mtype.Out(0) != typeOfError {
+			return nil, fmt.Errorf("with a stream parameter must return only error")
+		}
+		return &MethodType{method: method, stream: true}, nil
+	}
+
+	argType := mtype.In(2)
+	if !isExportedOrBuiltinType(argType) {
+		return nil, fmt.Errorf("args type %s is not exported", argType)
+	}
+
+	// func(ctx, *Args, *Reply) error
+	if mtype.NumIn() == 4 {
+		replyType := mtype.In(3)
+		if replyType.Kind() != reflect.Pointer {
+			return nil, fmt.Errorf("reply type %s is not a pointer", replyType)
+		}
+		if !isExportedOrBuiltinType(replyType) {
+			return nil, fmt.Errorf("reply type %s is not exported", replyType)
+		}
+		if mtype.NumOut() != 1 || mtype.Out(0) != typeOfError {
+			return nil, fmt.Errorf("with a reply parameter must return only error")
+		}
+		return &MethodType{
+			method:    method,
+			ArgType:   argType,
+			ReplyType: replyType,
+		}, nil
+	}
+
+	// func(ctx, *Args) (*Reply, error)
+	if mtype.NumOut() != 2 || mtype.Out(1) != typeOfError {
+		return nil, fmt.Errorf("without a reply parameter must return (reply, error)")
+	}
+	replyType := mtype.Out(0)
+	if !isExportedOrBuiltinType(replyType) {
+		return nil, fmt.Errorf("reply type %s is not exported", replyType)
+	}
+	return &MethodType{
+		method:       method,
+		ArgType:      argType,
+		ReplyType:    replyType,
+		returnsReply: true,
+	}, nil
+}
+
+// isExportedOrBuiltinType 类型(或其指向的类型)是否是导出类型或内置类型
+func isExportedOrBuiltinType(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return token.IsExported(t.Name()) || t.PkgPath() == ""
+}
+
+// allowCodec 服务是否接受指定的参数序列化方式
+func (s *Service) allowCodec(contentType string) bool {
+	return len(s.codecs) == 0 || s.codecs[contentType]
+}
+
+// newArgs 创建用于解码参数的指针
+func (m *MethodType) newArgs() reflect.Value {
+	if m.ArgType.Kind() == reflect.Pointer {
+		return reflect.New(m.ArgType.Elem())
+	}
+	return reflect.New(m.ArgType)
+}
+
+// call 通过反射调用服务方法, args 为 newArgs 创建的指针
+func (s *Service) call(ctx context.Context, mtype *MethodType, args interface{}) (interface{}, error) {
+	argv := reflect.ValueOf(args)
+	if mtype.ArgType.Kind() != reflect.Pointer {
+		argv = argv.Elem()
+	}
+
+	if mtype.returnsReply {
+		returnValues := mtype.method.Func.Call([]reflect.Value{s.rcvr, reflect.ValueOf(ctx), argv})
+		if err := returnValues[1].Interface(); err != nil {
+			return nil, err.(error)
+		}
+		// 返回 nil 指针的响应无法编码, 作为服务端内部错误返回给客户端
+		if reply := returnValues[0]; (reply.Kind() == reflect.Pointer || reply.Kind() == reflect.Interface) && reply.IsNil() {
+			return nil, ErrNilReply
+		}
+		return returnValues[0].Interface(), nil
+	}
+
+	replyv := reflect.New(mtype.ReplyType.Elem())
+	returnValues := mtype.method.Func.Call([]reflect.Value{s.rcvr, reflect.ValueOf(ctx), argv, replyv})
+
+	// 处理返回值
+	if err := returnValues[0].Interface(); err != nil {
+		return nil, err.(error)
+	}
+	return replyv.Interface(), nil
+}
+
+// callStream 通过反射调用流式方法
+func (s *Service) callStream(ctx context.Context, mtype *MethodType, stream Stream) error {
+	returnValues := mtype.method.Func.Call([]reflect.Value{s.rcvr, reflect.ValueOf(ctx), reflect.ValueOf(stream)})
+	if err := returnValues[0].Interface(); err != nil {
+		return err.(error)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/codec"
+	"github.com/eason-lee/l-rpc/status"
+	"github.com/stretchr/testify/suite"
+)
+
+type Args struct {
+	A, B int
+}
+
+type Reply struct {
+	Sum int
+}
+
+type unexported struct{}
+
+// Arith 包含各种签名的方法
+type Arith struct{}
+
+func (a *Arith) Add(ctx context.Context, args *Args, reply *Reply) error {
+	reply.Sum = args.A + args.B
+	return nil
+}
+
+func (a *Arith) Sum(ctx context.Context, args *Args) (*Reply, error) {
+	return &Reply{Sum: args.A + args.B}, nil
+}
+
+func (a *Arith) Nil(ctx context.Context, args *Args) (*Reply, error) {
+	return nil, nil
+}
+
+func (a *Arith) Double(ctx context.Context, n int) (int, error) {
+	return n * 2, nil
+}
+
+func (a *Arith) NoContext(args *Args, reply *Reply) error {
+	return nil
+}
+
+func (a *Arith) ValueReply(ctx context.Context, args *Args, reply Reply) error {
+	return nil
+}
+
+func (a *Arith) Hidden(ctx context.Context, args *unexported) (*Reply, error) {
+	return nil, nil
+}
+
+func (a *Arith) NoError(ctx context.Context, args *Args) *Reply {
+	return nil
+}
+
+// Invalid 没有任何可用的方法
+type Invalid struct{}
+
+func (i *Invalid) Helper() {}
+
+func (i *Invalid) Wrong(ctx context.Context, args *Args, reply *Reply) (*Reply, error) {
+	return nil, nil
+}
+
+type ServiceTestSuite struct {
+	suite.Suite
+}
+
+func (s *ServiceTestSuite) TestMethodDiscovery() {
+	service, err := newService("Arith", &Arith{})
+	s.NoError(err)
+	s.Equal("Arith", service.name)
+
+	s.Contains(service.methods, "Add")
+	s.Contains(service.methods, "Sum")
+	s.Contains(service.methods, "Double")
+	s.False(service.methods["Add"].returnsReply)
+	s.True(service.methods["Sum"].returnsReply)
+
+	for _, name := range []string{"NoContext", "ValueReply", "Hidden", "NoError"} {
+		s.NotContains(service.methods, name)
+	}
+}
+
+func (s *ServiceTestSuite) TestCall() {
+	service, err := newService("Arith", &Arith{})
+	s.NoError(err)
+
+	for _, name := range []string{"Add", "Sum"} {
+		mtype := service.methods[name]
+		args := mtype.newArgs()
+		*args.Interface().(*Args) = Args{A: 1, B: 2}
+
+		reply, err := service.call(context.Background(), mtype, args.Interface())
+		s.NoError(err)
+		s.Equal(&Reply{Sum: 3}, reply)
+	}
+
+	mtype := service.methods["Double"]
+	args := mtype.newArgs()
+	*args.Interface().(*int) = 21
+	reply, err := service.call(context.Background(), mtype, args.Interface())
+	s.NoError(err)
+	s.Equal(42, reply)
+
+	// 返回 nil 响应的方法返回内部错误
+	mtype = service.methods["Nil"]
+	_, err = service.call(context.Background(), mtype, mtype.newArgs().Interface())
+	s.ErrorIs(err, ErrNilReply)
+	s.Equal(status.Internal, status.CodeOf(err))
+}
+
+func (s *ServiceTestSuite) TestNoAvailableMethods() {
+	_, err := newService("Invalid", &Invalid{})
+	s.ErrorIs(err, ErrNoAvailableMethods)
+
+	var regErr *RegisterError
+	s.True(errors.As(err, &regErr))
+	s.Equal("Invalid", regErr.Service)
+	s.Len(regErr.Skipped, 2)
+	s.Contains(err.Error(), "Helper")
+	s.Contains(err.Error(), "Wrong")
+}
+
+func (s *ServiceTestSuite) TestRegisterName() {
+	srv := NewServer()
+	s.NoError(srv.Register(&Arith{}))
+	s.NoError(srv.RegisterName("ArithV2", &Arith{}, WithServiceVersion("2.0.0")))
+
+	// 重复的服务名
+	s.ErrorIs(srv.Register(&Arith{}), ErrServiceExists)
+	s.ErrorIs(srv.RegisterName("ArithV2", &Arith{}), ErrServiceExists)
+	s.ErrorIs(srv.RegisterName("", &Arith{}), ErrInvalidServiceName)
+
+	v, ok := srv.serviceMap.Load("ArithV2")
+	s.True(ok)
+	s.Equal("2.0.0", v.(*Service).version)
+}
+
+func (s *ServiceTestSuite) TestServiceOptions() {
+	service, err := newService("Arith", &Arith{},
+		WithServiceMetadata(map[string]string{"zone": "a"}),
+		WithMethodTimeout("Add", time.Second),
+		WithAllowedCodecs("application/x-msgpack"),
+	)
+	s.NoError(err)
+	s.Equal("a", service.metadata["zone"])
+	s.Equal(time.Second, service.timeouts["Add"])
+	s.True(service.allowCodec("application/x-msgpack"))
+	s.False(service.allowCodec("application/json"))
+
+	service, err = newService("Arith", &Arith{})
+	s.NoError(err)
+	s.True(service.allowCodec("application/json"))
+
+	// 配置了不存在的方法或未注册的序列化方式
+	_, err = newService("Arith", &Arith{}, WithMethodTimeout("Missing", time.Second))
+	s.ErrorIs(err, ErrMethodNotFound)
+	_, err = newService("Arith", &Arith{}, WithAllowedCodecs("application/x-unknown"))
+	s.ErrorIs(err, codec.ErrUnsupportedCodec)
+}
+
+func TestServiceSuite(t *testing.T) {
+	suite.Run(t, new(ServiceTestSuite))
+}
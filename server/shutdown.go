@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+
+	"github.com/eason-lee/l-rpc/protocol"
+	"github.com/eason-lee/l-rpc/transport"
+)
+
+// Shutdown 优雅关闭服务
+// 停止接收新连接, 从注册中心注销实例, 通知客户端不再发送新请求, 等待正在执行的请求完成后关闭所有连接;
+// ctx 结束时不再等待, 直接关闭所有连接并返回 ctx.Err()
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopListening()
+	s.deregisterInstances()
+	s.goAway()
+
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.closeConns()
+		return nil
+	case <-ctx.Done():
+		s.closeConns()
+		return ctx.Err()
+	}
+}
+
+// Close 立即关闭服务, 正在执行的请求会随连接关闭而被取消
+func (s *Server) Close() error {
+	s.stopListening()
+	s.deregisterInstances()
+	s.closeConns()
+	return nil
+}
+
+// stopListening 标记服务已关闭并停止接收新连接
+func (s *Server) stopListening() {
+	s.mu.Lock()
+	s.shutdown = true
+	listener := s.listener
+	s.mu.Unlock()
+
+	if listener != nil {
+		listener.Close()
+	}
+}
+
+// goAway 通知所有连接上的客户端不再发送新请求
+func (s *Server) goAway() {
+	msg := &protocol.Message{
+		Header: &protocol.Header{
+			Type: protocol.TypeGoAway,
+		},
+	}
+	for _, trans := range s.activeConns() {
+		s.sendResponse(msg, trans)
+	}
+}
+
+// closeConns 关闭所有连接
+func (s *Server) closeConns() {
+	for _, trans := range s.activeConns() {
+		trans.Close()
+	}
+}
+
+func (s *Server) activeConns() []transport.Transport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conns := make([]transport.Transport, 0, len(s.conns))
+	for trans := range s.conns {
+		conns = append(conns, trans)
+	}
+	return conns
+}
+
+// trackConn 记录或移除连接, 服务关闭后不再接受新连接
+func (s *Server) trackConn(trans transport.Transport, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !add {
+		delete(s.conns, trans)
+		return true
+	}
+	if s.shutdown {
+		return false
+	}
+	s.conns[trans] = struct{}{}
+	return true
+}
+
+// beginRequest 登记一个正在执行的请求, 服务关闭后返回 false
+func (s *Server) beginRequest() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shutdown {
+		return false
+	}
+	s.inflight.Add(1)
+	return true
+}
+
+func (s *Server) isShutdown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shutdown
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/eason-lee/l-rpc/codec"
+	"github.com/eason-lee/l-rpc/metadata"
+	"github.com/eason-lee/l-rpc/protocol"
+	"github.com/eason-lee/l-rpc/status"
+	"github.com/eason-lee/l-rpc/stream"
+	"github.com/eason-lee/l-rpc/transport"
+)
+
+// Stream 流式方法收发消息的接口
+// 方法返回即结束流, 返回的错误作为调用结果发送给客户端; 流式方法不经过 UnaryInterceptor
+type Stream interface {
+	// Context 返回流的上下文, 客户端取消或连接断开时结束
+	Context() context.Context
+	// Send 发送一条消息, 客户端来不及读取时阻塞
+	Send(msg interface{}) error
+	// Recv 读取一条消息, 客户端关闭发送后返回 io.EOF
+	Recv(msg interface{}) error
+}
+
+// serverStream 服务端的流
+type serverStream struct {
+	core   *stream.Stream
+	codec  codec.Codec
+	cancel context.CancelFunc
+}
+
+func (ss *serverStream) Context() context.Context {
+	return ss.core.Context()
+}
+
+func (ss *serverStream) Send(msg interface{}) error {
+	data, err := encode(ss.codec, msg)
+	if err != nil {
+		return err
+	}
+	return ss.core.Send(data)
+}
+
+func (ss *serverStream) Recv(msg interface{}) error {
+	data, err := ss.core.Recv()
+	if err != nil {
+		return err
+	}
+	return decode(ss.codec, data, msg)
+}
+
+// openStream 建立流并在新的协程中执行流式方法
+// 流在读协程中同步建立, 保证紧随其后的数据消息能找到对应的流
+func (s *Server) openStream(connCtx context.Context, header *protocol.Header, trans transport.Transport, streams *sync.Map) {
+	write := streamWriter(trans)
+
+	// 关闭过程中拒绝新的流
+	if !s.beginRequest() {
+		rejectStream(write, header.StreamID, ErrServerClosed)
+		return
+	}
+
+	service, mtype, cc, err := s.lookupMethod(header)
+	if err == nil && !mtype.stream {
+		err = fmt.Errorf("%w: %s.%s is not a streaming method", ErrStreamMismatch, header.ServiceName, header.MethodName)
+	}
+	var release func()
+	if err == nil {
+		release, err = s.acquire(header, false)
+	}
+	if err != nil {
+		s.inflight.Done()
+		rejectStream(write, header.StreamID, err)
+		return
+	}
+
+	ctx, cancel := requestContext(connCtx, header)
+	// 方法级别的超时时间
+	if timeout := service.timeouts[header.MethodName]; timeout > 0 {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeout(ctx, timeout)
+		parent := cancel
+		cancel = func() {
+			stop()
+			parent()
+		}
+	}
+	// 将请求头中的元数据注入处理函数的上下文
+	ctx = metadata.NewIncomingContext(ctx, metadata.New(header.Metadata))
+
+	ss := &serverStream{
+		core:   stream.New(ctx, header.StreamID, write),
+		codec:  cc,
+		cancel: cancel,
+	}
+	streams.Store(header.StreamID, ss)
+
+	go func() {
+		defer s.inflight.Done()
+		defer release()
+		defer streams.Delete(header.StreamID)
+		defer cancel()
+
+		err := service.callStream(ctx, mtype, ss)
+		// 方法返回后关闭流, 将结果发送给客户端
+		ss.core.CloseSend(err)
+	}()
+}
+
+// rejectStream 以错误结束尚未建立的流
+func rejectStream(write stream.WriteFunc, streamID uint64, err error) {
+	msg := &protocol.Message{
+		Header: &protocol.Header{
+			StreamID: streamID,
+			Type:     protocol.TypeStreamClose,
+		},
+	}
+	status.ToHeader(msg.Header, err)
+	write(msg)
+}
+
+// streamWriter 将流消息编码后写到连接上
+func streamWriter(trans transport.Transport) stream.WriteFunc {
+	return trans.WriteMessage
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+
+	"github.com/eason-lee/l-rpc/protocol"
+	"github.com/eason-lee/l-rpc/status"
+	"github.com/eason-lee/l-rpc/trace"
+)
+
+// WithTracer 设置链路追踪, 每个请求创建一个服务端调用, 请求头携带 traceparent 时作为客户端调用的子调用
+// 处理函数可以通过 trace.SpanFromContext 获取该调用
+func WithTracer(tracer *trace.Tracer) Option {
+	return func(s *Server) {
+		s.tracer = tracer
+	}
+}
+
+// startSpan 从请求头中解析调用上下文并创建服务端调用, 未配置链路追踪时返回 nil
+func (s *Server) startSpan(ctx context.Context, header *protocol.Header) (context.Context, *trace.Span) {
+	if s.tracer == nil {
+		return ctx, nil
+	}
+
+	if sc, ok := trace.Extract(header.Metadata); ok {
+		ctx = trace.ContextWithRemoteSpanContext(ctx, sc)
+	}
+	ctx, span := s.tracer.Start(ctx, header.ServiceName+"/"+header.MethodName, trace.SpanKindServer)
+	span.SetAttribute(trace.AttrService, header.ServiceName)
+	span.SetAttribute(trace.AttrMethod, header.MethodName)
+	return ctx, span
+}
+
+// endSpan 按响应头记录调用结果并结束调用
+func endSpan(span *trace.Span, resp *protocol.Header) {
+	if span == nil {
+		return
+	}
+	err := status.FromHeader(resp)
+	span.SetAttribute(trace.AttrStatusCode, status.CodeOf(err).String())
+	span.RecordError(err)
+	span.End()
+}
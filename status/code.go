@@ -0,0 +1,64 @@
+package status
+
+import "strconv"
+
+// Code RPC 错误码
+type Code uint32
+
+const (
+	// OK 调用成功
+	OK Code = iota
+	// Canceled 调用被调用方取消
+	Canceled
+	// Unknown 未知错误, 业务方法返回的普通错误使用该错误码
+	Unknown
+	// InvalidArgument 参数错误, 包括参数解码失败和不支持的序列化方式
+	InvalidArgument
+	// DeadlineExceeded 调用超时
+	DeadlineExceeded
+	// NotFound 服务或方法不存在
+	NotFound
+	// AlreadyExists 资源已存在
+	AlreadyExists
+	// PermissionDenied 没有权限
+	PermissionDenied
+	// ResourceExhausted 资源耗尽, 如触发限流
+	ResourceExhausted
+	// FailedPrecondition 不满足执行条件
+	FailedPrecondition
+	// Aborted 调用被中止
+	Aborted
+	// Unimplemented 方法未实现
+	Unimplemented
+	// Internal 服务端内部错误
+	Internal
+	// Unavailable 服务不可用, 如服务关闭或没有可用实例, 通常可以重试
+	Unavailable
+	// Unauthenticated 未认证
+	Unauthenticated
+)
+
+var codeNames = map[Code]string{
+	OK:                 "OK",
+	Canceled:           "Canceled",
+	Unknown:            "Unknown",
+	InvalidArgument:    "InvalidArgument",
+	DeadlineExceeded:   "DeadlineExceeded",
+	NotFound:           "NotFound",
+	AlreadyExists:      "AlreadyExists",
+	PermissionDenied:   "PermissionDenied",
+	ResourceExhausted:  "ResourceExhausted",
+	FailedPrecondition: "FailedPrecondition",
+	Aborted:            "Aborted",
+	Unimplemented:      "Unimplemented",
+	Internal:           "Internal",
+	Unavailable:        "Unavailable",
+	Unauthenticated:    "Unauthenticated",
+}
+
+func (c Code) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return "Code(" + strconv.FormatUint(uint64(c), 10) + ")"
+}
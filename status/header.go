@@ -0,0 +1,30 @@
+package status
+
+import "github.com/eason-lee/l-rpc/protocol"
+
+// ToHeader 将错误转换为状态写入消息头
+func ToHeader(header *protocol.Header, err error) {
+	st := Convert(err)
+	header.Error = st.Message
+	header.Code = uint32(st.Code)
+	header.Reason = st.Reason
+	header.Details = st.Details
+}
+
+// FromHeader 从消息头中还原状态, 消息头不包含错误时返回 nil
+func FromHeader(header *protocol.Header) error {
+	code := Code(header.Code)
+	if code == OK && header.Error == "" {
+		return nil
+	}
+	// 兼容只设置了错误信息的消息
+	if code == OK {
+		code = Unknown
+	}
+	return &Status{
+		Code:    code,
+		Message: header.Error,
+		Reason:  header.Reason,
+		Details: header.Details,
+	}
+}
@@ -0,0 +1,150 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Status RPC 调用结果, 作为错误在服务端和客户端之间传递
+type Status struct {
+	// 错误码
+	Code Code
+	// 错误信息
+	Message string
+	// 错误原因, 标识通过 RegisterError 注册的预定义错误, 客户端据此支持 errors.Is
+	Reason string
+	// 结构化的错误详情
+	Details map[string]string
+}
+
+// New 创建指定错误码的状态
+func New(code Code, msg string) *Status {
+	return &Status{Code: code, Message: msg}
+}
+
+// Newf 创建指定错误码的状态, 错误信息按格式生成
+func Newf(code Code, format string, a ...interface{}) *Status {
+	return New(code, fmt.Sprintf(format, a...))
+}
+
+// Error 创建指定错误码的错误
+func Error(code Code, msg string) error {
+	return New(code, msg)
+}
+
+// Errorf 创建指定错误码的错误, 错误信息按格式生成
+func Errorf(code Code, format string, a ...interface{}) error {
+	return Newf(code, format, a...)
+}
+
+// WithDetails 返回追加了错误详情的状态副本, 参数为键值对
+func (s *Status) WithDetails(kv ...string) *Status {
+	out := *s
+	out.Details = make(map[string]string, len(s.Details)+len(kv)/2)
+	for k, v := range s.Details {
+		out.Details[k] = v
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		out.Details[kv[i]] = kv[i+1]
+	}
+	return &out
+}
+
+// Err 状态为 OK 时返回 nil, 否则返回错误
+func (s *Status) Err() error {
+	if s == nil || s.Code == OK {
+		return nil
+	}
+	return s
+}
+
+func (s *Status) Error() string {
+	return s.Message
+}
+
+// String 返回包含错误码的描述
+func (s *Status) String() string {
+	return fmt.Sprintf("code = %s desc = %s", s.Code, s.Message)
+}
+
+// Is 支持 errors.Is 匹配通过 RegisterError 注册的预定义错误
+func (s *Status) Is(target error) bool {
+	if s.Reason == "" {
+		return false
+	}
+	e, ok := lookup(target)
+	return ok && e.reason == s.Reason
+}
+
+// registered 预定义错误与错误码的对应关系
+type registered struct {
+	err    error
+	code   Code
+	reason string
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []registered
+)
+
+func init() {
+	RegisterError(context.Canceled, Canceled, "CANCELED")
+	RegisterError(context.DeadlineExceeded, DeadlineExceeded, "DEADLINE_EXCEEDED")
+}
+
+// RegisterError 注册预定义错误对应的错误码
+// reason 在所有注册的错误中必须唯一, 转换后的状态会携带 reason,
+// 客户端收到该状态后可以通过 errors.Is(err, target) 判断是否是该预定义错误
+func RegisterError(err error, code Code, reason string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, registered{err: err, code: code, reason: reason})
+}
+
+func lookup(target error) (registered, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, e := range registry {
+		if e.err == target {
+			return e, true
+		}
+	}
+	return registered{}, false
+}
+
+// FromError 获取错误中的状态, 错误不包含状态时返回 false
+func FromError(err error) (*Status, bool) {
+	var s *Status
+	if errors.As(err, &s) {
+		return s, true
+	}
+	return nil, false
+}
+
+// Convert 将任意错误转换为状态
+// 包含状态的错误直接返回其状态, 预定义错误使用注册的错误码, 其他错误使用 Unknown
+func Convert(err error) *Status {
+	if err == nil {
+		return New(OK, "")
+	}
+	if s, ok := FromError(err); ok {
+		return s
+	}
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, e := range registry {
+		if errors.Is(err, e.err) {
+			return &Status{Code: e.code, Message: err.Error(), Reason: e.reason}
+		}
+	}
+	return New(Unknown, err.Error())
+}
+
+// CodeOf 获取错误的错误码, err 为 nil 时返回 OK
+func CodeOf(err error) Code {
+	return Convert(err).Code
+}
@@ -0,0 +1,74 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+var errNotFound = errors.New("user not found")
+
+func init() {
+	RegisterError(errNotFound, NotFound, "TEST_USER_NOT_FOUND")
+}
+
+type StatusTestSuite struct {
+	suite.Suite
+}
+
+func (s *StatusTestSuite) TestConvert() {
+	tests := []struct {
+		name string
+		err  error
+		code Code
+	}{
+		{"nil", nil, OK},
+		{"状态", Error(InvalidArgument, "bad"), InvalidArgument},
+		{"包装的状态", fmt.Errorf("wrap: %w", Error(Internal, "oops")), Internal},
+		{"预定义错误", fmt.Errorf("get: %w", errNotFound), NotFound},
+		{"超时", context.DeadlineExceeded, DeadlineExceeded},
+		{"取消", context.Canceled, Canceled},
+		{"普通错误", errors.New("plain"), Unknown},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.Equal(tt.code, CodeOf(tt.err))
+		})
+	}
+}
+
+func (s *StatusTestSuite) TestIs() {
+	st := Convert(fmt.Errorf("get: %w", errNotFound))
+	s.Equal("TEST_USER_NOT_FOUND", st.Reason)
+	s.Equal("get: user not found", st.Message)
+
+	// 模拟经过网络传输后还原的状态
+	var err error = &Status{Code: st.Code, Message: st.Message, Reason: st.Reason}
+	s.True(errors.Is(err, errNotFound))
+	s.False(errors.Is(err, context.Canceled))
+	s.False(errors.Is(Error(NotFound, "user not found"), errNotFound))
+}
+
+func (s *StatusTestSuite) TestWithDetails() {
+	st := New(InvalidArgument, "invalid name")
+	detailed := st.WithDetails("field", "name", "rule", "required")
+
+	s.Nil(st.Details)
+	s.Equal(map[string]string{"field": "name", "rule": "required"}, detailed.Details)
+	s.Equal("invalid name", detailed.Error())
+	s.Equal("code = InvalidArgument desc = invalid name", detailed.String())
+}
+
+func (s *StatusTestSuite) TestErr() {
+	s.NoError(New(OK, "").Err())
+	s.Error(New(Internal, "oops").Err())
+	s.Equal("Code(100)", Code(100).String())
+}
+
+func TestStatusSuite(t *testing.T) {
+	suite.Run(t, new(StatusTestSuite))
+}
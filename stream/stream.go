@@ -0,0 +1,275 @@
+package stream
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/eason-lee/l-rpc/protocol"
+	"github.com/eason-lee/l-rpc/status"
+)
+
+// 流控窗口大小
+// 接收方最多缓存 DefaultWindow 条、DefaultWindowBytes 字节未读取的消息, 发送方用完任一窗口后阻塞等待接收方读取;
+// 字节窗口还有剩余时可以发送超过剩余大小的消息, 接收方缓存的数据最多为 DefaultWindowBytes 加一条消息的长度
+const (
+	DefaultWindow      = 64
+	DefaultWindowBytes = 1 << 20
+)
+
+var (
+	// ErrStreamClosed 流已关闭发送
+	ErrStreamClosed = errors.New("stream closed")
+	// ErrWindowExceeded 对端发送的消息超出流控窗口
+	ErrWindowExceeded = errors.New("stream flow control window exceeded")
+)
+
+func init() {
+	status.RegisterError(ErrWindowExceeded, status.Aborted, "WINDOW_EXCEEDED")
+}
+
+// WriteFunc 将流消息写到连接上
+type WriteFunc func(msg *protocol.Message) error
+
+// Stream 客户端与服务端共用的流实现, 负责消息缓冲、流控和关闭状态
+// 消息体由上层完成序列化, Stream 只处理字节数据
+type Stream struct {
+	id    uint64
+	ctx   context.Context
+	write WriteFunc
+
+	mu            sync.Mutex
+	queue         [][]byte      // 已收到尚未读取的消息
+	queued        int           // 已收到尚未读取的消息的字节数
+	recvErr       error         // 对端关闭发送后的结果, io.EOF 表示正常结束
+	err           error         // 流被中止的原因
+	sendClosed    bool          // 本端已关闭发送
+	ended         bool          // 对端已结束整个流, 不再接收消息
+	credits       uint32        // 还可以发送的消息数
+	byteCredits   int64         // 还可以发送的字节数, 发送超过剩余大小的消息后为负数
+	consumed      uint32        // 已读取但尚未通知对端的消息数
+	consumedBytes uint32        // 已读取但尚未通知对端的字节数
+	signal        chan struct{} // 状态变化时关闭并替换, 唤醒等待中的 Send 和 Recv
+}
+
+// New 创建流, write 用于写出数据、关闭和流控消息
+func New(ctx context.Context, id uint64, write WriteFunc) *Stream {
+	return &Stream{
+		id:          id,
+		ctx:         ctx,
+		write:       write,
+		credits:     DefaultWindow,
+		byteCredits: DefaultWindowBytes,
+		signal:      make(chan struct{}),
+	}
+}
+
+// ID 返回流ID
+func (s *Stream) ID() uint64 {
+	return s.id
+}
+
+// Context 返回流的上下文
+func (s *Stream) Context() context.Context {
+	return s.ctx
+}
+
+// Send 发送一条消息, 对端窗口用完时阻塞直到对端读取或上下文结束
+// 对端已结束流时返回 io.EOF, 结果通过 Recv 获取
+func (s *Stream) Send(data []byte) error {
+	for {
+		s.mu.Lock()
+		if s.err != nil {
+			err := s.err
+			s.mu.Unlock()
+			return err
+		}
+		if s.sendClosed {
+			s.mu.Unlock()
+			return ErrStreamClosed
+		}
+		if s.ended {
+			s.mu.Unlock()
+			return io.EOF
+		}
+		if s.credits > 0 && s.byteCredits > 0 {
+			s.credits--
+			s.byteCredits -= int64(len(data))
+			s.mu.Unlock()
+			return s.write(s.message(protocol.TypeStreamData, data))
+		}
+		signal := s.signal
+		s.mu.Unlock()
+
+		select {
+		case <-signal:
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+	}
+}
+
+// Recv 读取一条消息
+// 对端正常关闭发送且消息读完后返回 io.EOF, 对端以错误结束时返回对应的状态错误
+func (s *Stream) Recv() ([]byte, error) {
+	for {
+		s.mu.Lock()
+		if s.err != nil {
+			err := s.err
+			s.mu.Unlock()
+			return nil, err
+		}
+		if len(s.queue) > 0 {
+			data := s.queue[0]
+			s.queue[0] = nil
+			s.queue = s.queue[1:]
+
+			s.queued -= len(data)
+
+			// 读取过半窗口后通知对端, 避免每条消息都发送流控消息
+			var increment, bytesIncrement uint32
+			s.consumed++
+			s.consumedBytes += uint32(len(data))
+			if (s.consumed >= DefaultWindow/2 || s.consumedBytes >= DefaultWindowBytes/2) && s.recvErr == nil {
+				increment, bytesIncrement = s.consumed, s.consumedBytes
+				s.consumed, s.consumedBytes = 0, 0
+			}
+			s.mu.Unlock()
+
+			if increment > 0 {
+				if err := s.writeWindow(increment, bytesIncrement); err != nil {
+					return nil, err
+				}
+			}
+			return data, nil
+		}
+		if s.recvErr != nil {
+			err := s.recvErr
+			s.mu.Unlock()
+			return nil, err
+		}
+		signal := s.signal
+		s.mu.Unlock()
+
+		select {
+		case <-signal:
+		case <-s.ctx.Done():
+			return nil, s.ctx.Err()
+		}
+	}
+}
+
+// CloseSend 关闭发送, 重复调用无效
+// err 不为 nil 时作为调用结果写入关闭消息的消息头
+// 因对端超出流控窗口而中止的流仍发送关闭消息, 以 ErrWindowExceeded 作为结果, 对端收到中止状态后结束流;
+// 其他原因中止的流对端已被取消或连接已断开, 不再发送
+func (s *Stream) CloseSend(err error) error {
+	s.mu.Lock()
+	if s.sendClosed || (s.err != nil && s.err != ErrWindowExceeded) {
+		s.mu.Unlock()
+		return nil
+	}
+	if s.err != nil {
+		err = s.err
+	}
+	s.sendClosed = true
+	s.broadcast()
+	s.mu.Unlock()
+
+	msg := s.message(protocol.TypeStreamClose, nil)
+	if err != nil {
+		status.ToHeader(msg.Header, err)
+	}
+	return s.write(msg)
+}
+
+// Deliver 处理连接读协程收到的流消息, 不会阻塞
+// 对端超出流控窗口时中止流并返回 ErrWindowExceeded, 调用方需要通知对端取消该流
+func (s *Stream) Deliver(msg *protocol.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil
+	}
+	switch msg.Header.Type {
+	case protocol.TypeStreamData:
+		if s.recvErr != nil {
+			return nil
+		}
+		// 对端不遵守流控时中止流, 避免无限缓存
+		if len(s.queue) >= DefaultWindow || s.queued >= DefaultWindowBytes {
+			s.err = ErrWindowExceeded
+			s.queue, s.queued = nil, 0
+			s.broadcast()
+			return ErrWindowExceeded
+		}
+		s.queue = append(s.queue, msg.Data)
+		s.queued += len(msg.Data)
+	case protocol.TypeStreamClose:
+		if s.recvErr != nil {
+			return nil
+		}
+		s.recvErr = status.FromHeader(msg.Header)
+		if s.recvErr == nil {
+			s.recvErr = io.EOF
+		}
+	case protocol.TypeStreamWindow:
+		// 消息数 4字节 | 字节数 4字节
+		if len(msg.Data) < 8 {
+			return nil
+		}
+		s.credits += binary.BigEndian.Uint32(msg.Data[0:4])
+		s.byteCredits += int64(binary.BigEndian.Uint32(msg.Data[4:8]))
+	default:
+		return nil
+	}
+	s.broadcast()
+	return nil
+}
+
+// Abort 以错误中止流, 等待中的 Send 和 Recv 立即返回该错误
+func (s *Stream) Abort(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return
+	}
+	s.err = err
+	s.broadcast()
+}
+
+// End 标记对端已结束整个流, 等待窗口的 Send 立即返回 io.EOF
+// 客户端收到服务端的关闭消息时调用, 服务端方法返回前客户端关闭发送不影响服务端发送
+func (s *Stream) End() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ended = true
+	s.broadcast()
+}
+
+func (s *Stream) broadcast() {
+	close(s.signal)
+	s.signal = make(chan struct{})
+}
+
+func (s *Stream) writeWindow(increment, bytesIncrement uint32) error {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint32(data[0:4], increment)
+	binary.BigEndian.PutUint32(data[4:8], bytesIncrement)
+	return s.write(s.message(protocol.TypeStreamWindow, data))
+}
+
+func (s *Stream) message(typ protocol.MessageType, data []byte) *protocol.Message {
+	return &protocol.Message{
+		Header: &protocol.Header{
+			StreamID: s.id,
+			Type:     typ,
+		},
+		Data: data,
+	}
+}
@@ -0,0 +1,183 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/protocol"
+	"github.com/eason-lee/l-rpc/status"
+	"github.com/stretchr/testify/suite"
+)
+
+type StreamTestSuite struct {
+	suite.Suite
+	ctx    context.Context
+	cancel context.CancelFunc
+	local  *Stream
+	remote *Stream
+}
+
+func (s *StreamTestSuite) SetupTest() {
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	// 两端直接互相投递消息, 模拟同一连接上的两端
+	s.local = New(s.ctx, 1, func(msg *protocol.Message) error {
+		s.remote.Deliver(msg)
+		return nil
+	})
+	s.remote = New(context.Background(), 1, func(msg *protocol.Message) error {
+		s.local.Deliver(msg)
+		return nil
+	})
+}
+
+func (s *StreamTestSuite) TearDownTest() {
+	s.cancel()
+}
+
+func (s *StreamTestSuite) TestSendAndRecv() {
+	for i := 0; i < 3; i++ {
+		s.NoError(s.local.Send([]byte(fmt.Sprint(i))))
+	}
+	s.NoError(s.local.CloseSend(nil))
+
+	for i := 0; i < 3; i++ {
+		data, err := s.remote.Recv()
+		s.NoError(err)
+		s.Equal(fmt.Sprint(i), string(data))
+	}
+	_, err := s.remote.Recv()
+	s.Equal(io.EOF, err)
+
+	// 关闭发送后不能再发送
+	s.Equal(ErrStreamClosed, s.local.Send([]byte("x")))
+}
+
+func (s *StreamTestSuite) TestFlowControl() {
+	for i := 0; i < DefaultWindow; i++ {
+		s.NoError(s.local.Send([]byte("x")))
+	}
+
+	// 窗口用完后阻塞, 直到对端读取过半窗口
+	sent := make(chan error, 1)
+	go func() {
+		sent <- s.local.Send([]byte("x"))
+	}()
+	select {
+	case <-sent:
+		s.Fail("send should block when window is exhausted")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	for i := 0; i < DefaultWindow/2; i++ {
+		_, err := s.remote.Recv()
+		s.NoError(err)
+	}
+	select {
+	case err := <-sent:
+		s.NoError(err)
+	case <-time.After(time.Second):
+		s.Fail("send should resume after window update")
+	}
+}
+
+func (s *StreamTestSuite) TestSendCanceled() {
+	for i := 0; i < DefaultWindow; i++ {
+		s.NoError(s.local.Send([]byte("x")))
+	}
+	s.cancel()
+	s.Equal(context.Canceled, s.local.Send([]byte("x")))
+}
+
+func (s *StreamTestSuite) TestByteWindow() {
+	// 字节窗口还有剩余时可以发送超过剩余大小的消息
+	s.NoError(s.local.Send(make([]byte, DefaultWindowBytes/2)))
+	s.NoError(s.local.Send(make([]byte, DefaultWindowBytes/2+1)))
+
+	// 字节窗口用完后阻塞, 直到对端读取过半字节窗口
+	sent := make(chan error, 1)
+	go func() {
+		sent <- s.local.Send([]byte("x"))
+	}()
+	select {
+	case <-sent:
+		s.Fail("send should block when byte window is exhausted")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	_, err := s.remote.Recv()
+	s.NoError(err)
+	select {
+	case err := <-sent:
+		s.NoError(err)
+	case <-time.After(time.Second):
+		s.Fail("send should resume after window update")
+	}
+}
+
+func (s *StreamTestSuite) TestWindowExceeded() {
+	data := &protocol.Message{
+		Header: &protocol.Header{StreamID: 1, Type: protocol.TypeStreamData},
+	}
+	for i := 0; i < DefaultWindow; i++ {
+		s.NoError(s.remote.Deliver(data))
+	}
+	s.Equal(ErrWindowExceeded, s.remote.Deliver(data))
+	_, err := s.remote.Recv()
+	s.Equal(ErrWindowExceeded, err)
+
+	// 中止的流仍以中止状态通知对端结束
+	s.NoError(s.remote.CloseSend(nil))
+	_, err = s.local.Recv()
+	s.Equal(status.Aborted, status.CodeOf(err))
+}
+
+func (s *StreamTestSuite) TestByteWindowExceeded() {
+	// 已缓存的数据达到字节窗口后中止流, 即使消息数没有超过窗口
+	data := &protocol.Message{
+		Header: &protocol.Header{StreamID: 1, Type: protocol.TypeStreamData},
+		Data:   make([]byte, DefaultWindowBytes/2),
+	}
+	s.NoError(s.remote.Deliver(data))
+	s.NoError(s.remote.Deliver(data))
+	s.Equal(ErrWindowExceeded, s.remote.Deliver(data))
+
+	// 中止的流以 ErrWindowExceeded 作为结果通知对端
+	s.NoError(s.remote.CloseSend(status.Error(status.Canceled, "canceled")))
+	_, err := s.local.Recv()
+	s.Equal(status.Aborted, status.CodeOf(err))
+}
+
+func (s *StreamTestSuite) TestCloseWithStatus() {
+	s.NoError(s.remote.Send([]byte("partial")))
+	s.NoError(s.remote.CloseSend(status.Error(status.NotFound, "no more pages")))
+
+	// 先读完已收到的消息, 再返回对端的结果
+	data, err := s.local.Recv()
+	s.NoError(err)
+	s.Equal("partial", string(data))
+
+	_, err = s.local.Recv()
+	s.Equal(status.NotFound, status.CodeOf(err))
+	s.Equal("no more pages", err.Error())
+
+	// 对端结束流后不能再发送
+	s.local.End()
+	s.Equal(io.EOF, s.local.Send([]byte("x")))
+}
+
+func (s *StreamTestSuite) TestAbort() {
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.local.Recv()
+		done <- err
+	}()
+	s.local.Abort(io.ErrUnexpectedEOF)
+	s.Equal(io.ErrUnexpectedEOF, <-done)
+}
+
+func TestStreamSuite(t *testing.T) {
+	suite.Run(t, new(StreamTestSuite))
+}
@@ -0,0 +1,207 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/breaker"
+	"github.com/eason-lee/l-rpc/client"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/server"
+	"github.com/eason-lee/l-rpc/status"
+)
+
+// BreakerService failing 为 true 时返回 Unavailable
+type BreakerService struct {
+	calls   atomic.Int64
+	failing atomic.Bool
+}
+
+func (s *BreakerService) Echo(ctx context.Context, req *EchoRequest, reply *EchoResponse) error {
+	s.calls.Add(1)
+	if s.failing.Load() {
+		return status.Error(status.Unavailable, "overloaded")
+	}
+	reply.Message = req.Message
+	return nil
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	reg := registry.NewInMemoryRegistry()
+	srv := server.NewServer(server.WithRegistry(reg))
+	svc := &BreakerService{}
+	if err := srv.Register(svc); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+	if err := srv.Register(&EchoService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+	if err := srv.Register(&StreamService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+
+	go srv.Start("127.0.0.1:8904")
+	time.Sleep(time.Second)
+	defer srv.Close()
+
+	t.Run("状态变化", func(t *testing.T) {
+		var mu sync.Mutex
+		var transitions []breaker.State
+		cli := client.NewClient(reg, registry.NewRandomBalancer(), client.WithCircuitBreaker(breaker.Config{
+			WindowSize:       4,
+			MinCalls:         4,
+			OpenTimeout:      200 * time.Millisecond,
+			HalfOpenMaxCalls: 1,
+			OnStateChange: func(name string, from, to breaker.State) {
+				mu.Lock()
+				defer mu.Unlock()
+				transitions = append(transitions, to)
+			},
+		}))
+		defer cli.Close()
+
+		svc.failing.Store(true)
+		for i := 0; i < 4; i++ {
+			cli.Call(context.Background(), "BreakerService.Echo", &EchoRequest{Message: "x"}, &EchoResponse{})
+		}
+
+		// 唯一的实例被熔断, 调用不再发送到服务端
+		err := cli.Call(context.Background(), "BreakerService.Echo", &EchoRequest{Message: "x"}, &EchoResponse{})
+		if !errors.Is(err, registry.ErrNoAvailableInstances) {
+			t.Errorf("期望没有可用实例, 实际: %v", err)
+		}
+		if n := svc.calls.Load(); n != 4 {
+			t.Errorf("熔断后不应再调用服务端, 实际调用次数: %d", n)
+		}
+
+		// 打开超时后放行探测调用, 成功后关闭
+		svc.failing.Store(false)
+		time.Sleep(250 * time.Millisecond)
+		if err := cli.Call(context.Background(), "BreakerService.Echo", &EchoRequest{Message: "x"}, &EchoResponse{}); err != nil {
+			t.Fatalf("探测调用失败: %v", err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		want := []breaker.State{breaker.StateOpen, breaker.StateHalfOpen, breaker.StateClosed}
+		if len(transitions) != len(want) {
+			t.Fatalf("期望状态变化 %v, 实际: %v", want, transitions)
+		}
+		for i := range want {
+			if transitions[i] != want[i] {
+				t.Errorf("期望状态变化 %v, 实际: %v", want, transitions)
+			}
+		}
+	})
+
+	t.Run("过滤熔断的实例", func(t *testing.T) {
+		if err := reg.Register(&registry.ServiceInstance{
+			ID:        "EchoService-dead",
+			Name:      "EchoService",
+			Endpoints: []string{"127.0.0.1:8905"},
+		}); err != nil {
+			t.Fatalf("注册实例失败: %v", err)
+		}
+		defer reg.Deregister("EchoService-dead")
+
+		// 负载均衡器总是优先选择不可达的实例, 熔断后只能选到可用的实例
+		cli := client.NewClient(reg, &preferBalancer{id: "EchoService-dead"}, client.WithMultiplex(), client.WithCircuitBreaker(breaker.Config{
+			WindowSize: 2,
+			MinCalls:   2,
+		}))
+		defer cli.Close()
+
+		var failures int
+		for i := 0; i < 10; i++ {
+			call := <-cli.Go("EchoService.Echo", &EchoRequest{Message: "x"}, &EchoResponse{}, make(chan *client.Call, 1)).Done
+			if call.Error != nil {
+				failures++
+				continue
+			}
+			if call.Instance.ID == "EchoService-dead" {
+				t.Errorf("第 %d 次调用选中了熔断的实例", i)
+			}
+		}
+		if failures != 2 {
+			t.Errorf("期望熔断前失败 2 次, 实际: %d", failures)
+		}
+	})
+	t.Run("流式调用过滤熔断的实例", func(t *testing.T) {
+		if err := reg.Register(&registry.ServiceInstance{
+			ID:        "StreamService-dead",
+			Name:      "StreamService",
+			Endpoints: []string{"127.0.0.1:8905"},
+		}); err != nil {
+			t.Fatalf("注册实例失败: %v", err)
+		}
+		defer reg.Deregister("StreamService-dead")
+
+		cli := client.NewClient(reg, &preferBalancer{id: "StreamService-dead"}, client.WithMultiplex(), client.WithCircuitBreaker(breaker.Config{
+			WindowSize: 2,
+			MinCalls:   2,
+		}))
+		defer cli.Close()
+
+		var failures int
+		for i := 0; i < 5; i++ {
+			st, err := cli.NewStream(context.Background(), "StreamService.List")
+			if err != nil {
+				failures++
+				continue
+			}
+			if err := st.Send(&PageRequest{Count: 1}); err != nil {
+				t.Fatalf("发送失败: %v", err)
+			}
+			if err := st.Recv(&EchoResponse{}); err != nil {
+				t.Fatalf("接收失败: %v", err)
+			}
+		}
+		if failures != 2 {
+			t.Errorf("期望熔断前失败 2 次, 实际: %d", failures)
+		}
+	})
+	t.Run("实例移除后释放熔断器", func(t *testing.T) {
+		dead := &registry.ServiceInstance{
+			ID:        "EchoService-moved",
+			Name:      "EchoService",
+			Endpoints: []string{"127.0.0.1:8905"},
+		}
+		if err := reg.Register(dead); err != nil {
+			t.Fatalf("注册实例失败: %v", err)
+		}
+
+		cli := client.NewClient(reg, &preferBalancer{id: "EchoService-moved"}, client.WithCircuitBreaker(breaker.Config{
+			WindowSize: 2,
+			MinCalls:   2,
+		}))
+		defer cli.Close()
+
+		for i := 0; i < 2; i++ {
+			cli.Call(context.Background(), "EchoService.Echo", &EchoRequest{Message: "x"}, &EchoResponse{})
+		}
+
+		// 实例下线后以相同的ID在可用的地址上重新注册, 不再沿用之前打开的熔断器
+		reg.Deregister(dead.ID)
+		time.Sleep(100 * time.Millisecond)
+		if err := reg.Register(&registry.ServiceInstance{
+			ID:        dead.ID,
+			Name:      "EchoService",
+			Endpoints: []string{"127.0.0.1:8904"},
+		}); err != nil {
+			t.Fatalf("注册实例失败: %v", err)
+		}
+		defer reg.Deregister(dead.ID)
+
+		call := <-cli.Go("EchoService.Echo", &EchoRequest{Message: "x"}, &EchoResponse{}, make(chan *client.Call, 1)).Done
+		if call.Error != nil {
+			t.Fatalf("调用失败: %v", call.Error)
+		}
+		if call.Instance.ID != dead.ID {
+			t.Errorf("期望选中重新注册的实例, 实际: %s", call.Instance.ID)
+		}
+	})
+}
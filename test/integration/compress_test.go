@@ -0,0 +1,93 @@
+package integration
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/client"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/server"
+	"github.com/eason-lee/l-rpc/transport"
+)
+
+// countingCompressor 统计压缩和解压次数的 gzip 压缩
+type countingCompressor struct {
+	transport.GzipCompressor
+	compressed   atomic.Int32
+	decompressed atomic.Int32
+}
+
+func (c *countingCompressor) Compress(data []byte) ([]byte, error) {
+	c.compressed.Add(1)
+	return c.GzipCompressor.Compress(data)
+}
+
+func (c *countingCompressor) Decompress(data []byte) ([]byte, error) {
+	c.decompressed.Add(1)
+	return c.GzipCompressor.Decompress(data)
+}
+
+func (c *countingCompressor) DecompressLimit(data []byte, maxSize int) ([]byte, error) {
+	c.decompressed.Add(1)
+	return c.GzipCompressor.DecompressLimit(data, maxSize)
+}
+
+const compressCounting transport.CompressType = 100
+
+func TestCompression(t *testing.T) {
+	counting := &countingCompressor{}
+	transport.RegisterCompressor(compressCounting, "counting", counting)
+
+	reg := registry.NewInMemoryRegistry()
+	srv := server.NewServer(server.WithRegistry(reg), server.WithCompressor(compressCounting))
+	if err := srv.Register(&EchoService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+	go srv.Start("127.0.0.1:8914")
+	time.Sleep(time.Second)
+	defer srv.Close()
+
+	cli := client.NewClient(reg, registry.NewRandomBalancer(), client.WithMultiplex(), client.WithCompressor(compressCounting))
+	defer cli.Close()
+
+	large := strings.Repeat("l-rpc ", 1000)
+	call := func(message string) {
+		t.Helper()
+		resp := &EchoResponse{}
+		if err := cli.Call(context.Background(), "EchoService.Echo", &EchoRequest{Message: message}, resp); err != nil {
+			t.Fatalf("调用失败: %v", err)
+		}
+		if resp.Message != message {
+			t.Fatalf("响应内容错误, 长度: %d", len(resp.Message))
+		}
+	}
+	expect := func(compressed, decompressed int32) {
+		t.Helper()
+		if c, d := counting.compressed.Load(), counting.decompressed.Load(); c != compressed || d != decompressed {
+			t.Errorf("期望压缩 %d 次解压 %d 次, 实际: %d %d", compressed, decompressed, c, d)
+		}
+	}
+
+	// 首次请求时还不知道服务端支持的算法, 只压缩响应
+	call(large)
+	expect(1, 1)
+
+	// 收到服务端声明的算法后压缩请求
+	call(large)
+	expect(3, 3)
+
+	// 小消息不压缩
+	call("small")
+	expect(3, 3)
+
+	// 未开启压缩的客户端不会收到压缩的响应
+	plain := client.NewClient(reg, registry.NewRandomBalancer(), client.WithMultiplex())
+	defer plain.Close()
+	if err := plain.Call(context.Background(), "EchoService.Echo", &EchoRequest{Message: large}, &EchoResponse{}); err != nil {
+		t.Fatalf("调用失败: %v", err)
+	}
+	expect(3, 3)
+}
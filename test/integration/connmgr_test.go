@@ -0,0 +1,185 @@
+package integration
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/client"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/server"
+)
+
+// NodeService 返回处理请求的服务端名称
+type NodeService struct {
+	name string
+}
+
+func (s *NodeService) Name(ctx context.Context, req *EchoRequest, reply *EchoResponse) error {
+	reply.Message = s.name
+	return nil
+}
+
+func TestConnManager(t *testing.T) {
+	reg := registry.NewInMemoryRegistry()
+	startNode := func(name, addr string) *server.Server {
+		srv := server.NewServer(server.WithRegistry(reg))
+		if err := srv.Register(&NodeService{name: name}); err != nil {
+			t.Fatalf("注册服务失败: %v", err)
+		}
+		go srv.Start(addr)
+		return srv
+	}
+	srvA := startNode("A", "127.0.0.1:8915")
+	srvB := startNode("B", "127.0.0.1:8916")
+	time.Sleep(time.Second)
+	defer srvA.Close()
+	defer srvB.Close()
+
+	for _, multiplex := range []bool{false, true} {
+		var opts []client.Option
+		name := "连接池"
+		if multiplex {
+			opts = append(opts, client.WithMultiplex())
+			name = "多路复用"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			cli := client.NewClient(reg, registry.NewRoundRobinBalancer(), opts...)
+			defer cli.Close()
+
+			// 轮询的请求分别发送到两个实例
+			nodes := make(map[string]int)
+			for i := 0; i < 10; i++ {
+				resp := &EchoResponse{}
+				if err := cli.Call(context.Background(), "NodeService.Name", &EchoRequest{}, resp); err != nil {
+					t.Fatalf("调用失败: %v", err)
+				}
+				nodes[resp.Message]++
+			}
+			if nodes["A"] != 5 || nodes["B"] != 5 {
+				t.Errorf("期望请求平均分配到两个实例, 实际: %v", nodes)
+			}
+			if !multiplex {
+				stats := cli.PoolStats()
+				if _, ok := stats["127.0.0.1:8915"]; !ok {
+					t.Errorf("缺少实例 A 的连接池: %v", stats)
+				}
+				if _, ok := stats["127.0.0.1:8916"]; !ok {
+					t.Errorf("缺少实例 B 的连接池: %v", stats)
+				}
+			}
+		})
+	}
+
+	t.Run("移除实例", func(t *testing.T) {
+		cli := client.NewClient(reg, registry.NewRoundRobinBalancer())
+		defer cli.Close()
+
+		for i := 0; i < 2; i++ {
+			if err := cli.Call(context.Background(), "NodeService.Name", &EchoRequest{}, &EchoResponse{}); err != nil {
+				t.Fatalf("调用失败: %v", err)
+			}
+		}
+
+		// 实例 A 下线后关闭其连接池
+		srvA.Close()
+		deadline := time.Now().Add(time.Second)
+		for {
+			if _, ok := cli.PoolStats()["127.0.0.1:8915"]; !ok {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("实例 A 的连接池未被移除: %v", cli.PoolStats())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		for i := 0; i < 4; i++ {
+			resp := &EchoResponse{}
+			if err := cli.Call(context.Background(), "NodeService.Name", &EchoRequest{}, resp); err != nil {
+				t.Fatalf("调用失败: %v", err)
+			}
+			if resp.Message != "B" {
+				t.Errorf("期望由实例 B 处理, 实际: %s", resp.Message)
+			}
+		}
+	})
+
+	t.Run("不可用的实例", func(t *testing.T) {
+		// 第一个实例无法连接时只影响发送到该实例的请求
+		if err := reg.Register(&registry.ServiceInstance{
+			ID:        "NodeService-dead",
+			Name:      "NodeService",
+			Endpoints: []string{"127.0.0.1:8909"},
+		}); err != nil {
+			t.Fatalf("注册实例失败: %v", err)
+		}
+		defer reg.Deregister("NodeService-dead")
+
+		cli := client.NewClient(reg, registry.NewRoundRobinBalancer())
+		defer cli.Close()
+
+		var succeeded, failed int
+		for i := 0; i < 10; i++ {
+			if err := cli.Call(context.Background(), "NodeService.Name", &EchoRequest{}, &EchoResponse{}); err != nil {
+				failed++
+			} else {
+				succeeded++
+			}
+		}
+		if succeeded != 5 || failed != 5 {
+			t.Errorf("期望一半的请求成功, 实际成功 %d 次失败 %d 次", succeeded, failed)
+		}
+	})
+}
+
+func TestMuxDialContext(t *testing.T) {
+	// 只接受连接不完成 TLS 握手的服务端, 记录客户端放弃连接的时间
+	ln, err := net.Listen("tcp", "127.0.0.1:8919")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	defer ln.Close()
+	abandoned := make(chan time.Time, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(io.Discard, conn)
+		abandoned <- time.Now()
+	}()
+
+	reg := registry.NewInMemoryRegistry()
+	if err := reg.Register(&registry.ServiceInstance{
+		ID:        "StallService-1",
+		Name:      "StallService",
+		Endpoints: []string{"127.0.0.1:8919"},
+	}); err != nil {
+		t.Fatalf("注册实例失败: %v", err)
+	}
+	cli := client.NewClient(reg, registry.NewRandomBalancer(), client.WithMultiplex(), client.WithTLS(&tls.Config{InsecureSkipVerify: true}))
+	defer cli.Close()
+
+	// 建立连接使用调用的上下文, 超时后放弃握手, 不会一直等到握手超时
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := cli.Call(ctx, "StallService.Echo", &EchoRequest{}, &EchoResponse{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("期望超时, 实际: %v", err)
+	}
+	select {
+	case at := <-abandoned:
+		if elapsed := at.Sub(start); elapsed > time.Second {
+			t.Errorf("超时后没有及时放弃建立连接, 耗时: %v", elapsed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("超时后没有放弃建立连接")
+	}
+}
@@ -0,0 +1,150 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/client"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/server"
+	"github.com/eason-lee/l-rpc/status"
+)
+
+func TestFallback(t *testing.T) {
+	reg := registry.NewInMemoryRegistry()
+	srv := server.NewServer(server.WithRegistry(reg))
+	if err := srv.Register(&EchoService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+	if err := srv.Register(&StatusService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+
+	go srv.Start("127.0.0.1:8908")
+	time.Sleep(time.Second)
+	defer srv.Close()
+
+	// 实例全部下线后服务没有可用实例
+	if err := reg.Register(&registry.ServiceInstance{
+		ID:        "MissingService-1",
+		Name:      "MissingService",
+		Endpoints: []string{"127.0.0.1:8909"},
+	}); err != nil {
+		t.Fatalf("注册实例失败: %v", err)
+	}
+	if err := reg.Deregister("MissingService-1"); err != nil {
+		t.Fatalf("注销实例失败: %v", err)
+	}
+
+	errFallback := errors.New("fallback failed")
+	var cli *client.Client
+	cli = client.NewClient(reg, registry.NewRandomBalancer(),
+		client.WithMultiplex(),
+		// 返回默认值
+		client.WithFallback("MissingService", func(ctx context.Context, call *client.Call, err error) error {
+			call.Reply.(*EchoResponse).Message = "default"
+			return nil
+		}),
+		// 从未注册过的服务
+		client.WithFallback("UnknownService", func(ctx context.Context, call *client.Call, err error) error {
+			call.Reply.(*EchoResponse).Message = "unknown"
+			return nil
+		}),
+		// 方法级别优先于服务级别
+		client.WithFallback("MissingService.Fail", func(ctx context.Context, call *client.Call, err error) error {
+			return errFallback
+		}),
+		// 调用其他方法
+		client.WithFallback("EchoService.Delay", func(ctx context.Context, call *client.Call, err error) error {
+			ctx, cancel := context.WithTimeout(ctx, time.Second)
+			defer cancel()
+			req := call.Args.(*DelayRequest)
+			return cli.Call(ctx, "EchoService.Upper", &EchoRequest{Message: req.Message}, call.Reply)
+		}),
+		client.WithFallback("StatusService", func(ctx context.Context, call *client.Call, err error) error {
+			t.Errorf("业务错误不应触发降级: %v", err)
+			return nil
+		}),
+	)
+	defer cli.Close()
+
+	t.Run("没有可用实例", func(t *testing.T) {
+		var cause error
+		resp := &EchoResponse{}
+		err := cli.Call(context.Background(), "MissingService.Get", &EchoRequest{}, resp, client.OnDegraded(func(err error) {
+			cause = err
+		}))
+		if err != nil {
+			t.Fatalf("期望降级成功, 实际: %v", err)
+		}
+		if resp.Message != "default" {
+			t.Errorf("期望默认值, 实际: %s", resp.Message)
+		}
+		if !errors.Is(cause, registry.ErrNoAvailableInstances) {
+			t.Errorf("期望降级原因为没有可用实例, 实际: %v", cause)
+		}
+
+		// 异步调用通过 Degraded 字段区分
+		call := <-cli.Go("MissingService.Get", &EchoRequest{}, &EchoResponse{}, make(chan *client.Call, 1)).Done
+		if call.Error != nil || !call.Degraded {
+			t.Errorf("期望降级结果, 实际: degraded=%v, err=%v", call.Degraded, call.Error)
+		}
+	})
+
+	t.Run("服务未注册", func(t *testing.T) {
+		var cause error
+		resp := &EchoResponse{}
+		err := cli.Call(context.Background(), "UnknownService.Get", &EchoRequest{}, resp, client.OnDegraded(func(err error) {
+			cause = err
+		}))
+		if err != nil {
+			t.Fatalf("期望降级成功, 实际: %v", err)
+		}
+		if resp.Message != "unknown" {
+			t.Errorf("期望默认值, 实际: %s", resp.Message)
+		}
+		if !errors.Is(cause, registry.ErrServiceNotFound) {
+			t.Errorf("期望降级原因为服务未注册, 实际: %v", cause)
+		}
+	})
+
+	t.Run("降级失败", func(t *testing.T) {
+		call := <-cli.Go("MissingService.Fail", &EchoRequest{}, &EchoResponse{}, make(chan *client.Call, 1)).Done
+		if !errors.Is(call.Error, errFallback) {
+			t.Errorf("期望返回降级处理函数的错误, 实际: %v", call.Error)
+		}
+		if call.Degraded {
+			t.Error("降级失败时不应标记为降级")
+		}
+	})
+
+	t.Run("调用超时", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		degraded := false
+		resp := &EchoResponse{}
+		start := time.Now()
+		err := cli.Call(ctx, "EchoService.Delay", &DelayRequest{Message: "slow", Delay: time.Second}, resp, client.OnDegraded(func(err error) {
+			degraded = true
+		}))
+		if err != nil {
+			t.Fatalf("期望降级成功, 实际: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("降级不应等待原调用完成, 耗时: %v", elapsed)
+		}
+		if !degraded || resp.Message != "SLOW" {
+			t.Errorf("期望降级调用 Upper, 实际: degraded=%v, message=%s", degraded, resp.Message)
+		}
+	})
+
+	t.Run("业务错误不降级", func(t *testing.T) {
+		call := <-cli.Go("StatusService.Validate", &EchoRequest{}, &EchoResponse{}, make(chan *client.Call, 1)).Done
+		if status.CodeOf(call.Error) != status.InvalidArgument || call.Degraded {
+			t.Errorf("期望原始业务错误, 实际: degraded=%v, err=%v", call.Degraded, call.Error)
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/client"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/server"
+	"github.com/eason-lee/l-rpc/transport"
+)
+
+func TestHeartbeat(t *testing.T) {
+	reg := registry.NewInMemoryRegistry()
+	// 服务端也主动发送心跳, 客户端的读协程需要回复 pong
+	srv := server.NewServer(
+		server.WithRegistry(reg),
+		server.WithTransportOptions(transport.WithHeartbeat(20*time.Millisecond, 100*time.Millisecond)),
+	)
+	if err := srv.Register(&EchoService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+	go srv.Start("127.0.0.1:8917")
+	time.Sleep(time.Second)
+	defer srv.Close()
+
+	// 连接池中的空闲连接不回复 pong, 只有多路复用连接可以配合服务端的心跳
+	cli := client.NewClient(reg, registry.NewRandomBalancer(), client.WithMultiplex(),
+		client.WithTransportOptions(transport.WithHeartbeat(20*time.Millisecond, 100*time.Millisecond)))
+	defer cli.Close()
+
+	call := func() {
+		t.Helper()
+		resp := &EchoResponse{}
+		if err := cli.Call(context.Background(), "EchoService.Echo", &EchoRequest{Message: "ping"}, resp); err != nil {
+			t.Fatalf("调用失败: %v", err)
+		}
+		if resp.Message != "ping" {
+			t.Fatalf("响应内容错误: %s", resp.Message)
+		}
+	}
+
+	// 经过多个心跳周期后连接仍然可用
+	call()
+	time.Sleep(200 * time.Millisecond)
+	call()
+
+	// 客户端连接状态中可以看到心跳的往返时间
+	stats, ok := cli.ConnStats()["127.0.0.1:8917"]
+	if !ok {
+		t.Fatalf("缺少连接状态: %v", cli.ConnStats())
+	}
+	if stats.RTT <= 0 || stats.RTT > 100*time.Millisecond {
+		t.Errorf("心跳往返时间异常: %v", stats.RTT)
+	}
+}
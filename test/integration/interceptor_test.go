@@ -0,0 +1,231 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/client"
+	"github.com/eason-lee/l-rpc/protocol"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/server"
+)
+
+// PanicService 方法执行时触发 panic
+type PanicService struct{}
+
+func (s *PanicService) Panic(ctx context.Context, req *EchoRequest, reply *EchoResponse) error {
+	panic("boom")
+}
+
+func TestServerInterceptor(t *testing.T) {
+	reg := registry.NewInMemoryRegistry()
+	srv := server.NewServer()
+	if err := srv.Register(&EchoService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+	if err := srv.Register(&PanicService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+
+	var (
+		mu    sync.Mutex
+		trace []string
+	)
+	record := func(name string) server.UnaryInterceptor {
+		return func(ctx context.Context, header *protocol.Header, args interface{}, next server.UnaryHandler) (interface{}, error) {
+			mu.Lock()
+			trace = append(trace, name+":"+header.MethodName)
+			mu.Unlock()
+			return next(ctx, args)
+		}
+	}
+	auth := func(ctx context.Context, header *protocol.Header, args interface{}, next server.UnaryHandler) (interface{}, error) {
+		if req, ok := args.(*EchoRequest); ok && req.Message == "deny" {
+			return nil, errors.New("permission denied")
+		}
+		return next(ctx, args)
+	}
+	srv.Use(server.Recovery(), record("first"), record("second"), auth)
+
+	for _, name := range []string{"EchoService", "PanicService"} {
+		instance := &registry.ServiceInstance{
+			ID:        name,
+			Name:      name,
+			Endpoints: []string{"127.0.0.1:8892"},
+		}
+		if err := reg.Register(instance); err != nil {
+			t.Fatalf("注册实例失败: %v", err)
+		}
+	}
+
+	go srv.Start(":8892")
+	time.Sleep(time.Second)
+
+	cli := client.NewClient(reg, registry.NewRandomBalancer())
+	defer cli.Close()
+
+	t.Run("按顺序执行", func(t *testing.T) {
+		req := &EchoRequest{Message: "hello"}
+		resp := &EchoResponse{}
+		if err := cli.Call(context.Background(), "EchoService.Echo", req, resp); err != nil {
+			t.Fatalf("调用失败: %v", err)
+		}
+		if resp.Message != req.Message {
+			t.Errorf("响应不匹配, 期望: %s, 实际: %s", req.Message, resp.Message)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.Join(trace, ",") != "first:Echo,second:Echo" {
+			t.Errorf("拦截器执行顺序错误: %v", trace)
+		}
+	})
+
+	t.Run("中断调用", func(t *testing.T) {
+		err := cli.Call(context.Background(), "EchoService.Echo", &EchoRequest{Message: "deny"}, &EchoResponse{})
+		if err == nil || err.Error() != "permission denied" {
+			t.Fatalf("期望拦截器返回错误, 实际: %v", err)
+		}
+	})
+
+	t.Run("捕获panic", func(t *testing.T) {
+		err := cli.Call(context.Background(), "PanicService.Panic", &EchoRequest{}, &EchoResponse{})
+		if err == nil || !strings.Contains(err.Error(), "boom") {
+			t.Fatalf("期望 panic 转换为错误, 实际: %v", err)
+		}
+	})
+}
+
+func TestClientInterceptor(t *testing.T) {
+	reg := registry.NewInMemoryRegistry()
+	srv := server.NewServer()
+	if err := srv.Register(&EchoService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+
+	// 服务端校验客户端拦截器注入的令牌
+	var failures int32
+	srv.Use(func(ctx context.Context, header *protocol.Header, args interface{}, next server.UnaryHandler) (interface{}, error) {
+		if header.Metadata["token"] != "secret" {
+			return nil, errors.New("unauthenticated")
+		}
+		if header.Metadata["fail"] != "" && atomic.AddInt32(&failures, 1) == 1 {
+			return nil, errors.New("temporary failure")
+		}
+		return next(ctx, args)
+	})
+
+	instance := &registry.ServiceInstance{
+		ID:        "echo-1",
+		Name:      "EchoService",
+		Endpoints: []string{"127.0.0.1:8893"},
+	}
+	if err := reg.Register(instance); err != nil {
+		t.Fatalf("注册实例失败: %v", err)
+	}
+
+	go srv.Start(":8893")
+	time.Sleep(time.Second)
+
+	var (
+		mu        sync.Mutex
+		attempts  int
+		instances []string
+	)
+	cli := client.NewClient(reg, registry.NewRandomBalancer(), client.WithMultiplex())
+	defer cli.Close()
+	cli.Use(
+		// 拒绝空消息
+		func(ctx context.Context, call *client.Call, next client.Invoker) error {
+			if req, ok := call.Args.(*EchoRequest); ok && req.Message == "" {
+				return errors.New("empty message")
+			}
+			return next(ctx, call)
+		},
+		// 注入令牌
+		func(ctx context.Context, call *client.Call, next client.Invoker) error {
+			call.Metadata["token"] = "secret"
+			return next(ctx, call)
+		},
+		// 失败后重试一次并记录选中的实例
+		func(ctx context.Context, call *client.Call, next client.Invoker) error {
+			var err error
+			for i := 0; i < 2; i++ {
+				mu.Lock()
+				attempts++
+				mu.Unlock()
+				if err = next(ctx, call); err == nil {
+					break
+				}
+			}
+			mu.Lock()
+			instances = append(instances, call.Instance.ID)
+			mu.Unlock()
+			return err
+		},
+	)
+
+	t.Run("同步调用", func(t *testing.T) {
+		req := &EchoRequest{Message: "hello"}
+		resp := &EchoResponse{}
+		if err := cli.Call(context.Background(), "EchoService.Echo", req, resp); err != nil {
+			t.Fatalf("调用失败: %v", err)
+		}
+		if resp.Message != req.Message {
+			t.Errorf("响应不匹配, 期望: %s, 实际: %s", req.Message, resp.Message)
+		}
+	})
+
+	t.Run("异步调用", func(t *testing.T) {
+		req := &EchoRequest{Message: "world"}
+		resp := &EchoResponse{}
+		call := <-cli.Go("EchoService.Echo", req, resp, make(chan *client.Call, 1)).Done
+		if call.Error != nil {
+			t.Fatalf("调用失败: %v", call.Error)
+		}
+		if call.Instance == nil || call.Instance.ID != instance.ID {
+			t.Errorf("未记录选中的实例: %v", call.Instance)
+		}
+	})
+
+	t.Run("重试", func(t *testing.T) {
+		mu.Lock()
+		attempts = 0
+		mu.Unlock()
+
+		req := &EchoRequest{Message: "retry"}
+		resp := &EchoResponse{}
+		call := <-cli.Go("EchoService.Echo", req, resp, make(chan *client.Call, 1), func(call *client.Call) {
+			call.Metadata["fail"] = "once"
+		}).Done
+		if call.Error != nil {
+			t.Fatalf("调用失败: %v", call.Error)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if attempts != 2 {
+			t.Errorf("期望重试一次, 实际尝试次数: %d", attempts)
+		}
+	})
+
+	t.Run("拒绝调用", func(t *testing.T) {
+		err := cli.Call(context.Background(), "EchoService.Echo", &EchoRequest{}, &EchoResponse{})
+		if err == nil || err.Error() != "empty message" {
+			t.Fatalf("期望拦截器拒绝调用, 实际: %v", err)
+		}
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, id := range instances {
+		if id != instance.ID {
+			t.Errorf("拦截器获取到错误的实例: %s", id)
+		}
+	}
+}
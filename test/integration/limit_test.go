@@ -0,0 +1,144 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/client"
+	"github.com/eason-lee/l-rpc/limiter"
+	"github.com/eason-lee/l-rpc/metadata"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/server"
+	"github.com/eason-lee/l-rpc/status"
+)
+
+// startLimitServer 启动带限流配置的 EchoService
+func startLimitServer(t *testing.T, addr string, opts ...server.Option) (*server.Server, *registry.MemoryRegistry) {
+	reg := registry.NewInMemoryRegistry()
+	srv := server.NewServer(append(opts, server.WithRegistry(reg))...)
+	if err := srv.Register(&EchoService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+	go srv.Start(addr)
+	time.Sleep(time.Second)
+	return srv, reg
+}
+
+// concurrentDelay 同时发起 n 个耗时调用, 返回被拒绝的调用数
+func concurrentDelay(cli *client.Client, n int, delay time.Duration, target error) (int, error) {
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- cli.Call(context.Background(), "EchoService.Delay", &DelayRequest{Message: "x", Delay: delay}, &EchoResponse{})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var rejected int
+	for err := range errs {
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, target) || status.CodeOf(err) != status.ResourceExhausted {
+			return rejected, err
+		}
+		rejected++
+	}
+	return rejected, nil
+}
+
+func TestRateLimit(t *testing.T) {
+	srv, reg := startLimitServer(t, "127.0.0.1:8906", server.WithLimit(
+		server.LimitRule{Service: "EchoService", Method: "Echo", Rate: 1, Burst: 2},
+		server.LimitRule{Service: "EchoService", Method: "Upper", MetadataKey: "caller", Rate: 1, Burst: 1},
+		server.LimitRule{Service: "EchoService", Method: "Delay", MaxConcurrency: 2},
+	))
+	defer srv.Close()
+
+	cli := client.NewClient(reg, registry.NewRandomBalancer(), client.WithMultiplex())
+	defer cli.Close()
+
+	t.Run("按方法限速", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			if err := cli.Call(context.Background(), "EchoService.Echo", &EchoRequest{Message: "x"}, &EchoResponse{}); err != nil {
+				t.Fatalf("第 %d 次调用失败: %v", i, err)
+			}
+		}
+		err := cli.Call(context.Background(), "EchoService.Echo", &EchoRequest{Message: "x"}, &EchoResponse{})
+		if status.CodeOf(err) != status.ResourceExhausted || !errors.Is(err, server.ErrRateLimited) {
+			t.Errorf("期望限速错误, 实际: %v", err)
+		}
+	})
+
+	t.Run("按调用方限速", func(t *testing.T) {
+		call := func(caller string) error {
+			ctx := metadata.AppendToOutgoingContext(context.Background(), "caller", caller)
+			return cli.Call(ctx, "EchoService.Upper", &EchoRequest{Message: "x"}, &EchoResponse{})
+		}
+		if err := call("a"); err != nil {
+			t.Fatalf("调用失败: %v", err)
+		}
+		if err := call("a"); !errors.Is(err, server.ErrRateLimited) {
+			t.Errorf("期望调用方 a 被限速, 实际: %v", err)
+		}
+		if err := call("b"); err != nil {
+			t.Errorf("调用方 b 不应受调用方 a 影响: %v", err)
+		}
+	})
+
+	t.Run("并发数限制", func(t *testing.T) {
+		rejected, err := concurrentDelay(cli, 3, 200*time.Millisecond, server.ErrConcurrencyLimited)
+		if err != nil {
+			t.Fatalf("调用失败: %v", err)
+		}
+		if rejected != 1 {
+			t.Errorf("期望拒绝 1 个调用, 实际: %d", rejected)
+		}
+
+		// 请求结束后释放名额
+		if err := cli.Call(context.Background(), "EchoService.Delay", &DelayRequest{Message: "x"}, &EchoResponse{}); err != nil {
+			t.Errorf("名额释放后调用失败: %v", err)
+		}
+	})
+}
+
+func TestAdaptiveLimit(t *testing.T) {
+	srv, reg := startLimitServer(t, "127.0.0.1:8907", server.WithAdaptiveLimit(limiter.AdaptiveConfig{
+		TargetLatency: 20 * time.Millisecond,
+		MinLimit:      1,
+		InitialLimit:  4,
+		Smoothing:     1,
+		Backoff:       0.5,
+	}))
+	defer srv.Close()
+
+	cli := client.NewClient(reg, registry.NewRandomBalancer(), client.WithMultiplex())
+	defer cli.Close()
+
+	// 初始上限足够时不拒绝
+	rejected, err := concurrentDelay(cli, 2, 50*time.Millisecond, server.ErrOverloaded)
+	if err != nil || rejected != 0 {
+		t.Fatalf("期望全部成功, 实际拒绝 %d 个: %v", rejected, err)
+	}
+
+	// 延迟持续超过期望值后上限降到 1
+	for i := 0; i < 3; i++ {
+		if err := cli.Call(context.Background(), "EchoService.Delay", &DelayRequest{Message: "x", Delay: 50 * time.Millisecond}, &EchoResponse{}); err != nil {
+			t.Fatalf("调用失败: %v", err)
+		}
+	}
+	rejected, err = concurrentDelay(cli, 2, 50*time.Millisecond, server.ErrOverloaded)
+	if err != nil {
+		t.Fatalf("调用失败: %v", err)
+	}
+	if rejected != 1 {
+		t.Errorf("期望过载时拒绝 1 个调用, 实际: %d", rejected)
+	}
+}
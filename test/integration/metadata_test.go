@@ -0,0 +1,60 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/client"
+	"github.com/eason-lee/l-rpc/metadata"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/server"
+)
+
+// MetadataService 返回请求中携带的元数据
+type MetadataService struct{}
+
+type MetadataRequest struct {
+	Key string
+}
+
+func (s *MetadataService) Get(ctx context.Context, req *MetadataRequest, reply *EchoResponse) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	reply.Message = md.Get(req.Key)
+	return nil
+}
+
+func TestMetadataPropagation(t *testing.T) {
+	reg := registry.NewInMemoryRegistry()
+	srv := server.NewServer()
+	if err := srv.Register(&MetadataService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+
+	instance := &registry.ServiceInstance{
+		Name:      "MetadataService",
+		Endpoints: []string{"127.0.0.1:8894"},
+	}
+	if err := reg.Register(instance); err != nil {
+		t.Fatalf("注册实例失败: %v", err)
+	}
+
+	go srv.Start(":8894")
+	time.Sleep(time.Second)
+
+	cli := client.NewClient(reg, registry.NewRandomBalancer())
+	defer cli.Close()
+
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("tenant", "t1"))
+	ctx = metadata.AppendToOutgoingContext(ctx, "trace_id", "abc")
+
+	for key, want := range map[string]string{"tenant": "t1", "trace_id": "abc", "missing": ""} {
+		resp := &EchoResponse{}
+		if err := cli.Call(ctx, "MetadataService.Get", &MetadataRequest{Key: key}, resp); err != nil {
+			t.Fatalf("调用失败: %v", err)
+		}
+		if resp.Message != want {
+			t.Errorf("元数据 %s 不匹配, 期望: %s, 实际: %s", key, want, resp.Message)
+		}
+	}
+}
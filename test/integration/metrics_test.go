@@ -0,0 +1,102 @@
+package integration
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/client"
+	"github.com/eason-lee/l-rpc/metrics"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/server"
+	"github.com/eason-lee/l-rpc/status"
+)
+
+func TestMetrics(t *testing.T) {
+	serverMetrics := metrics.NewServerMetrics()
+	clientMetrics := metrics.NewClientMetrics()
+
+	reg := registry.NewInMemoryRegistry()
+	srv := server.NewServer(server.WithRegistry(reg), server.WithMetrics(serverMetrics))
+	if err := srv.Register(&EchoService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+	if err := srv.Register(&StatusService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+
+	go srv.Start("127.0.0.1:8911")
+	time.Sleep(time.Second)
+	defer srv.Close()
+
+	cli := client.NewClient(reg, registry.NewRandomBalancer(),
+		client.WithMetrics(clientMetrics),
+		client.WithFallback("EchoService.Delay", func(ctx context.Context, call *client.Call, err error) error {
+			return nil
+		}),
+	)
+	defer cli.Close()
+
+	r := metrics.NewRegistry()
+	r.Register(serverMetrics, clientMetrics, metrics.NewRegistryCollector(reg), metrics.NewPoolCollector(cli.PoolStats))
+	scraper := httptest.NewServer(metrics.Handler(r))
+	defer scraper.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := cli.Call(context.Background(), "EchoService.Echo", &EchoRequest{Message: "hello"}, &EchoResponse{}); err != nil {
+			t.Fatalf("调用失败: %v", err)
+		}
+	}
+	if err := cli.Call(context.Background(), "StatusService.Validate", &EchoRequest{}, &EchoResponse{}); err == nil {
+		t.Fatal("期望调用失败")
+	}
+	// 未注册的服务和方法在服务端指标中记为 unknown
+	if err := reg.Register(&registry.ServiceInstance{ID: "Ghost-1", Name: "Ghost", Endpoints: []string{"127.0.0.1:8911"}}); err != nil {
+		t.Fatalf("注册实例失败: %v", err)
+	}
+	for _, method := range []string{"EchoService.Missing", "Ghost.Missing"} {
+		if err := cli.Call(context.Background(), method, &EchoRequest{}, &EchoResponse{}); status.CodeOf(err) != status.NotFound {
+			t.Fatalf("期望 NotFound, 实际: %v", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := cli.Call(ctx, "EchoService.Delay", &DelayRequest{Delay: 200 * time.Millisecond}, &EchoResponse{}); err != nil {
+		t.Fatalf("期望降级成功, 实际: %v", err)
+	}
+	// 等待服务端执行完超时的请求
+	time.Sleep(300 * time.Millisecond)
+
+	resp, err := http.Get(scraper.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("抓取指标失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != metrics.ContentType {
+		t.Errorf("Content-Type 错误: %s", ct)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	text := string(body)
+
+	for _, want := range []string{
+		`lrpc_client_requests_total{service="EchoService",method="Echo"} 2`,
+		`lrpc_server_requests_total{service="EchoService",method="Echo"} 2`,
+		`lrpc_client_errors_total{service="StatusService",method="Validate",code="InvalidArgument"} 1`,
+		`lrpc_server_errors_total{service="StatusService",method="Validate",code="InvalidArgument"} 1`,
+		`lrpc_client_degraded_total{service="EchoService",method="Delay"} 1`,
+		`lrpc_client_request_duration_seconds_count{service="EchoService",method="Echo"} 2`,
+		`lrpc_server_requests_in_flight{service="EchoService",method="Echo"} 0`,
+		`lrpc_registry_instance_up{service="EchoService",instance=`,
+		`lrpc_pool_max_active_connections{endpoint="127.0.0.1:8911"}`,
+		`lrpc_server_errors_total{service="EchoService",method="unknown",code="NotFound"} 1`,
+		`lrpc_server_errors_total{service="unknown",method="unknown",code="NotFound"} 1`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("指标中缺少 %s\n%s", want, text)
+		}
+	}
+}
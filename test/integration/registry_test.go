@@ -0,0 +1,120 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/client"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/server"
+)
+
+func TestSelfRegistration(t *testing.T) {
+	reg := registry.NewInMemoryRegistry()
+	srv := server.NewServer(
+		server.WithRegistry(reg),
+		server.WithVersion("1.2.0"),
+		server.WithMetadata(map[string]string{"zone": "a"}),
+		server.WithHeartbeatInterval(100*time.Millisecond),
+	)
+	if err := srv.Register(&EchoService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+
+	go srv.Start("127.0.0.1:8898")
+	time.Sleep(time.Second)
+
+	// 启动后注册的服务同样自动注册实例
+	if err := srv.Register(&MetadataService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+
+	for _, name := range []string{"EchoService", "MetadataService"} {
+		instances, err := reg.GetService(name)
+		if err != nil {
+			t.Fatalf("获取服务 %s 失败: %v", name, err)
+		}
+		if len(instances) != 1 {
+			t.Fatalf("期望服务 %s 有 1 个实例, 实际: %d", name, len(instances))
+		}
+		instance := instances[0]
+		if instance.Endpoints[0] != "127.0.0.1:8898" {
+			t.Errorf("实例地址错误: %v", instance.Endpoints)
+		}
+		if instance.Version != "1.2.0" || instance.Metadata["zone"] != "a" {
+			t.Errorf("实例版本或元数据错误: %s %v", instance.Version, instance.Metadata)
+		}
+	}
+
+	cli := client.NewClient(reg, registry.NewRandomBalancer())
+	defer cli.Close()
+
+	req := &EchoRequest{Message: "hello"}
+	resp := &EchoResponse{}
+	if err := cli.Call(context.Background(), "EchoService.Echo", req, resp); err != nil {
+		t.Fatalf("调用失败: %v", err)
+	}
+	if resp.Message != req.Message {
+		t.Errorf("响应不匹配, 期望: %s, 实际: %s", req.Message, resp.Message)
+	}
+
+	// 关闭后实例被注销
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("关闭服务失败: %v", err)
+	}
+	for _, name := range []string{"EchoService", "MetadataService"} {
+		instances, err := reg.GetService(name)
+		if err != nil {
+			t.Fatalf("获取服务 %s 失败: %v", name, err)
+		}
+		if len(instances) != 0 {
+			t.Errorf("关闭后服务 %s 仍有实例: %d", name, len(instances))
+		}
+	}
+}
+
+// blockingHeartbeatRegistry 续约时等待测试放行, 用于模拟续约与关闭并发执行
+type blockingHeartbeatRegistry struct {
+	*registry.MemoryRegistry
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (r *blockingHeartbeatRegistry) Heartbeat(instanceID string) error {
+	select {
+	case r.entered <- struct{}{}:
+		<-r.release
+	default:
+	}
+	return r.MemoryRegistry.Heartbeat(instanceID)
+}
+
+func TestNoRegistrationAfterClose(t *testing.T) {
+	reg := &blockingHeartbeatRegistry{
+		MemoryRegistry: registry.NewInMemoryRegistry(),
+		entered:        make(chan struct{}),
+		release:        make(chan struct{}),
+	}
+	srv := server.NewServer(server.WithRegistry(reg), server.WithHeartbeatInterval(50*time.Millisecond))
+	if err := srv.Register(&EchoService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+	go srv.Start("127.0.0.1:8918")
+
+	// 续约进行中时关闭服务, 续约因实例已注销而失败后不再重新注册
+	<-reg.entered
+	srv.Close()
+	close(reg.release)
+	time.Sleep(200 * time.Millisecond)
+
+	instances, err := reg.GetService("EchoService")
+	if err != nil {
+		t.Fatalf("获取服务失败: %v", err)
+	}
+	if len(instances) != 0 {
+		t.Errorf("关闭后服务仍有实例: %d", len(instances))
+	}
+}
@@ -0,0 +1,209 @@
+package integration
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/client"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/server"
+	"github.com/eason-lee/l-rpc/status"
+)
+
+// RetryService 前 failures 次调用返回 Unavailable
+type RetryService struct {
+	calls    atomic.Int64
+	failures atomic.Int64
+}
+
+func (s *RetryService) Flaky(ctx context.Context, req *EchoRequest, reply *EchoResponse) error {
+	if s.calls.Add(1) <= s.failures.Load() {
+		return status.Error(status.Unavailable, "try again")
+	}
+	reply.Message = req.Message
+	return nil
+}
+
+// Invalid 总是返回不可重试的错误
+func (s *RetryService) Invalid(ctx context.Context, req *EchoRequest, reply *EchoResponse) error {
+	return status.Error(status.InvalidArgument, "bad request")
+}
+
+func (s *RetryService) reset(failures int64) {
+	s.calls.Store(0)
+	s.failures.Store(failures)
+}
+
+// preferBalancer 优先选择指定的实例
+type preferBalancer struct {
+	id string
+}
+
+func (b *preferBalancer) Select(instances []*registry.ServiceInstance) (*registry.ServiceInstance, error) {
+	if len(instances) == 0 {
+		return nil, registry.ErrNoAvailableInstances
+	}
+	for _, instance := range instances {
+		if instance.ID == b.id {
+			return instance, nil
+		}
+	}
+	return instances[0], nil
+}
+
+func TestRetryPolicy(t *testing.T) {
+	reg := registry.NewInMemoryRegistry()
+	srv := server.NewServer(server.WithRegistry(reg))
+	svc := &RetryService{}
+	if err := srv.Register(svc); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+	if err := srv.Register(&EchoService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+
+	go srv.Start("127.0.0.1:8902")
+	time.Sleep(time.Second)
+	defer srv.Close()
+
+	policy := client.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+	idempotent := policy
+	idempotent.Idempotent = true
+
+	t.Run("排除失败的实例", func(t *testing.T) {
+		// 不可达的实例, 连接失败的请求一定没有被处理, 非幂等方法也可以重试
+		if err := reg.Register(&registry.ServiceInstance{
+			ID:        "EchoService-dead",
+			Name:      "EchoService",
+			Endpoints: []string{"127.0.0.1:8903"},
+		}); err != nil {
+			t.Fatalf("注册实例失败: %v", err)
+		}
+		defer reg.Deregister("EchoService-dead")
+
+		// 首次总是选中不可达的实例, 重试时必须排除它
+		cli := client.NewClient(reg, &preferBalancer{id: "EchoService-dead"}, client.WithMultiplex(), client.WithRetryPolicy(policy))
+		defer cli.Close()
+		call := <-cli.Go("EchoService.Echo", &EchoRequest{Message: "hello"}, &EchoResponse{}, make(chan *client.Call, 1)).Done
+		if call.Error != nil {
+			t.Fatalf("调用失败: %v", call.Error)
+		}
+		if call.Instance.ID == "EchoService-dead" {
+			t.Errorf("重试时仍选中了不可达的实例")
+		}
+	})
+
+	t.Run("非幂等方法不重试", func(t *testing.T) {
+		svc.reset(1)
+		cli := client.NewClient(reg, registry.NewRandomBalancer(), client.WithRetryPolicy(policy))
+		defer cli.Close()
+
+		err := cli.Call(context.Background(), "RetryService.Flaky", &EchoRequest{Message: "x"}, &EchoResponse{})
+		if status.CodeOf(err) != status.Unavailable {
+			t.Errorf("期望 Unavailable, 实际: %v", err)
+		}
+		if n := svc.calls.Load(); n != 1 {
+			t.Errorf("期望只调用 1 次, 实际: %d", n)
+		}
+	})
+
+	t.Run("幂等方法重试", func(t *testing.T) {
+		svc.reset(2)
+		cli := client.NewClient(reg, registry.NewRandomBalancer(),
+			client.WithRetryPolicy(policy),
+			client.WithMethodRetryPolicy("RetryService.Flaky", idempotent),
+		)
+		defer cli.Close()
+
+		resp := &EchoResponse{}
+		if err := cli.Call(context.Background(), "RetryService.Flaky", &EchoRequest{Message: "x"}, resp); err != nil {
+			t.Fatalf("调用失败: %v", err)
+		}
+		if resp.Message != "x" {
+			t.Errorf("响应不匹配: %s", resp.Message)
+		}
+		if n := svc.calls.Load(); n != 3 {
+			t.Errorf("期望调用 3 次, 实际: %d", n)
+		}
+	})
+
+	t.Run("不可重试的错误码", func(t *testing.T) {
+		svc.reset(1)
+		p := idempotent
+		p.RetryableCodes = []status.Code{status.ResourceExhausted}
+		cli := client.NewClient(reg, registry.NewRandomBalancer(), client.WithMethodRetryPolicy("RetryService", p))
+		defer cli.Close()
+
+		cli.Call(context.Background(), "RetryService.Flaky", &EchoRequest{Message: "x"}, &EchoResponse{})
+		if n := svc.calls.Load(); n != 1 {
+			t.Errorf("期望只调用 1 次, 实际: %d", n)
+		}
+	})
+
+	t.Run("截止时间不足以等待", func(t *testing.T) {
+		svc.reset(3)
+		p := idempotent
+		p.InitialBackoff = time.Second
+		p.MaxBackoff = 2 * time.Second
+		cli := client.NewClient(reg, registry.NewRandomBalancer(), client.WithRetryPolicy(p))
+		defer cli.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err := cli.Call(ctx, "RetryService.Flaky", &EchoRequest{Message: "x"}, &EchoResponse{})
+		if status.CodeOf(err) != status.Unavailable {
+			t.Errorf("期望返回最后一次的错误, 实际: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+			t.Errorf("不应等待注定超时的重试, 耗时: %v", elapsed)
+		}
+		if n := svc.calls.Load(); n != 1 {
+			t.Errorf("期望只调用 1 次, 实际: %d", n)
+		}
+	})
+
+	t.Run("重试预算", func(t *testing.T) {
+		svc.reset(100)
+		cli := client.NewClient(reg, registry.NewRandomBalancer(),
+			client.WithRetryPolicy(idempotent),
+			client.WithRetryBudget(4, 0.1),
+		)
+		defer cli.Close()
+
+		// 令牌从 4 开始, 每次失败消耗 1 个, 不超过一半后停止重试:
+		// 第一次调用重试一次, 之后的调用都只尝试一次
+		for i := 0; i < 3; i++ {
+			cli.Call(context.Background(), "RetryService.Flaky", &EchoRequest{Message: "x"}, &EchoResponse{})
+		}
+		if n := svc.calls.Load(); n != 4 {
+			t.Errorf("期望预算耗尽后不再重试, 共调用 4 次, 实际: %d", n)
+		}
+	})
+	t.Run("不可重试的失败不消耗预算", func(t *testing.T) {
+		svc.reset(1)
+		cli := client.NewClient(reg, registry.NewRandomBalancer(),
+			client.WithRetryPolicy(idempotent),
+			client.WithRetryBudget(4, 0.1),
+		)
+		defer cli.Close()
+
+		for i := 0; i < 5; i++ {
+			err := cli.Call(context.Background(), "RetryService.Invalid", &EchoRequest{Message: "x"}, &EchoResponse{})
+			if status.CodeOf(err) != status.InvalidArgument {
+				t.Fatalf("期望参数错误, 实际: %v", err)
+			}
+		}
+		if err := cli.Call(context.Background(), "RetryService.Flaky", &EchoRequest{Message: "x"}, &EchoResponse{}); err != nil {
+			t.Errorf("预算未被消耗, 期望重试成功, 实际: %v", err)
+		}
+	})
+}
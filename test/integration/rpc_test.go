@@ -2,10 +2,14 @@ package integration
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/eason-lee/l-rpc/client"
+	"github.com/eason-lee/l-rpc/codec"
 	"github.com/eason-lee/l-rpc/registry"
 	"github.com/eason-lee/l-rpc/server"
 )
@@ -21,12 +25,60 @@ type EchoResponse struct {
 	Message string
 }
 
+type DelayRequest struct {
+	Message string
+	Delay   time.Duration
+}
+
 // Echo 修改参数为指针类型
 func (s *EchoService) Echo(ctx context.Context, req *EchoRequest, reply *EchoResponse) error {
 	reply.Message = req.Message
 	return nil
 }
 
+// Upper 通过返回值传出响应
+func (s *EchoService) Upper(ctx context.Context, req *EchoRequest) (*EchoResponse, error) {
+	return &EchoResponse{Message: strings.ToUpper(req.Message)}, nil
+}
+
+// Delay 延迟指定时间后返回
+func (s *EchoService) Delay(ctx context.Context, req *DelayRequest, reply *EchoResponse) error {
+	time.Sleep(req.Delay)
+	reply.Message = req.Message
+	return nil
+}
+
+// unknownCodec 服务端未注册的序列化方式
+type unknownCodec struct {
+	codec.JSONCodec
+}
+
+func (c *unknownCodec) ContentType() string {
+	return "application/x-unknown"
+}
+
+// BlockService 阻塞直到请求上下文结束, 用于验证超时与取消的传递
+type BlockService struct {
+	done chan BlockResult
+}
+
+type BlockResult struct {
+	HasDeadline bool
+	Err         error
+}
+
+func (s *BlockService) Block(ctx context.Context, req *EchoRequest, reply *EchoResponse) error {
+	_, ok := ctx.Deadline()
+	select {
+	case <-ctx.Done():
+		s.done <- BlockResult{HasDeadline: ok, Err: ctx.Err()}
+		return ctx.Err()
+	case <-time.After(time.Second * 5):
+		s.done <- BlockResult{HasDeadline: ok}
+		return nil
+	}
+}
+
 func TestBasicRPC(t *testing.T) {
 	// 1. 启动服务端
 	reg := registry.NewInMemoryRegistry()
@@ -72,6 +124,36 @@ func TestBasicRPC(t *testing.T) {
 		}
 	})
 
+	t.Run("通过返回值传出响应", func(t *testing.T) {
+		resp := &EchoResponse{}
+		if err := cli.Call(context.Background(), "EchoService.Upper", &EchoRequest{Message: "hello"}, resp); err != nil {
+			t.Fatalf("调用失败: %v", err)
+		}
+		if resp.Message != "HELLO" {
+			t.Errorf("响应不匹配, 期望: HELLO, 实际: %s", resp.Message)
+		}
+	})
+
+	t.Run("指定序列化方式", func(t *testing.T) {
+		req := &EchoRequest{Message: "msgpack"}
+		resp := &EchoResponse{}
+
+		err := cli.Call(context.Background(), "EchoService.Echo", req, resp, client.UseCodec(codec.NewMsgpackCodec()))
+		if err != nil {
+			t.Fatalf("调用失败: %v", err)
+		}
+		if resp.Message != req.Message {
+			t.Errorf("响应不匹配, 期望: %s, 实际: %s", req.Message, resp.Message)
+		}
+	})
+
+	t.Run("不支持的序列化方式", func(t *testing.T) {
+		err := cli.Call(context.Background(), "EchoService.Echo", &EchoRequest{}, &EchoResponse{}, client.UseCodec(&unknownCodec{}))
+		if err == nil || !strings.Contains(err.Error(), codec.ErrUnsupportedCodec.Error()) {
+			t.Fatalf("期望不支持的序列化方式错误, 实际: %v", err)
+		}
+	})
+
 	t.Run("异步调用", func(t *testing.T) {
 		req := &EchoRequest{Message: "world"}
 		resp := &EchoResponse{}
@@ -92,3 +174,149 @@ func TestBasicRPC(t *testing.T) {
 		}
 	})
 }
+
+func TestMultiplexRPC(t *testing.T) {
+	reg := registry.NewInMemoryRegistry()
+	srv := server.NewServer()
+	if err := srv.Register(&EchoService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+
+	instance := &registry.ServiceInstance{
+		Name:      "EchoService",
+		Endpoints: []string{"127.0.0.1:8890"},
+	}
+	if err := reg.Register(instance); err != nil {
+		t.Fatalf("注册实例失败: %v", err)
+	}
+
+	go srv.Start(":8890")
+	time.Sleep(time.Second)
+
+	cli := client.NewClient(reg, registry.NewRandomBalancer(), client.WithMultiplex())
+	defer cli.Close()
+
+	t.Run("响应乱序返回", func(t *testing.T) {
+		slowResp := &EchoResponse{}
+		slow := cli.Go("EchoService.Delay", &DelayRequest{Message: "slow", Delay: 500 * time.Millisecond}, slowResp, make(chan *client.Call, 1))
+		fastResp := &EchoResponse{}
+		fast := cli.Go("EchoService.Delay", &DelayRequest{Message: "fast"}, fastResp, make(chan *client.Call, 1))
+
+		select {
+		case call := <-fast.Done:
+			if call.Error != nil {
+				t.Fatalf("调用失败: %v", call.Error)
+			}
+			if fastResp.Message != "fast" {
+				t.Errorf("响应不匹配, 期望: fast, 实际: %s", fastResp.Message)
+			}
+		case <-slow.Done:
+			t.Fatal("慢请求先于快请求返回")
+		case <-time.After(time.Second * 3):
+			t.Fatal("调用超时")
+		}
+
+		call := <-slow.Done
+		if call.Error != nil {
+			t.Fatalf("调用失败: %v", call.Error)
+		}
+		if slowResp.Message != "slow" {
+			t.Errorf("响应不匹配, 期望: slow, 实际: %s", slowResp.Message)
+		}
+	})
+
+	t.Run("并发调用", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				req := &EchoRequest{Message: fmt.Sprintf("msg-%d", i)}
+				resp := &EchoResponse{}
+				if err := cli.Call(context.Background(), "EchoService.Echo", req, resp); err != nil {
+					t.Errorf("调用失败: %v", err)
+					return
+				}
+				if resp.Message != req.Message {
+					t.Errorf("响应不匹配, 期望: %s, 实际: %s", req.Message, resp.Message)
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
+}
+
+func TestContextPropagation(t *testing.T) {
+	reg := registry.NewInMemoryRegistry()
+	srv := server.NewServer()
+	svc := &BlockService{done: make(chan BlockResult, 1)}
+	if err := srv.Register(svc); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+
+	instance := &registry.ServiceInstance{
+		Name:      "BlockService",
+		Endpoints: []string{"127.0.0.1:8891"},
+	}
+	if err := reg.Register(instance); err != nil {
+		t.Fatalf("注册实例失败: %v", err)
+	}
+
+	go srv.Start(":8891")
+	time.Sleep(time.Second)
+
+	clients := map[string]*client.Client{
+		"连接池模式":  client.NewClient(reg, registry.NewRandomBalancer()),
+		"多路复用模式": client.NewClient(reg, registry.NewRandomBalancer(), client.WithMultiplex()),
+	}
+
+	for name, cli := range clients {
+		t.Run(name, func(t *testing.T) {
+			defer cli.Close()
+
+			t.Run("截止时间传递", func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+				defer cancel()
+
+				err := cli.Call(ctx, "BlockService.Block", &EchoRequest{}, &EchoResponse{})
+				if err != context.DeadlineExceeded {
+					t.Fatalf("期望超时错误, 实际: %v", err)
+				}
+
+				select {
+				case result := <-svc.done:
+					if !result.HasDeadline {
+						t.Error("服务端上下文未设置截止时间")
+					}
+					if result.Err == nil {
+						t.Error("服务端上下文未结束")
+					}
+				case <-time.After(time.Second * 2):
+					t.Fatal("服务端请求未被取消")
+				}
+			})
+
+			t.Run("取消调用", func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(100*time.Millisecond, cancel)
+
+				err := cli.Call(ctx, "BlockService.Block", &EchoRequest{}, &EchoResponse{})
+				if err != context.Canceled {
+					t.Fatalf("期望取消错误, 实际: %v", err)
+				}
+
+				select {
+				case result := <-svc.done:
+					if result.HasDeadline {
+						t.Error("服务端上下文不应设置截止时间")
+					}
+					if result.Err != context.Canceled {
+						t.Errorf("期望服务端请求被取消, 实际: %v", result.Err)
+					}
+				case <-time.After(time.Second * 2):
+					t.Fatal("服务端请求未被取消")
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package integration
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/client"
+	"github.com/eason-lee/l-rpc/codec"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/server"
+)
+
+func TestRegisterName(t *testing.T) {
+	reg := registry.NewInMemoryRegistry()
+	srv := server.NewServer(server.WithRegistry(reg), server.WithVersion("1.0.0"))
+
+	svc := &BlockService{done: make(chan BlockResult, 1)}
+	if err := srv.RegisterName("Block", svc, server.WithMethodTimeout("Block", 200*time.Millisecond)); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+	if err := srv.RegisterName("EchoV2", &EchoService{},
+		server.WithServiceVersion("2.0.0"),
+		server.WithAllowedCodecs("application/x-msgpack"),
+	); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+	if err := srv.RegisterName("EchoV2", &EchoService{}); err == nil {
+		t.Fatal("重复的服务名应注册失败")
+	}
+
+	go srv.Start("127.0.0.1:8899")
+	time.Sleep(time.Second)
+	defer srv.Close()
+
+	instances, err := reg.GetService("EchoV2")
+	if err != nil || len(instances) != 1 {
+		t.Fatalf("获取服务失败: %v %v", instances, err)
+	}
+	if instances[0].Version != "2.0.0" {
+		t.Errorf("期望服务版本 2.0.0, 实际: %s", instances[0].Version)
+	}
+
+	cli := client.NewClient(reg, registry.NewRandomBalancer())
+	defer cli.Close()
+
+	t.Run("方法超时", func(t *testing.T) {
+		start := time.Now()
+		err := cli.Call(context.Background(), "Block.Block", &EchoRequest{}, &EchoResponse{})
+		if err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+			t.Fatalf("期望超时错误, 实际: %v", err)
+		}
+		if time.Since(start) > time.Second {
+			t.Error("方法超时未生效")
+		}
+		if result := <-svc.done; !result.HasDeadline {
+			t.Error("服务端上下文未设置截止时间")
+		}
+	})
+
+	t.Run("限制序列化方式", func(t *testing.T) {
+		err := cli.Call(context.Background(), "EchoV2.Echo", &EchoRequest{Message: "json"}, &EchoResponse{})
+		if err == nil || !strings.Contains(err.Error(), server.ErrCodecNotAllowed.Error()) {
+			t.Fatalf("期望序列化方式被拒绝, 实际: %v", err)
+		}
+
+		resp := &EchoResponse{}
+		err = cli.Call(context.Background(), "EchoV2.Echo", &EchoRequest{Message: "msgpack"}, resp, client.UseCodec(codec.NewMsgpackCodec()))
+		if err != nil {
+			t.Fatalf("调用失败: %v", err)
+		}
+		if resp.Message != "msgpack" {
+			t.Errorf("响应不匹配, 期望: msgpack, 实际: %s", resp.Message)
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/client"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/server"
+)
+
+func startEchoServer(t *testing.T, addr string) (*server.Server, *registry.MemoryRegistry, chan error) {
+	reg := registry.NewInMemoryRegistry()
+	srv := server.NewServer()
+	if err := srv.Register(&EchoService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+
+	instance := &registry.ServiceInstance{
+		Name:      "EchoService",
+		Endpoints: []string{addr},
+	}
+	if err := reg.Register(instance); err != nil {
+		t.Fatalf("注册实例失败: %v", err)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Start(addr)
+	}()
+	time.Sleep(time.Second)
+	return srv, reg, errCh
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	srv, reg, errCh := startEchoServer(t, "127.0.0.1:8895")
+
+	cli := client.NewClient(reg, registry.NewRandomBalancer(), client.WithMultiplex())
+	defer cli.Close()
+	if err := cli.Call(context.Background(), "EchoService.Echo", &EchoRequest{Message: "warmup"}, &EchoResponse{}); err != nil {
+		t.Fatalf("调用失败: %v", err)
+	}
+
+	// 关闭过程中正在执行的请求应正常完成
+	resp := &EchoResponse{}
+	call := cli.Go("EchoService.Delay", &DelayRequest{Message: "slow", Delay: 500 * time.Millisecond}, resp, make(chan *client.Call, 1))
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("关闭服务失败: %v", err)
+	}
+	if time.Since(start) < 300*time.Millisecond {
+		t.Error("未等待正在执行的请求完成")
+	}
+
+	result := <-call.Done
+	if result.Error != nil {
+		t.Fatalf("正在执行的请求失败: %v", result.Error)
+	}
+	if resp.Message != "slow" {
+		t.Errorf("响应不匹配, 期望: slow, 实际: %s", resp.Message)
+	}
+
+	select {
+	case err := <-errCh:
+		if err != server.ErrServerClosed {
+			t.Errorf("期望 ErrServerClosed, 实际: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Start 未返回")
+	}
+
+	// 关闭后新的调用失败
+	err := cli.Call(context.Background(), "EchoService.Echo", &EchoRequest{Message: "after"}, &EchoResponse{})
+	if err == nil {
+		t.Error("服务关闭后调用应失败")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	srv, reg, _ := startEchoServer(t, "127.0.0.1:8896")
+
+	cli := client.NewClient(reg, registry.NewRandomBalancer(), client.WithMultiplex())
+	defer cli.Close()
+
+	call := cli.Go("EchoService.Delay", &DelayRequest{Delay: 2 * time.Second}, &EchoResponse{}, make(chan *client.Call, 1))
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("期望超时错误, 实际: %v", err)
+	}
+
+	// 超时后连接被关闭, 未完成的请求失败
+	select {
+	case result := <-call.Done:
+		if result.Error == nil {
+			t.Error("连接关闭后请求应失败")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("请求未结束")
+	}
+}
+
+func TestClose(t *testing.T) {
+	srv, reg, errCh := startEchoServer(t, "127.0.0.1:8897")
+
+	cli := client.NewClient(reg, registry.NewRandomBalancer(), client.WithMultiplex())
+	defer cli.Close()
+
+	call := cli.Go("EchoService.Delay", &DelayRequest{Delay: 2 * time.Second}, &EchoResponse{}, make(chan *client.Call, 1))
+	time.Sleep(100 * time.Millisecond)
+
+	if err := srv.Close(); err != nil {
+		t.Fatalf("关闭服务失败: %v", err)
+	}
+
+	select {
+	case result := <-call.Done:
+		if result.Error == nil {
+			t.Error("连接关闭后请求应失败")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("请求未结束")
+	}
+
+	if err := <-errCh; err != server.ErrServerClosed {
+		t.Errorf("期望 ErrServerClosed, 实际: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/client"
+	"github.com/eason-lee/l-rpc/codec"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/server"
+	"github.com/eason-lee/l-rpc/status"
+)
+
+// StatusService 返回带错误码的错误
+type StatusService struct{}
+
+func (s *StatusService) Validate(ctx context.Context, req *EchoRequest, reply *EchoResponse) error {
+	if req.Message == "" {
+		return status.New(status.InvalidArgument, "message is required").WithDetails("field", "Message")
+	}
+	return errors.New("plain error")
+}
+
+func TestStatusPropagation(t *testing.T) {
+	reg := registry.NewInMemoryRegistry()
+	srv := server.NewServer(server.WithRegistry(reg))
+	if err := srv.Register(&StatusService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+	if err := srv.Register(&BlockService{done: make(chan BlockResult, 1)}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+
+	go srv.Start("127.0.0.1:8900")
+	time.Sleep(time.Second)
+	defer srv.Close()
+
+	// 注册一个服务端不存在的服务名
+	if err := reg.Register(&registry.ServiceInstance{
+		ID:        "missing",
+		Name:      "MissingService",
+		Endpoints: []string{"127.0.0.1:8900"},
+	}); err != nil {
+		t.Fatalf("注册实例失败: %v", err)
+	}
+
+	cli := client.NewClient(reg, registry.NewRandomBalancer())
+	defer cli.Close()
+
+	tests := []struct {
+		name          string
+		serviceMethod string
+		req           *EchoRequest
+		opts          []client.CallOption
+		code          status.Code
+		target        error
+	}{
+		{"服务不存在", "MissingService.Echo", &EchoRequest{}, nil, status.NotFound, server.ErrServiceNotFound},
+		{"方法不存在", "StatusService.Missing", &EchoRequest{}, nil, status.NotFound, server.ErrMethodNotFound},
+		{"不支持的序列化方式", "StatusService.Validate", &EchoRequest{}, []client.CallOption{client.UseCodec(&unknownCodec{})}, status.InvalidArgument, codec.ErrUnsupportedCodec},
+		{"业务错误", "StatusService.Validate", &EchoRequest{Message: "x"}, nil, status.Unknown, nil},
+		{"参数错误", "StatusService.Validate", &EchoRequest{}, nil, status.InvalidArgument, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cli.Call(context.Background(), tt.serviceMethod, tt.req, &EchoResponse{}, tt.opts...)
+			if code := status.CodeOf(err); code != tt.code {
+				t.Fatalf("期望错误码 %s, 实际: %s (%v)", tt.code, code, err)
+			}
+			if tt.target != nil && !errors.Is(err, tt.target) {
+				t.Errorf("errors.Is(%v, %v) 应为 true", err, tt.target)
+			}
+		})
+	}
+
+	t.Run("错误详情", func(t *testing.T) {
+		err := cli.Call(context.Background(), "StatusService.Validate", &EchoRequest{}, &EchoResponse{})
+		st, ok := status.FromError(err)
+		if !ok {
+			t.Fatalf("期望返回状态, 实际: %v", err)
+		}
+		if st.Message != "message is required" || st.Details["field"] != "Message" {
+			t.Errorf("错误信息或详情不匹配: %s %v", st.Message, st.Details)
+		}
+	})
+
+	t.Run("服务端超时", func(t *testing.T) {
+		srv.RegisterName("ShortBlock", &BlockService{done: make(chan BlockResult, 1)}, server.WithMethodTimeout("Block", 50*time.Millisecond))
+		err := cli.Call(context.Background(), "ShortBlock.Block", &EchoRequest{}, &EchoResponse{})
+		if status.CodeOf(err) != status.DeadlineExceeded || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("期望超时错误, 实际: %v", err)
+		}
+	})
+
+	t.Run("没有可用实例", func(t *testing.T) {
+		reg.Register(&registry.ServiceInstance{ID: "down", Name: "DownService", Status: registry.StatusDown})
+		err := cli.Call(context.Background(), "DownService.Echo", &EchoRequest{}, &EchoResponse{})
+		if status.CodeOf(err) != status.Unavailable {
+			t.Errorf("期望服务不可用错误, 实际: %v", err)
+		}
+	})
+}
@@ -0,0 +1,373 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/client"
+	"github.com/eason-lee/l-rpc/metadata"
+	"github.com/eason-lee/l-rpc/protocol"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/server"
+	"github.com/eason-lee/l-rpc/status"
+	"github.com/eason-lee/l-rpc/stream"
+	"github.com/eason-lee/l-rpc/transport"
+)
+
+type PageRequest struct {
+	Count int
+}
+
+// StreamService 流式方法
+type StreamService struct {
+	flooded atomic.Int64
+	bulked  atomic.Int64
+	stopped chan error
+}
+
+// List 服务端流: 按请求数量逐条返回
+func (s *StreamService) List(ctx context.Context, st server.Stream) error {
+	var req PageRequest
+	if err := st.Recv(&req); err != nil {
+		return err
+	}
+	for i := 0; i < req.Count; i++ {
+		if err := st.Send(&EchoResponse{Message: strings.Repeat("x", i)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Join 客户端流: 读取全部消息后返回拼接结果
+func (s *StreamService) Join(ctx context.Context, st server.Stream) error {
+	var parts []string
+	for {
+		var req EchoRequest
+		err := st.Recv(&req)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		parts = append(parts, req.Message)
+	}
+	return st.Send(&EchoResponse{Message: strings.Join(parts, ",")})
+}
+
+// Chat 双向流: 逐条转换为大写返回, 消息头中的前缀加在每条消息前
+func (s *StreamService) Chat(ctx context.Context, st server.Stream) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for {
+		var req EchoRequest
+		err := st.Recv(&req)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := st.Send(&EchoResponse{Message: md.Get("prefix") + strings.ToUpper(req.Message)}); err != nil {
+			return err
+		}
+	}
+}
+
+// Fail 发送一条消息后返回错误
+func (s *StreamService) Fail(ctx context.Context, st server.Stream) error {
+	if err := st.Send(&EchoResponse{Message: "partial"}); err != nil {
+		return err
+	}
+	return status.Error(status.FailedPrecondition, "stream failed")
+}
+
+// Flood 持续发送直到流被取消
+func (s *StreamService) Flood(ctx context.Context, st server.Stream) error {
+	for {
+		if err := st.Send(&EchoResponse{Message: "flood"}); err != nil {
+			s.stopped <- err
+			return err
+		}
+		s.flooded.Add(1)
+	}
+}
+
+// Bulk 持续发送半个字节窗口大小的消息直到流被取消
+func (s *StreamService) Bulk(ctx context.Context, st server.Stream) error {
+	msg := &EchoResponse{Message: strings.Repeat("x", stream.DefaultWindowBytes/2)}
+	for {
+		if err := st.Send(msg); err != nil {
+			s.stopped <- err
+			return err
+		}
+		s.bulked.Add(1)
+	}
+}
+
+func (s *StreamService) Unary(ctx context.Context, req *EchoRequest, reply *EchoResponse) error {
+	reply.Message = req.Message
+	return nil
+}
+
+func TestStreamRPC(t *testing.T) {
+	reg := registry.NewInMemoryRegistry()
+	srv := server.NewServer(server.WithRegistry(reg))
+	svc := &StreamService{stopped: make(chan error, 1)}
+	if err := srv.Register(svc); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+
+	go srv.Start("127.0.0.1:8901")
+	time.Sleep(time.Second)
+	defer srv.Close()
+
+	cli := client.NewClient(reg, registry.NewRandomBalancer())
+	defer cli.Close()
+
+	t.Run("服务端流", func(t *testing.T) {
+		st, err := cli.NewStream(context.Background(), "StreamService.List")
+		if err != nil {
+			t.Fatalf("建立流失败: %v", err)
+		}
+		if err := st.Send(&PageRequest{Count: 200}); err != nil {
+			t.Fatalf("发送失败: %v", err)
+		}
+		if err := st.CloseSend(); err != nil {
+			t.Fatalf("关闭发送失败: %v", err)
+		}
+
+		// 消息数超过流控窗口, 需要客户端边读边通知服务端
+		for i := 0; i < 200; i++ {
+			var resp EchoResponse
+			if err := st.Recv(&resp); err != nil {
+				t.Fatalf("第 %d 条消息读取失败: %v", i, err)
+			}
+			if len(resp.Message) != i {
+				t.Fatalf("第 %d 条消息不匹配: %q", i, resp.Message)
+			}
+		}
+		if err := st.Recv(&EchoResponse{}); err != io.EOF {
+			t.Errorf("期望 io.EOF, 实际: %v", err)
+		}
+	})
+
+	t.Run("客户端流", func(t *testing.T) {
+		st, err := cli.NewStream(context.Background(), "StreamService.Join")
+		if err != nil {
+			t.Fatalf("建立流失败: %v", err)
+		}
+		for _, msg := range []string{"a", "b", "c"} {
+			if err := st.Send(&EchoRequest{Message: msg}); err != nil {
+				t.Fatalf("发送失败: %v", err)
+			}
+		}
+		st.CloseSend()
+
+		var resp EchoResponse
+		if err := st.Recv(&resp); err != nil {
+			t.Fatalf("读取失败: %v", err)
+		}
+		if resp.Message != "a,b,c" {
+			t.Errorf("期望 a,b,c, 实际: %s", resp.Message)
+		}
+		if err := st.Recv(&resp); err != io.EOF {
+			t.Errorf("期望 io.EOF, 实际: %v", err)
+		}
+	})
+
+	t.Run("双向流", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "prefix", ">")
+		st, err := cli.NewStream(ctx, "StreamService.Chat")
+		if err != nil {
+			t.Fatalf("建立流失败: %v", err)
+		}
+		for _, msg := range []string{"hello", "world"} {
+			if err := st.Send(&EchoRequest{Message: msg}); err != nil {
+				t.Fatalf("发送失败: %v", err)
+			}
+			var resp EchoResponse
+			if err := st.Recv(&resp); err != nil {
+				t.Fatalf("读取失败: %v", err)
+			}
+			if resp.Message != ">"+strings.ToUpper(msg) {
+				t.Errorf("期望 %s, 实际: %s", ">"+strings.ToUpper(msg), resp.Message)
+			}
+		}
+		st.CloseSend()
+		if err := st.Recv(&EchoResponse{}); err != io.EOF {
+			t.Errorf("期望 io.EOF, 实际: %v", err)
+		}
+	})
+
+	t.Run("服务端返回错误", func(t *testing.T) {
+		st, err := cli.NewStream(context.Background(), "StreamService.Fail")
+		if err != nil {
+			t.Fatalf("建立流失败: %v", err)
+		}
+		var resp EchoResponse
+		if err := st.Recv(&resp); err != nil || resp.Message != "partial" {
+			t.Fatalf("期望先收到 partial, 实际: %q %v", resp.Message, err)
+		}
+		err = st.Recv(&resp)
+		if status.CodeOf(err) != status.FailedPrecondition {
+			t.Errorf("期望 FailedPrecondition, 实际: %v", err)
+		}
+		// 服务端已结束流
+		if err := st.Send(&EchoRequest{}); err != io.EOF {
+			t.Errorf("服务端结束流后发送应返回 io.EOF, 实际: %v", err)
+		}
+	})
+
+	t.Run("调用方式不匹配", func(t *testing.T) {
+		err := cli.Call(context.Background(), "StreamService.List", &PageRequest{}, &EchoResponse{})
+		if status.CodeOf(err) != status.Unimplemented || !errors.Is(err, server.ErrStreamMismatch) {
+			t.Errorf("普通调用流式方法应返回 Unimplemented, 实际: %v", err)
+		}
+
+		st, err := cli.NewStream(context.Background(), "StreamService.Unary")
+		if err != nil {
+			t.Fatalf("建立流失败: %v", err)
+		}
+		if err := st.Recv(&EchoResponse{}); !errors.Is(err, server.ErrStreamMismatch) {
+			t.Errorf("流式调用普通方法应返回 ErrStreamMismatch, 实际: %v", err)
+		}
+	})
+
+	t.Run("慢消费者", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		st, err := cli.NewStream(ctx, "StreamService.Flood")
+		if err != nil {
+			t.Fatalf("建立流失败: %v", err)
+		}
+
+		// 客户端不读取时服务端最多发送一个窗口的消息
+		waitFlooded(t, &svc.flooded, stream.DefaultWindow)
+
+		// 读取过半窗口后服务端继续发送
+		for i := 0; i < stream.DefaultWindow/2; i++ {
+			if err := st.Recv(&EchoResponse{}); err != nil {
+				t.Fatalf("读取失败: %v", err)
+			}
+		}
+		waitFlooded(t, &svc.flooded, stream.DefaultWindow+stream.DefaultWindow/2)
+
+		// 取消流后服务端方法退出
+		cancel()
+		select {
+		case err := <-svc.stopped:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("期望服务端收到取消, 实际: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("服务端方法未在取消后退出")
+		}
+		if err := st.Recv(&EchoResponse{}); !errors.Is(err, context.Canceled) {
+			t.Errorf("期望 context.Canceled, 实际: %v", err)
+		}
+	})
+
+	t.Run("慢消费者按字节限流", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		st, err := cli.NewStream(ctx, "StreamService.Bulk")
+		if err != nil {
+			t.Fatalf("建立流失败: %v", err)
+		}
+
+		// 每条消息略大于半个字节窗口, 客户端不读取时服务端发送两条后阻塞
+		waitFlooded(t, &svc.bulked, 2)
+
+		// 读取一条消息即读取过半字节窗口, 服务端可以再发送一条
+		if err := st.Recv(&EchoResponse{}); err != nil {
+			t.Fatalf("读取失败: %v", err)
+		}
+		waitFlooded(t, &svc.bulked, 3)
+
+		cancel()
+		select {
+		case <-svc.stopped:
+		case <-time.After(time.Second):
+			t.Fatal("服务端方法未在取消后退出")
+		}
+	})
+}
+
+func TestStreamWindowExceeded(t *testing.T) {
+	// 不遵守流控的服务端, 流建立后连续发送超过窗口的消息, 记录客户端取消的流
+	ln, err := net.Listen("tcp", "127.0.0.1:8920")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	defer ln.Close()
+	canceled := make(chan uint64, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		trans := transport.NewTCPTransport(conn)
+		defer trans.Close()
+		for {
+			msg, err := trans.ReadMessage()
+			if err != nil {
+				return
+			}
+			switch msg.Header.Type {
+			case protocol.TypeStreamOpen:
+				for i := 0; i <= stream.DefaultWindow; i++ {
+					trans.WriteMessage(&protocol.Message{
+						Header: &protocol.Header{StreamID: msg.Header.StreamID, Type: protocol.TypeStreamData},
+					})
+				}
+			case protocol.TypeCancel:
+				canceled <- msg.Header.StreamID
+			}
+		}
+	}()
+
+	reg := registry.NewInMemoryRegistry()
+	if err := reg.Register(&registry.ServiceInstance{
+		ID:        "RogueService-1",
+		Name:      "RogueService",
+		Endpoints: []string{"127.0.0.1:8920"},
+	}); err != nil {
+		t.Fatalf("注册实例失败: %v", err)
+	}
+	cli := client.NewClient(reg, registry.NewRandomBalancer())
+	defer cli.Close()
+
+	st, err := cli.NewStream(context.Background(), "RogueService.List")
+	if err != nil {
+		t.Fatalf("建立流失败: %v", err)
+	}
+
+	// 客户端中止流并通知服务端取消
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("超出流控窗口后没有通知服务端取消")
+	}
+	if err := st.Recv(&EchoResponse{}); !errors.Is(err, stream.ErrWindowExceeded) {
+		t.Errorf("期望 ErrWindowExceeded, 实际: %v", err)
+	}
+}
+
+// waitFlooded 等待服务端发送 want 条消息, 并确认之后不再继续发送
+func waitFlooded(t *testing.T, flooded *atomic.Int64, want int64) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for flooded.Load() < want && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := flooded.Load(); n != want {
+		t.Errorf("期望服务端发送 %d 条消息后阻塞, 实际: %d", want, n)
+	}
+}
@@ -0,0 +1,229 @@
+package integration
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/client"
+	"github.com/eason-lee/l-rpc/peer"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/server"
+	"github.com/eason-lee/l-rpc/transport"
+)
+
+// PeerService 返回客户端证书的 CommonName
+type PeerService struct{}
+
+func (s *PeerService) Identity(ctx context.Context, req *EchoRequest, reply *EchoResponse) error {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		reply.Message = "no peer"
+		return nil
+	}
+	if cert := p.Certificate(); cert != nil {
+		reply.Message = cert.Subject.CommonName
+		return nil
+	}
+	reply.Message = "anonymous@" + p.Addr.Network()
+	return nil
+}
+
+// testCA 测试用的证书签发机构
+type testCA struct {
+	t    *testing.T
+	dir  string
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("生成私钥失败: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("生成 CA 证书失败: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	ca := &testCA{t: t, dir: t.TempDir(), cert: cert, key: key}
+	ca.write("ca.pem", "CERTIFICATE", der)
+	return ca
+}
+
+// issue 签发证书并写入 name.pem 和 name-key.pem, 返回两个文件的路径
+func (ca *testCA) issue(name, commonName string, serial int64) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		ca.t.Fatalf("生成私钥失败: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		ca.t.Fatalf("签发证书失败: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		ca.t.Fatalf("编码私钥失败: %v", err)
+	}
+	return ca.write(name+".pem", "CERTIFICATE", der), ca.write(name+"-key.pem", "EC PRIVATE KEY", keyDER)
+}
+
+func (ca *testCA) write(name, typ string, der []byte) string {
+	path := filepath.Join(ca.dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		ca.t.Fatalf("写入 %s 失败: %v", name, err)
+	}
+	return path
+}
+
+func (ca *testCA) caFile() string {
+	return filepath.Join(ca.dir, "ca.pem")
+}
+
+func TestTLS(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue("server", "server-1", 2)
+	clientCert, clientKey := ca.issue("client", "client-a", 3)
+
+	serverTLS, err := (&transport.TLSConfig{
+		CertFile:          serverCert,
+		KeyFile:           serverKey,
+		CAFile:            ca.caFile(),
+		RequireClientCert: true,
+	}).ServerConfig()
+	if err != nil {
+		t.Fatalf("创建服务端 TLS 配置失败: %v", err)
+	}
+
+	reg := registry.NewInMemoryRegistry()
+	srv := server.NewServer(server.WithRegistry(reg), server.WithTLS(serverTLS))
+	if err := srv.Register(&PeerService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+	go srv.Start("127.0.0.1:8912")
+	time.Sleep(time.Second)
+	defer srv.Close()
+
+	// newClient 创建使用客户端证书的客户端, 并记录握手时服务端证书的 CommonName
+	newClient := func(t *testing.T, certFile, keyFile string, opts ...client.Option) (*client.Client, *string) {
+		config, err := (&transport.TLSConfig{CertFile: certFile, KeyFile: keyFile, CAFile: ca.caFile()}).ClientConfig()
+		if err != nil {
+			t.Fatalf("创建客户端 TLS 配置失败: %v", err)
+		}
+		var serverName string
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			serverName = cs.PeerCertificates[0].Subject.CommonName
+			return nil
+		}
+		return client.NewClient(reg, registry.NewRandomBalancer(), append(opts, client.WithTLS(config))...), &serverName
+	}
+
+	t.Run("双向认证", func(t *testing.T) {
+		for name, opts := range map[string][]client.Option{
+			"连接池":  nil,
+			"多路复用": {client.WithMultiplex()},
+		} {
+			cli, _ := newClient(t, clientCert, clientKey, opts...)
+			resp := &EchoResponse{}
+			err := cli.Call(context.Background(), "PeerService.Identity", &EchoRequest{}, resp)
+			cli.Close()
+			if err != nil {
+				t.Fatalf("%s: 调用失败: %v", name, err)
+			}
+			if resp.Message != "client-a" {
+				t.Errorf("%s: 处理函数应获取到客户端证书, 实际: %s", name, resp.Message)
+			}
+		}
+	})
+
+	t.Run("缺少客户端证书", func(t *testing.T) {
+		cli, _ := newClient(t, "", "", client.WithMultiplex())
+		defer cli.Close()
+		if err := cli.Call(context.Background(), "PeerService.Identity", &EchoRequest{}, &EchoResponse{}); err == nil {
+			t.Error("服务端应拒绝没有证书的客户端")
+		}
+	})
+
+	t.Run("非 TLS 客户端", func(t *testing.T) {
+		cli := client.NewClient(reg, registry.NewRandomBalancer(), client.WithMultiplex())
+		defer cli.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := cli.Call(ctx, "PeerService.Identity", &EchoRequest{}, &EchoResponse{}); err == nil {
+			t.Error("服务端应拒绝非 TLS 连接")
+		}
+	})
+
+	t.Run("证书热更新", func(t *testing.T) {
+		ca.issue("server", "server-2", 4)
+		// 保证修改时间变化
+		later := time.Now().Add(time.Minute)
+		os.Chtimes(serverCert, later, later)
+		os.Chtimes(serverKey, later, later)
+
+		cli, serverName := newClient(t, clientCert, clientKey, client.WithMultiplex())
+		defer cli.Close()
+		if err := cli.Call(context.Background(), "PeerService.Identity", &EchoRequest{}, &EchoResponse{}); err != nil {
+			t.Fatalf("调用失败: %v", err)
+		}
+		if *serverName != "server-2" {
+			t.Errorf("新连接应使用更新后的证书, 实际: %s", *serverName)
+		}
+	})
+}
+
+func TestSymmetricEncryption(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	reg := registry.NewInMemoryRegistry()
+	srv := server.NewServer(server.WithRegistry(reg), server.WithTransportOptions(transport.WithEncryptor(transport.NewAESEncryptor(key))))
+	if err := srv.Register(&PeerService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+	go srv.Start("127.0.0.1:8913")
+	time.Sleep(time.Second)
+	defer srv.Close()
+
+	cli := client.NewClient(reg, registry.NewRandomBalancer(), client.WithMultiplex(),
+		client.WithTransportOptions(transport.WithEncryptor(transport.NewAESEncryptor(key))))
+	defer cli.Close()
+
+	resp := &EchoResponse{}
+	if err := cli.Call(context.Background(), "PeerService.Identity", &EchoRequest{}, resp); err != nil {
+		t.Fatalf("调用失败: %v", err)
+	}
+	if resp.Message != "anonymous@tcp" {
+		t.Errorf("未使用 TLS 时只有对端地址, 实际: %s", resp.Message)
+	}
+}
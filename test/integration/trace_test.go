@@ -0,0 +1,134 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/client"
+	"github.com/eason-lee/l-rpc/metadata"
+	"github.com/eason-lee/l-rpc/registry"
+	"github.com/eason-lee/l-rpc/server"
+	"github.com/eason-lee/l-rpc/status"
+	"github.com/eason-lee/l-rpc/trace"
+)
+
+// TraceService 返回处理函数上下文中的链路ID
+type TraceService struct{}
+
+func (s *TraceService) TraceID(ctx context.Context, req *EchoRequest, reply *EchoResponse) error {
+	reply.Message = trace.SpanContextFromContext(ctx).TraceID.String()
+	return nil
+}
+
+// waitSpans 等待导出指定数量的调用, 服务端调用在发送响应之后结束
+func waitSpans(t *testing.T, exporter *trace.InMemoryExporter, n int) []trace.SpanData {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if spans := exporter.Spans(); len(spans) >= n {
+			return spans
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("期望导出 %d 个调用, 实际: %d", n, len(exporter.Spans()))
+	return nil
+}
+
+// spanOfKind 按类型查找调用
+func spanOfKind(spans []trace.SpanData, kind trace.SpanKind) trace.SpanData {
+	for _, span := range spans {
+		if span.Kind == kind {
+			return span
+		}
+	}
+	return trace.SpanData{}
+}
+
+func TestTracing(t *testing.T) {
+	clientExporter := trace.NewInMemoryExporter()
+	serverExporter := trace.NewInMemoryExporter()
+
+	reg := registry.NewInMemoryRegistry()
+	srv := server.NewServer(server.WithRegistry(reg), server.WithTracer(trace.NewTracer(serverExporter)))
+	if err := srv.Register(&TraceService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+	if err := srv.Register(&StatusService{}); err != nil {
+		t.Fatalf("注册服务失败: %v", err)
+	}
+
+	go srv.Start("127.0.0.1:8910")
+	time.Sleep(time.Second)
+	defer srv.Close()
+
+	tracer := trace.NewTracer(clientExporter)
+	cli := client.NewClient(reg, registry.NewRandomBalancer(), client.WithMultiplex(), client.WithTracer(tracer))
+	defer cli.Close()
+
+	t.Run("链路传递", func(t *testing.T) {
+		clientExporter.Reset()
+		serverExporter.Reset()
+
+		ctx, root := tracer.Start(context.Background(), "handler", trace.SpanKindInternal)
+		md := metadata.Pairs("caller", "a")
+		ctx = metadata.NewOutgoingContext(ctx, md)
+		resp := &EchoResponse{}
+		if err := cli.Call(ctx, "TraceService.TraceID", &EchoRequest{}, resp); err != nil {
+			t.Fatalf("调用失败: %v", err)
+		}
+		root.End()
+
+		traceID := root.SpanContext().TraceID
+		if resp.Message != traceID.String() {
+			t.Errorf("处理函数应处于同一链路, 期望: %s, 实际: %s", traceID, resp.Message)
+		}
+		if _, ok := md[trace.TraceparentKey]; ok {
+			t.Error("不应修改调用方的元数据")
+		}
+
+		clientSpan := spanOfKind(waitSpans(t, clientExporter, 2), trace.SpanKindClient)
+		serverSpan := waitSpans(t, serverExporter, 1)[0]
+
+		if clientSpan.Parent != root.SpanContext().SpanID {
+			t.Errorf("客户端调用的父调用应为 %s, 实际: %s", root.SpanContext().SpanID, clientSpan.Parent)
+		}
+		if serverSpan.SpanContext.TraceID != traceID || serverSpan.Parent != clientSpan.SpanContext.SpanID {
+			t.Errorf("服务端调用应为客户端调用的子调用, 实际: trace=%s parent=%s", serverSpan.SpanContext.TraceID, serverSpan.Parent)
+		}
+		if serverSpan.Kind != trace.SpanKindServer || serverSpan.Name != "TraceService/TraceID" {
+			t.Errorf("服务端调用信息错误: %+v", serverSpan)
+		}
+
+		attrs := clientSpan.Attributes
+		if attrs[trace.AttrService] != "TraceService" || attrs[trace.AttrMethod] != "TraceID" ||
+			attrs[trace.AttrPeer] != "127.0.0.1:8910" || attrs[trace.AttrInstance] == "" ||
+			attrs[trace.AttrStatusCode] != status.OK.String() {
+			t.Errorf("客户端调用属性错误: %v", attrs)
+		}
+		if clientSpan.Err != nil || serverSpan.Err != nil {
+			t.Errorf("调用成功时不应记录错误: client=%v server=%v", clientSpan.Err, serverSpan.Err)
+		}
+	})
+
+	t.Run("记录错误", func(t *testing.T) {
+		clientExporter.Reset()
+		serverExporter.Reset()
+
+		err := cli.Call(context.Background(), "StatusService.Validate", &EchoRequest{}, &EchoResponse{})
+		if status.CodeOf(err) != status.InvalidArgument {
+			t.Fatalf("期望 InvalidArgument, 实际: %v", err)
+		}
+
+		clientSpan := waitSpans(t, clientExporter, 1)[0]
+		serverSpan := waitSpans(t, serverExporter, 1)[0]
+		for _, span := range []trace.SpanData{clientSpan, serverSpan} {
+			if status.CodeOf(span.Err) != status.InvalidArgument || span.Attributes[trace.AttrStatusCode] != status.InvalidArgument.String() {
+				t.Errorf("%s 调用应记录错误, 实际: %v %v", span.Kind, span.Err, span.Attributes)
+			}
+		}
+		// 没有父调用时客户端开始新的链路
+		if clientSpan.Parent.IsValid() || serverSpan.SpanContext.TraceID != clientSpan.SpanContext.TraceID {
+			t.Errorf("期望客户端开始新的链路, 实际: client=%+v server=%+v", clientSpan.SpanContext, serverSpan.SpanContext)
+		}
+	})
+}
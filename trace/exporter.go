@@ -0,0 +1,39 @@
+package trace
+
+import "sync"
+
+// Exporter 导出结束的调用, 如写入日志或发送到 OpenTelemetry Collector
+// 在调用结束的协程中同步执行, 耗时的导出应自行缓冲
+type Exporter interface {
+	ExportSpan(span SpanData)
+}
+
+// InMemoryExporter 将调用保存在内存中, 用于测试
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+func (e *InMemoryExporter) ExportSpan(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// Spans 返回已导出的调用, 按结束顺序排列
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
+
+// Reset 清空已导出的调用
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
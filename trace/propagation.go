@@ -0,0 +1,127 @@
+package trace
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// W3C Trace Context 在元数据中使用的键
+const (
+	TraceparentKey = "traceparent"
+	TracestateKey  = "tracestate"
+)
+
+// ErrInvalidTraceparent traceparent 格式错误
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// TraceID 链路ID
+type TraceID [16]byte
+
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// SpanID 调用ID
+type SpanID [8]byte
+
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// FlagSampled 采样标记, 未采样的调用不导出
+const FlagSampled byte = 0x01
+
+// SpanContext 跨进程传递的调用上下文
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	TraceFlags byte
+	TraceState string
+	// 是否从请求头中解析得到
+	Remote bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+func (sc SpanContext) IsSampled() bool {
+	return sc.TraceFlags&FlagSampled != 0
+}
+
+// Traceparent 按 W3C 格式编码, 如 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.TraceFlags)
+}
+
+// ParseTraceparent 解析 W3C traceparent
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return sc, fmt.Errorf("%w: %q", ErrInvalidTraceparent, s)
+	}
+	// 版本 00 只有 4 段, 更高版本可以在后面追加字段
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, fmt.Errorf("%w: %q", ErrInvalidTraceparent, s)
+	}
+
+	var flags [1]byte
+	if err := decodeHex(sc.TraceID[:], parts[1]); err != nil {
+		return sc, fmt.Errorf("%w: %q", ErrInvalidTraceparent, s)
+	}
+	if err := decodeHex(sc.SpanID[:], parts[2]); err != nil {
+		return sc, fmt.Errorf("%w: %q", ErrInvalidTraceparent, s)
+	}
+	if err := decodeHex(flags[:], parts[3]); err != nil {
+		return sc, fmt.Errorf("%w: %q", ErrInvalidTraceparent, s)
+	}
+	sc.TraceFlags = flags[0]
+	if !sc.IsValid() {
+		return sc, fmt.Errorf("%w: %q", ErrInvalidTraceparent, s)
+	}
+	return sc, nil
+}
+
+// decodeHex 解码固定长度的小写十六进制字符串
+func decodeHex(dst []byte, s string) error {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return ErrInvalidTraceparent
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
+
+// Inject 将调用上下文写入元数据
+func Inject(sc SpanContext, md map[string]string) {
+	if !sc.IsValid() {
+		return
+	}
+	md[TraceparentKey] = sc.Traceparent()
+	if sc.TraceState != "" {
+		md[TracestateKey] = sc.TraceState
+	} else {
+		delete(md, TracestateKey)
+	}
+}
+
+// Extract 从元数据中解析调用上下文, 不存在或格式错误时返回 false
+func Extract(md map[string]string) (SpanContext, bool) {
+	sc, err := ParseTraceparent(md[TraceparentKey])
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc.TraceState = md[TracestateKey]
+	sc.Remote = true
+	return sc, true
+}
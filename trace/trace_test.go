@@ -0,0 +1,137 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type TraceTestSuite struct {
+	suite.Suite
+}
+
+func (s *TraceTestSuite) TestTraceparent() {
+	const tp = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceparent(tp)
+	s.Require().NoError(err)
+	s.Equal("4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	s.Equal("00f067aa0ba902b7", sc.SpanID.String())
+	s.True(sc.IsSampled())
+	s.Equal(tp, sc.Traceparent())
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+	} {
+		_, err := ParseTraceparent(invalid)
+		s.ErrorIs(err, ErrInvalidTraceparent, invalid)
+	}
+
+	// 更高版本可以追加字段
+	_, err = ParseTraceparent(tp[2:] + "-extra")
+	s.Error(err)
+	_, err = ParseTraceparent("01" + tp[2:] + "-extra")
+	s.NoError(err)
+}
+
+func (s *TraceTestSuite) TestInjectExtract() {
+	sc := SpanContext{
+		TraceID:    newTraceID(),
+		SpanID:     newSpanID(),
+		TraceFlags: FlagSampled,
+		TraceState: "vendor=value",
+	}
+	md := map[string]string{"caller": "a"}
+	Inject(sc, md)
+	s.Equal("a", md["caller"])
+
+	got, ok := Extract(md)
+	s.Require().True(ok)
+	s.True(got.Remote)
+	got.Remote = false
+	s.Equal(sc, got)
+
+	// 无效的调用上下文不写入
+	md = map[string]string{}
+	Inject(SpanContext{}, md)
+	s.Empty(md)
+	_, ok = Extract(md)
+	s.False(ok)
+}
+
+func (s *TraceTestSuite) TestStart() {
+	exporter := NewInMemoryExporter()
+	tracer := NewTracer(exporter)
+
+	// 没有父调用时开始新的链路
+	ctx, root := tracer.Start(context.Background(), "root", SpanKindClient)
+	s.Same(root, SpanFromContext(ctx))
+	s.True(root.SpanContext().IsValid())
+	s.True(root.SpanContext().IsSampled())
+
+	_, child := tracer.Start(ctx, "child", SpanKindInternal)
+	child.SetAttribute("key", "value")
+	child.RecordError(errors.New("failed"))
+	child.End()
+	child.End()
+	root.End()
+
+	spans := exporter.Spans()
+	s.Require().Len(spans, 2)
+	s.Equal("child", spans[0].Name)
+	s.Equal(root.SpanContext().TraceID, spans[0].SpanContext.TraceID)
+	s.Equal(root.SpanContext().SpanID, spans[0].Parent)
+	s.Equal("value", spans[0].Attributes["key"])
+	s.EqualError(spans[0].Err, "failed")
+	s.False(spans[1].Parent.IsValid())
+
+	// 结束后的修改不生效
+	child.SetAttribute("key", "changed")
+	s.Equal("value", exporter.Spans()[0].Attributes["key"])
+}
+
+func (s *TraceTestSuite) TestRemoteParent() {
+	exporter := NewInMemoryExporter()
+	tracer := NewTracer(exporter)
+
+	remote := SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), TraceFlags: FlagSampled, TraceState: "k=v", Remote: true}
+	_, span := tracer.Start(ContextWithRemoteSpanContext(context.Background(), remote), "server", SpanKindServer)
+	span.End()
+
+	spans := exporter.Spans()
+	s.Require().Len(spans, 1)
+	s.Equal(remote.TraceID, spans[0].SpanContext.TraceID)
+	s.Equal(remote.SpanID, spans[0].Parent)
+	s.Equal("k=v", spans[0].SpanContext.TraceState)
+	s.False(spans[0].SpanContext.Remote)
+
+	// 未采样的链路不导出
+	exporter.Reset()
+	remote.TraceFlags = 0
+	_, span = tracer.Start(ContextWithRemoteSpanContext(context.Background(), remote), "server", SpanKindServer)
+	span.End()
+	s.Empty(exporter.Spans())
+}
+
+func (s *TraceTestSuite) TestNilSpan() {
+	var span *Span
+	s.NotPanics(func() {
+		span.SetAttribute("key", "value")
+		span.RecordError(errors.New("failed"))
+		span.End()
+	})
+	s.False(span.SpanContext().IsValid())
+	s.Nil(SpanFromContext(context.Background()))
+}
+
+func TestTraceSuite(t *testing.T) {
+	suite.Run(t, new(TraceTestSuite))
+}
@@ -3,8 +3,6 @@ package transport
 import (
 	"context"
 	"github.com/eason-lee/l-rpc/protocol"
-	"net"
-	"time"
 )
 
 type Client struct {
@@ -13,22 +11,16 @@ type Client struct {
 	address string
 }
 
-func NewClient(network, addr string) (*Client, error) {
-	factory := func() (*TCPTransport, error) {
-		conn, err := net.Dial(network, addr)
-		if err != nil {
-			return nil, err
-		}
-		return NewTCPTransport(conn), nil
+// NewClient 创建连接池客户端, opts 用于建立每个连接, 连接池配置通过 WithPool 设置
+func NewClient(network, addr string, opts ...Option) (*Client, error) {
+	config := newOptions(opts).pool
+	// 连接池中的连接没有读协程处理 pong, 不发送心跳, 借出时由连接池检查连接是否可用
+	dialOpts := append(opts[:len(opts):len(opts)], WithHeartbeat(0, 0))
+	config.Factory = func(ctx context.Context) (*TCPTransport, error) {
+		return DialContext(ctx, network, addr, dialOpts...)
 	}
 
-	pool, err := NewPool(PoolConfig{
-		MaxIdle:     5,                 // 最大空闲连接数
-		MaxActive:   20,                // 最大活跃连接数
-		IdleTimeout: 30 * time.Second,  // 空闲超时时间
-		Factory:     factory,
-	})
-
+	pool, err := NewPool(config)
 	if err != nil {
 		return nil, err
 	}
@@ -41,32 +33,64 @@ func NewClient(network, addr string) (*Client, error) {
 }
 
 func (c *Client) Send(ctx context.Context, message *protocol.Message) (*protocol.Message, error) {
-	// 获取连接
-	trans, err := c.pool.Get()
+	// 获取连接, 连接数达到上限时等待直到上下文结束
+	trans, err := c.pool.Get(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer c.pool.Put(trans)
 
-	// 编码消息
-	codec := protocol.NewDefaultCodec()
-	data, err := codec.Encode(message)
-	if err != nil {
-		return nil, err
-	}
+	// 上下文结束时关闭连接以中断阻塞的读写, 服务端会随之取消该连接上的请求
+	stop := context.AfterFunc(ctx, func() {
+		trans.Close()
+	})
 
 	// 发送并接收响应
-	respData, err := trans.Send(data)
+	resp, goAway, err := c.roundTrip(trans, message)
+	if !stop() {
+		c.pool.Discard(trans)
+		return nil, ctx.Err()
+	}
 	if err != nil {
+		c.pool.Discard(trans)
 		return nil, err
 	}
 
-	// 解码响应
-	return codec.Decode(respData)
+	// 服务端即将关闭的连接不再放回连接池
+	if goAway {
+		c.pool.Discard(trans)
+	} else {
+		c.pool.Put(trans)
+	}
+	return resp, nil
+}
+
+// roundTrip 发送请求并读取响应, 跳过连接空闲期间收到的关闭通知和服务端的心跳
+func (c *Client) roundTrip(trans *TCPTransport, message *protocol.Message) (*protocol.Message, bool, error) {
+	if err := trans.WriteMessage(message); err != nil {
+		return nil, false, err
+	}
+
+	goAway := false
+	for {
+		resp, err := trans.ReadMessage()
+		if err != nil {
+			return nil, goAway, err
+		}
+		switch resp.Header.Type {
+		case protocol.TypeGoAway:
+			goAway = true
+		case protocol.TypeHeartbeat:
+			if err := trans.HandleHeartbeat(resp); err != nil {
+				return nil, goAway, err
+			}
+		default:
+			return resp, goAway, nil
+		}
+	}
 }
 
 func (c *Client) Receive() ([]byte, error) {
-	conn, err := c.pool.Get()
+	conn, err := c.pool.Get(context.Background())
 	if err != nil {
 		return nil, err
 	}
@@ -75,6 +99,16 @@ func (c *Client) Receive() ([]byte, error) {
 	return conn.Receive()
 }
 
+// Addr 返回连接的服务端地址
+func (c *Client) Addr() string {
+	return c.address
+}
+
+// Stats 返回连接池状态
+func (c *Client) Stats() PoolStats {
+	return c.pool.Stats()
+}
+
 func (c *Client) Close() error {
 	return c.pool.Close()
 }
\ No newline at end of file
@@ -3,26 +3,225 @@ package transport
 import (
 	"bytes"
 	"compress/gzip"
+	"errors"
+	"fmt"
 	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/eason-lee/l-rpc/protocol"
+	"github.com/eason-lee/l-rpc/status"
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
 )
 
+// CompressType 压缩算法, 记录在 protocol.Header.Compress 中
 type CompressType byte
 
 const (
 	CompressNone CompressType = iota
 	CompressGzip
+	// CompressSnappy Snappy 格式的 LZ 压缩, 速度快, 适合延迟敏感的调用
+	CompressSnappy
+	// CompressZstd 压缩率高, 适合较大的消息
+	CompressZstd
 )
 
+func (t CompressType) String() string {
+	if entry, ok := lookupCompressor(t); ok {
+		return entry.name
+	}
+	if t == CompressNone {
+		return "none"
+	}
+	return fmt.Sprintf("CompressType(%d)", t)
+}
+
+// DefaultCompressMinSize 默认的最小压缩大小, 更小的消息压缩收益不足以抵消开销
+const DefaultCompressMinSize = 1024
+
+// AcceptCompressKey 元数据中声明本端可以解压的算法, 值为逗号分隔的算法名称
+// 客户端在请求中声明后服务端才会压缩响应, 服务端在响应中声明后客户端才会压缩之后的请求
+const AcceptCompressKey = "lrpc-accept-compress"
+
+// ErrUnsupportedCompress 不支持的压缩算法
+var ErrUnsupportedCompress = errors.New("unsupported compress type")
+
+// Compressor 压缩算法, 需要可以并发使用
 type Compressor interface {
 	Compress([]byte) ([]byte, error)
 	Decompress([]byte) ([]byte, error)
 }
 
+// LimitedDecompressor 可以在解压过程中限制输出长度的压缩算法, 解压后超过 maxSize 时返回 protocol.ErrFrameTooLarge
+// 避免很小的压缩数据解压出超大的消息体; 未实现该接口的算法在解压完成后检查长度
+type LimitedDecompressor interface {
+	DecompressLimit(data []byte, maxSize int) ([]byte, error)
+}
+
+type compressorEntry struct {
+	name       string
+	compressor Compressor
+}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[CompressType]compressorEntry{}
+)
+
+func init() {
+	status.RegisterError(ErrUnsupportedCompress, status.Unimplemented, "UNSUPPORTED_COMPRESS")
+	RegisterCompressor(CompressGzip, "gzip", &GzipCompressor{})
+	RegisterCompressor(CompressSnappy, "snappy", &SnappyCompressor{})
+	RegisterCompressor(CompressZstd, "zstd", NewZstdCompressor())
+}
+
+// RegisterCompressor 注册压缩算法, 已存在时覆盖; 通信双方需要使用相同的类型和名称
+func RegisterCompressor(t CompressType, name string, c Compressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[t] = compressorEntry{name: name, compressor: c}
+}
+
+// GetCompressor 获取已注册的压缩算法
+func GetCompressor(t CompressType) (Compressor, bool) {
+	entry, ok := lookupCompressor(t)
+	return entry.compressor, ok
+}
+
+func lookupCompressor(t CompressType) (compressorEntry, bool) {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	entry, ok := compressors[t]
+	return entry, ok
+}
+
+// CompressTypes 返回所有已注册的压缩算法, 按类型排序
+func CompressTypes() []CompressType {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+
+	types := make([]CompressType, 0, len(compressors))
+	for t := range compressors {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i] < types[j]
+	})
+	return types
+}
+
+// ParseCompressTypes 解析逗号分隔的算法名称, 忽略未注册的算法
+func ParseCompressTypes(names string) []CompressType {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+
+	var types []CompressType
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		for t, entry := range compressors {
+			if entry.name == name {
+				types = append(types, t)
+				break
+			}
+		}
+	}
+	return types
+}
+
+// FormatCompressTypes 将算法编码为逗号分隔的名称
+func FormatCompressTypes(types []CompressType) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = t.String()
+	}
+	return strings.Join(names, ",")
+}
+
+// NegotiateCompress 按本端的偏好顺序选择对端可以解压的算法, 没有时返回 CompressNone
+func NegotiateCompress(preferred, accepted []CompressType) CompressType {
+	for _, p := range preferred {
+		for _, a := range accepted {
+			if p == a {
+				return p
+			}
+		}
+	}
+	return CompressNone
+}
+
+// CompressMessage 使用指定算法压缩消息体并记录在消息头中, 消息体小于 minSize 时不压缩
+// 压缩后没有变小时保留原始消息体
+func CompressMessage(msg *protocol.Message, t CompressType, minSize int) error {
+	if t == CompressNone || len(msg.Data) < minSize || msg.Header.Compress != uint8(CompressNone) {
+		return nil
+	}
+	c, ok := GetCompressor(t)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedCompress, t)
+	}
+	data, err := c.Compress(msg.Data)
+	if err != nil {
+		return err
+	}
+	if len(data) >= len(msg.Data) {
+		return nil
+	}
+	msg.Data = data
+	msg.Header.Compress = uint8(t)
+	return nil
+}
+
+// DecompressMessage 按消息头记录的算法解压消息体, 解压后超过 maxSize 时返回 protocol.ErrFrameTooLarge
+// maxSize 通常为连接上允许接收的消息体最大长度, 小于等于 0 时使用 protocol.DefaultMaxBodySize
+func DecompressMessage(msg *protocol.Message, maxSize int) error {
+	t := CompressType(msg.Header.Compress)
+	if t == CompressNone {
+		return nil
+	}
+	c, ok := GetCompressor(t)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedCompress, t)
+	}
+	if maxSize <= 0 {
+		maxSize = protocol.DefaultMaxBodySize
+	}
+
+	var data []byte
+	var err error
+	if l, ok := c.(LimitedDecompressor); ok {
+		data, err = l.DecompressLimit(msg.Data, maxSize)
+	} else if data, err = c.Decompress(msg.Data); err == nil && len(data) > maxSize {
+		err = protocol.ErrFrameTooLarge
+	}
+	if err != nil {
+		return err
+	}
+	msg.Data = data
+	msg.Header.Compress = uint8(CompressNone)
+	return nil
+}
+
+// GzipCompressor gzip 压缩, 复用 gzip.Writer 和 gzip.Reader 减少内存分配
 type GzipCompressor struct{}
 
+var (
+	gzipWriterPool = sync.Pool{
+		New: func() interface{} {
+			return gzip.NewWriter(nil)
+		},
+	}
+	gzipReaderPool sync.Pool
+)
+
 func (c *GzipCompressor) Compress(data []byte) ([]byte, error) {
 	var buf bytes.Buffer
-	writer := gzip.NewWriter(&buf)
+	writer := gzipWriterPool.Get().(*gzip.Writer)
+	defer gzipWriterPool.Put(writer)
+
+	writer.Reset(&buf)
 	if _, err := writer.Write(data); err != nil {
 		return nil, err
 	}
@@ -33,10 +232,102 @@ func (c *GzipCompressor) Compress(data []byte) ([]byte, error) {
 }
 
 func (c *GzipCompressor) Decompress(data []byte) ([]byte, error) {
-	reader, err := gzip.NewReader(bytes.NewReader(data))
+	return c.DecompressLimit(data, math.MaxInt)
+}
+
+// DecompressLimit 最多读取 maxSize+1 字节, 超过 maxSize 时返回 protocol.ErrFrameTooLarge
+func (c *GzipCompressor) DecompressLimit(data []byte, maxSize int) ([]byte, error) {
+	reader, ok := gzipReaderPool.Get().(*gzip.Reader)
+	if ok {
+		if err := reader.Reset(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		reader, err = gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer gzipReaderPool.Put(reader)
+	defer reader.Close()
+
+	var r io.Reader = reader
+	if maxSize < math.MaxInt {
+		r = io.LimitReader(reader, int64(maxSize)+1)
+	}
+	out, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
-	defer reader.Close()
-	return io.ReadAll(reader)
-}
\ No newline at end of file
+	if len(out) > maxSize {
+		return nil, protocol.ErrFrameTooLarge
+	}
+	return out, nil
+}
+
+// SnappyCompressor Snappy 块格式压缩
+type SnappyCompressor struct{}
+
+func (c *SnappyCompressor) Compress(data []byte) ([]byte, error) {
+	return s2.EncodeSnappy(nil, data), nil
+}
+
+func (c *SnappyCompressor) Decompress(data []byte) ([]byte, error) {
+	return s2.Decode(nil, data)
+}
+
+// DecompressLimit 根据块格式中记录的原始长度在解压前检查
+func (c *SnappyCompressor) DecompressLimit(data []byte, maxSize int) ([]byte, error) {
+	n, err := s2.DecodedLen(data)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxSize {
+		return nil, protocol.ErrFrameTooLarge
+	}
+	return s2.Decode(nil, data)
+}
+
+// ZstdCompressor zstd 压缩, 编码器和解码器在所有调用间共享
+type ZstdCompressor struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+
+	// 按解压长度上限创建的解码器, 上限来自连接配置, 通常只有少数几种
+	limited sync.Map // int -> *zstd.Decoder
+}
+
+func NewZstdCompressor() *ZstdCompressor {
+	// 参数固定, 不会返回错误
+	encoder, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	decoder, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+	return &ZstdCompressor{encoder: encoder, decoder: decoder}
+}
+
+func (c *ZstdCompressor) Compress(data []byte) ([]byte, error) {
+	return c.encoder.EncodeAll(data, nil), nil
+}
+
+func (c *ZstdCompressor) Decompress(data []byte) ([]byte, error) {
+	return c.decoder.DecodeAll(data, nil)
+}
+
+// DecompressLimit 使用限制了解压长度的解码器, 解压过程中超过 maxSize 时停止
+func (c *ZstdCompressor) DecompressLimit(data []byte, maxSize int) ([]byte, error) {
+	v, ok := c.limited.Load(maxSize)
+	if !ok {
+		decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderMaxMemory(uint64(maxSize)))
+		if err != nil {
+			return nil, err
+		}
+		if v, ok = c.limited.LoadOrStore(maxSize, decoder); ok {
+			decoder.Close()
+		}
+	}
+	out, err := v.(*zstd.Decoder).DecodeAll(data, nil)
+	if errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+		return nil, protocol.ErrFrameTooLarge
+	}
+	return out, err
+}
@@ -0,0 +1,104 @@
+package transport
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/eason-lee/l-rpc/protocol"
+	"github.com/eason-lee/l-rpc/status"
+	"github.com/stretchr/testify/suite"
+)
+
+type CompressionTestSuite struct {
+	suite.Suite
+}
+
+func (s *CompressionTestSuite) TestCompressors() {
+	data := bytes.Repeat([]byte("hello l-rpc "), 1000)
+	for _, t := range []CompressType{CompressGzip, CompressSnappy, CompressZstd} {
+		c, ok := GetCompressor(t)
+		s.Require().True(ok, t.String())
+
+		compressed, err := c.Compress(data)
+		s.Require().NoError(err, t.String())
+		s.Less(len(compressed), len(data), t.String())
+
+		decompressed, err := c.Decompress(compressed)
+		s.Require().NoError(err, t.String())
+		s.Equal(data, decompressed, t.String())
+
+		_, err = c.Decompress([]byte("not compressed"))
+		s.Error(err, t.String())
+	}
+}
+
+func (s *CompressionTestSuite) TestCompressMessage() {
+	data := []byte(strings.Repeat("a", 2048))
+	msg := &protocol.Message{Header: &protocol.Header{}, Data: data}
+
+	// 小于最小压缩大小时不压缩
+	s.Require().NoError(CompressMessage(msg, CompressGzip, len(data)+1))
+	s.Equal(uint8(CompressNone), msg.Header.Compress)
+
+	s.Require().NoError(CompressMessage(msg, CompressZstd, DefaultCompressMinSize))
+	s.Equal(uint8(CompressZstd), msg.Header.Compress)
+	s.Less(len(msg.Data), len(data))
+
+	s.Require().NoError(DecompressMessage(msg, 0))
+	s.Equal(uint8(CompressNone), msg.Header.Compress)
+	s.Equal(data, msg.Data)
+
+	// 压缩后没有变小时保留原始消息体
+	random := []byte("\x8f\x12\x7a\x03\xee\x51\xc4\x99")
+	msg = &protocol.Message{Header: &protocol.Header{}, Data: random}
+	s.Require().NoError(CompressMessage(msg, CompressGzip, 0))
+	s.Equal(uint8(CompressNone), msg.Header.Compress)
+	s.Equal(random, msg.Data)
+
+	// 未注册的算法
+	msg = &protocol.Message{Header: &protocol.Header{Compress: 200}, Data: data}
+	err := DecompressMessage(msg, 0)
+	s.ErrorIs(err, ErrUnsupportedCompress)
+	s.Equal(status.Unimplemented, status.CodeOf(err))
+}
+
+func (s *CompressionTestSuite) TestDecompressLimit() {
+	data := bytes.Repeat([]byte("a"), 4096)
+	for _, t := range []CompressType{CompressGzip, CompressSnappy, CompressZstd} {
+		msg := &protocol.Message{Header: &protocol.Header{}, Data: data}
+		s.Require().NoError(CompressMessage(msg, t, 0))
+		compressed := msg.Data
+
+		// 很小的压缩数据解压后超过最大长度
+		s.Equal(protocol.ErrFrameTooLarge, DecompressMessage(msg, len(data)-1), t.String())
+		s.Equal(uint8(t), msg.Header.Compress, t.String())
+		s.Equal(compressed, msg.Data, t.String())
+
+		s.Require().NoError(DecompressMessage(msg, len(data)), t.String())
+		s.Equal(data, msg.Data, t.String())
+	}
+}
+
+func (s *CompressionTestSuite) TestNegotiate() {
+	s.Equal("gzip,snappy,zstd", FormatCompressTypes(CompressTypes()))
+	s.Equal([]CompressType{CompressZstd, CompressGzip}, ParseCompressTypes("zstd, unknown,gzip"))
+
+	accepted := ParseCompressTypes("gzip,snappy")
+	s.Equal(CompressSnappy, NegotiateCompress([]CompressType{CompressZstd, CompressSnappy, CompressGzip}, accepted))
+	s.Equal(CompressNone, NegotiateCompress([]CompressType{CompressZstd}, accepted))
+	s.Equal(CompressNone, NegotiateCompress([]CompressType{CompressGzip}, nil))
+}
+
+func BenchmarkGzipCompress(b *testing.B) {
+	data := bytes.Repeat([]byte("hello l-rpc "), 1000)
+	c := &GzipCompressor{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.Compress(data)
+	}
+}
+
+func TestCompressionSuite(t *testing.T) {
+	suite.Run(t, new(CompressionTestSuite))
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package transport
+
+import "net"
+
+// checkConn 不支持非阻塞窥探的平台上不检查连接, 对端已关闭的连接要到下次读写时才会发现
+func checkConn(conn net.Conn) error {
+	return nil
+}
@@ -0,0 +1,137 @@
+package transport
+
+import (
+	"errors"
+	"time"
+
+	"github.com/eason-lee/l-rpc/protocol"
+)
+
+const (
+	// defaultHeartbeatInterval 客户端建立的连接默认的心跳间隔
+	defaultHeartbeatInterval = 30 * time.Second
+	// defaultHeartbeatTimeout 默认等待 pong 的超时时间
+	defaultHeartbeatTimeout = 5 * time.Second
+)
+
+// ErrHeartbeatTimeout 超时没有收到 pong, 连接已被关闭
+var ErrHeartbeatTimeout = errors.New("heartbeat timeout")
+
+// heartbeat 定期发送 ping, 超时没有收到 pong 时认为连接已断开并关闭连接
+// pong 由连接的读协程通过 HandleHeartbeat 交给心跳协程
+func (t *TCPTransport) heartbeat() {
+	ticker := time.NewTicker(t.heartbeatInterval)
+	defer ticker.Stop()
+
+	var seq uint64
+	for {
+		select {
+		case <-ticker.C:
+		case <-t.heartbeatStop:
+			return
+		}
+
+		seq++
+		start := time.Now()
+		if err := t.writeHeartbeat(seq, protocol.HeartbeatPing); err != nil {
+			t.Close()
+			return
+		}
+		if !t.waitPong(seq, start) {
+			return
+		}
+	}
+}
+
+// waitPong 等待指定 ping 的 pong 并记录往返时间, 超时时关闭连接并返回 false
+func (t *TCPTransport) waitPong(seq uint64, start time.Time) bool {
+	timer := time.NewTimer(t.heartbeatTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case id := <-t.pongs:
+			// 忽略已经超时的 ping 的 pong
+			if id == seq {
+				t.rtt.Store(int64(time.Since(start)))
+				return true
+			}
+		case <-timer.C:
+			t.fail(ErrHeartbeatTimeout)
+			return false
+		case <-t.heartbeatStop:
+			return false
+		}
+	}
+}
+
+// writeHeartbeat 发送 ping 或 pong
+func (t *TCPTransport) writeHeartbeat(id uint64, kind byte) error {
+	msg := &protocol.Message{
+		Header: &protocol.Header{
+			ID:   id,
+			Type: protocol.TypeHeartbeat,
+		},
+		Data: []byte{kind},
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// 设置写入超时, 避免对端不读取时心跳协程一直阻塞
+	t.conn.SetWriteDeadline(time.Now().Add(t.heartbeatTimeout))
+	defer t.conn.SetWriteDeadline(time.Time{})
+	return t.writer.WriteMessage(msg)
+}
+
+// HandleHeartbeat 处理读协程收到的心跳消息, 收到 ping 时回复 pong, 收到 pong 时交给心跳协程计算往返时间
+func (t *TCPTransport) HandleHeartbeat(msg *protocol.Message) error {
+	if len(msg.Data) == 0 {
+		return protocol.ErrInvalidMessage
+	}
+
+	switch msg.Data[0] {
+	case protocol.HeartbeatPing:
+		return t.writeHeartbeat(msg.Header.ID, protocol.HeartbeatPong)
+	case protocol.HeartbeatPong:
+		select {
+		case t.pongs <- msg.Header.ID:
+		default:
+		}
+		return nil
+	default:
+		return protocol.ErrInvalidMessage
+	}
+}
+
+// RTT 返回最近一次心跳的往返时间, 还没有收到过 pong 时返回 0
+func (t *TCPTransport) RTT() time.Duration {
+	return time.Duration(t.rtt.Load())
+}
+
+// ConnStats 单个连接的状态
+type ConnStats struct {
+	RTT time.Duration // 最近一次心跳的往返时间, 还没有收到过 pong 时为 0
+}
+
+// Stats 返回连接当前状态
+func (t *TCPTransport) Stats() ConnStats {
+	return ConnStats{RTT: t.RTT()}
+}
+
+// fail 以指定原因关闭连接, 之后的读取和 Ping 返回该错误
+func (t *TCPTransport) fail(err error) {
+	t.errMu.Lock()
+	if t.err == nil {
+		t.err = err
+	}
+	t.errMu.Unlock()
+	t.Close()
+}
+
+// Err 返回连接被心跳判定为断开的原因, 连接正常时返回 nil
+func (t *TCPTransport) Err() error {
+	t.errMu.Lock()
+	defer t.errMu.Unlock()
+	return t.err
+}
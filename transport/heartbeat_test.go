@@ -0,0 +1,115 @@
+package transport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eason-lee/l-rpc/protocol"
+	"github.com/stretchr/testify/suite"
+)
+
+type HeartbeatTestSuite struct {
+	suite.Suite
+}
+
+// serve 启动服务端, handle 为 true 时回复心跳, 否则只读取不回复
+func (s *HeartbeatTestSuite) serve(handle bool, opts ...Option) *Server {
+	srv, err := NewServer("127.0.0.1:0", opts...)
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { srv.Close() })
+
+	go srv.Accept(func(trans Transport) {
+		codec := protocol.NewDefaultCodec()
+		for {
+			data, err := trans.Receive()
+			if err != nil {
+				return
+			}
+			msg, err := codec.Decode(data)
+			if err != nil {
+				return
+			}
+			if handle && msg.Header.Type == protocol.TypeHeartbeat {
+				trans.(*TCPTransport).HandleHeartbeat(msg)
+			}
+		}
+	})
+	return srv
+}
+
+// readLoop 在客户端连接上读取并处理心跳, 返回读协程退出时的错误
+func (s *HeartbeatTestSuite) readLoop(trans *TCPTransport) <-chan error {
+	errCh := make(chan error, 1)
+	go func() {
+		codec := protocol.NewDefaultCodec()
+		for {
+			data, err := trans.Receive()
+			if err != nil {
+				errCh <- err
+				return
+			}
+			msg, err := codec.Decode(data)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			if msg.Header.Type == protocol.TypeHeartbeat {
+				trans.HandleHeartbeat(msg)
+			}
+		}
+	}()
+	return errCh
+}
+
+func (s *HeartbeatTestSuite) TestPingPong() {
+	srv := s.serve(true)
+
+	trans, err := Dial("tcp", srv.Addr().String(), WithHeartbeat(20*time.Millisecond, 200*time.Millisecond))
+	s.Require().NoError(err)
+	defer trans.Close()
+	s.readLoop(trans)
+
+	s.Eventually(func() bool { return trans.RTT() > 0 }, time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	s.NoError(trans.Err())
+}
+
+func (s *HeartbeatTestSuite) TestMissedPong() {
+	srv := s.serve(false)
+
+	trans, err := Dial("tcp", srv.Addr().String(), WithHeartbeat(20*time.Millisecond, 50*time.Millisecond))
+	s.Require().NoError(err)
+	defer trans.Close()
+	errCh := s.readLoop(trans)
+
+	select {
+	case err := <-errCh:
+		s.ErrorIs(err, ErrHeartbeatTimeout)
+	case <-time.After(time.Second):
+		s.Fail("连接没有因心跳超时关闭")
+	}
+	s.ErrorIs(trans.Err(), ErrHeartbeatTimeout)
+	s.ErrorIs(trans.Ping(), ErrHeartbeatTimeout)
+	s.Zero(trans.RTT())
+}
+
+func (s *HeartbeatTestSuite) TestServerPing() {
+	// 服务端主动发送心跳, 客户端不发送只回复
+	srv := s.serve(true, WithHeartbeat(20*time.Millisecond, 50*time.Millisecond))
+
+	trans, err := Dial("tcp", srv.Addr().String(), WithHeartbeat(0, 0))
+	s.Require().NoError(err)
+	defer trans.Close()
+	errCh := s.readLoop(trans)
+
+	select {
+	case err := <-errCh:
+		s.Failf("连接被关闭", "%v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	s.Zero(trans.RTT())
+}
+
+func TestHeartbeatSuite(t *testing.T) {
+	suite.Run(t, new(HeartbeatTestSuite))
+}
@@ -0,0 +1,125 @@
+package transport
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/eason-lee/l-rpc/protocol"
+)
+
+// Option 传输层配置项, 用于 NewServer、NewClient 和 Dial
+type Option func(*options)
+
+type options struct {
+	tlsConfig        *tls.Config
+	encryptor        Encryptor
+	handshakeTimeout time.Duration
+	pool             PoolConfig
+	// heartbeat 为 nil 时客户端建立的连接使用默认的心跳配置, 服务端接受的连接不主动发送心跳
+	heartbeat *heartbeatConfig
+	// 消息头和消息体的最大长度, 为 0 时使用 protocol 中的默认值
+	maxHeaderSize int
+	maxBodySize   int
+}
+
+type heartbeatConfig struct {
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// defaultHandshakeTimeout 默认的 TLS 握手超时时间
+const defaultHandshakeTimeout = 10 * time.Second
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		handshakeTimeout: defaultHandshakeTimeout,
+		pool: PoolConfig{
+			MaxIdle:     5,                // 最大空闲连接数
+			MaxActive:   20,               // 最大活跃连接数
+			IdleTimeout: 30 * time.Second, // 空闲超时时间
+			Wait:        true,
+		},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithTLS 使用 TLS 加密连接
+// 服务端的配置需要提供证书, 设置 ClientAuth 和 ClientCAs 后校验客户端证书 (mTLS);
+// 客户端的配置未设置 ServerName 时使用连接地址中的主机名校验服务端证书
+func WithTLS(config *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = config
+	}
+}
+
+// WithEncryptor 使用对称加密器加密每一帧数据, 通信双方需要使用相同的密钥
+// 只用于无法使用 TLS 的场景, 不提供身份认证, 默认不加密
+func WithEncryptor(encryptor Encryptor) Option {
+	return func(o *options) {
+		o.encryptor = encryptor
+	}
+}
+
+// WithHandshakeTimeout 设置 TLS 握手超时时间, 默认 10 秒
+func WithHandshakeTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.handshakeTimeout = timeout
+	}
+}
+
+// WithPool 设置 NewClient 的连接池配置, 默认最多 5 个空闲连接、20 个连接, 空闲 30 秒后关闭,
+// 连接数达到上限时等待其他请求归还连接; Factory 由 NewClient 设置
+func WithPool(config PoolConfig) Option {
+	return func(o *options) {
+		o.pool = config
+	}
+}
+
+// WithHeartbeat 设置连接的心跳, 每隔 interval 发送一次 ping, timeout 内没有收到 pong 时关闭连接
+// interval 为 0 时不发送 ping, 只回复对端的 ping; 默认 Dial 建立的连接每 30 秒发送一次, 超时时间 5 秒,
+// NewServer 接受的连接不主动发送; 收到的心跳消息需要由读协程交给 TCPTransport.HandleHeartbeat 处理
+// NewClient 连接池中的空闲连接没有读协程, 不发送心跳也不回复 pong, 服务端开启心跳时这些连接会被关闭
+func WithHeartbeat(interval, timeout time.Duration) Option {
+	return func(o *options) {
+		o.heartbeat = &heartbeatConfig{interval: interval, timeout: timeout}
+	}
+}
+
+// WithMaxFrameSize 设置连接上收发的消息头和消息体的最大长度, 超过时返回 protocol.ErrFrameTooLarge,
+// 接收时会关闭连接; 默认消息头最大 1MB, 消息体最大 16MB
+func WithMaxFrameSize(maxHeaderSize, maxBodySize int) Option {
+	return func(o *options) {
+		o.maxHeaderSize = maxHeaderSize
+		o.maxBodySize = maxBodySize
+	}
+}
+
+// MaxBodySize 返回配置项中接收消息体的最大长度, 未设置时返回 protocol.DefaultMaxBodySize
+// 解压后的消息体同样不能超过该长度
+func MaxBodySize(opts ...Option) int {
+	if o := newOptions(opts); o.maxBodySize > 0 {
+		return o.maxBodySize
+	}
+	return protocol.DefaultMaxBodySize
+}
+
+// transportOpts 创建连接时使用的加密、心跳和帧大小配置, dial 表示连接由本端建立
+func (o *options) transportOpts(dial bool) TransportOpts {
+	opts := TransportOpts{
+		Encryptor:     o.encryptor,
+		MaxHeaderSize: o.maxHeaderSize,
+		MaxBodySize:   o.maxBodySize,
+	}
+	switch {
+	case o.heartbeat != nil:
+		opts.HeartbeatInterval = o.heartbeat.interval
+		opts.HeartbeatTimeout = o.heartbeat.timeout
+	case dial:
+		opts.HeartbeatInterval = defaultHeartbeatInterval
+		opts.HeartbeatTimeout = defaultHeartbeatTimeout
+	}
+	return opts
+}
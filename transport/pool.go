@@ -1,28 +1,59 @@
 package transport
 
 import (
+	"context"
 	"errors"
 	"sync"
 	"time"
 )
 
+var (
+	// ErrPoolClosed 连接池已关闭
+	ErrPoolClosed = errors.New("pool is closed")
+	// ErrPoolExhausted 连接数达到 MaxActive 且未开启等待
+	ErrPoolExhausted = errors.New("pool exhausted")
+)
+
 type Pool struct {
 	mu      sync.Mutex
-	conns   chan *TCPTransport
-	factory func() (*TCPTransport, error)
+	idle    []idleConn           // 空闲连接, 最近归还的在最后
+	waiters []chan *TCPTransport // 等待连接的请求, 按到达顺序
+	factory func(ctx context.Context) (*TCPTransport, error)
+	ping    func(conn *TCPTransport) error
 	closed  bool
+	done    chan struct{}
 
 	// 连接池配置
 	maxIdle     int           // 最大空闲连接数
 	maxActive   int           // 最大活跃连接数
 	idleTimeout time.Duration // 空闲超时时间
+	wait        bool          // 连接数达到上限时是否等待
+
+	// 统计
+	active       int // 已借出的连接数
+	waitCount    int64
+	waitDuration time.Duration
+	dials        int64
+	dialErrors   int64
+}
+
+type idleConn struct {
+	conn  *TCPTransport
+	since time.Time
 }
 
 type PoolConfig struct {
 	MaxIdle     int
-	MaxActive   int
-	IdleTimeout time.Duration
-	Factory     func() (*TCPTransport, error)
+	MaxActive   int           // 已借出和空闲的连接总数上限
+	IdleTimeout time.Duration // 空闲超过该时间的连接由后台协程关闭, 为 0 时不关闭
+	// Wait 为 true 时连接数达到 MaxActive 后等待其他请求归还连接, 直到上下文结束;
+	// 为 false 时立即返回 ErrPoolExhausted
+	Wait bool
+	// Factory 建立新连接, 上下文结束时应放弃建立
+	Factory func(ctx context.Context) (*TCPTransport, error)
+	// Ping 借出空闲连接前检查连接是否可用, 返回错误时关闭该连接, 默认使用 TCPTransport.Ping,
+	// 默认检查在非 unix 平台上不发现失效的连接, 需要时可以在这里发送心跳
+	Ping func(conn *TCPTransport) error
 }
 
 func NewPool(config PoolConfig) (*Pool, error) {
@@ -32,69 +63,268 @@ func NewPool(config PoolConfig) (*Pool, error) {
 	if config.Factory == nil {
 		return nil, errors.New("factory func is required")
 	}
+	if config.Ping == nil {
+		config.Ping = (*TCPTransport).Ping
+	}
 
-	return &Pool{
-		conns:       make(chan *TCPTransport, config.MaxIdle),
+	p := &Pool{
 		factory:     config.Factory,
-		maxIdle:     config.MaxIdle,
+		ping:        config.Ping,
+		done:        make(chan struct{}),
+		maxIdle:     min(config.MaxIdle, config.MaxActive),
 		maxActive:   config.MaxActive,
 		idleTimeout: config.IdleTimeout,
-	}, nil
+		wait:        config.Wait,
+	}
+	if p.idleTimeout > 0 {
+		go p.reap()
+	}
+	return p, nil
 }
 
-func (p *Pool) Get() (*TCPTransport, error) {
-    if p.closed {
-        return nil, errors.New("pool is closed")
-    }
-
-    select {
-    case conn := <-p.conns:
-        // 检查连接是否存活
-        if time.Since(conn.lastActiveTime) > p.idleTimeout {
-            conn.Close()
-            return p.factory()
-        }
-        return conn, nil
-    default:
-        return p.factory()
-    }
+// Get 借出一个连接, 优先复用空闲连接, 使用完后需要调用 Put 归还或调用 Discard 丢弃
+// 连接数达到 MaxActive 时按 Wait 配置等待或返回 ErrPoolExhausted
+func (p *Pool) Get(ctx context.Context) (*TCPTransport, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrPoolClosed
+		}
+
+		if n := len(p.idle); n > 0 {
+			ic := p.idle[n-1]
+			p.idle = p.idle[:n-1]
+			p.active++
+			p.mu.Unlock()
+
+			if p.expired(ic.since) || p.ping(ic.conn) != nil {
+				p.Discard(ic.conn)
+				continue
+			}
+			return ic.conn, nil
+		}
+
+		if p.active+len(p.idle) < p.maxActive {
+			p.active++
+			p.mu.Unlock()
+			return p.dial(ctx)
+		}
+
+		if !p.wait {
+			p.mu.Unlock()
+			return nil, ErrPoolExhausted
+		}
+
+		conn, err := p.waitConn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if conn == nil {
+			// 有连接被关闭, 使用空出的名额建立新连接
+			return p.dial(ctx)
+		}
+		if p.ping(conn) != nil {
+			p.Discard(conn)
+			continue
+		}
+		return conn, nil
+	}
+}
+
+// waitConn 等待归还的连接或空出的名额, 调用方需要持有锁, 返回时已释放锁
+// 返回 nil 连接表示已为调用方占用了一个名额, 需要建立新连接
+func (p *Pool) waitConn(ctx context.Context) (*TCPTransport, error) {
+	ch := make(chan *TCPTransport, 1)
+	p.waiters = append(p.waiters, ch)
+	p.waitCount++
+	p.mu.Unlock()
+
+	start := time.Now()
+	defer func() {
+		p.mu.Lock()
+		p.waitDuration += time.Since(start)
+		p.mu.Unlock()
+	}()
+
+	select {
+	case conn, ok := <-ch:
+		if !ok {
+			return nil, ErrPoolClosed
+		}
+		return conn, nil
+	case <-ctx.Done():
+		p.mu.Lock()
+		for i, w := range p.waiters {
+			if w == ch {
+				p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+				p.mu.Unlock()
+				return nil, ctx.Err()
+			}
+		}
+		p.mu.Unlock()
+
+		// 已经分配给该请求的连接或名额还给连接池
+		if conn, ok := <-ch; ok {
+			if conn != nil {
+				p.Put(conn)
+			} else {
+				p.release()
+			}
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// dial 使用已占用的名额建立新连接, 失败时释放名额
+func (p *Pool) dial(ctx context.Context) (*TCPTransport, error) {
+	conn, err := p.factory(ctx)
+
+	p.mu.Lock()
+	p.dials++
+	if err != nil {
+		p.dialErrors++
+	}
+	p.mu.Unlock()
+
+	if err != nil {
+		p.release()
+		return nil, err
+	}
+	return conn, nil
 }
 
 func (p *Pool) Put(conn *TCPTransport) error {
-	if p.closed {
-		return conn.Close()
+	p.mu.Lock()
+	if !p.closed {
+		// 优先交给等待中的请求
+		if len(p.waiters) > 0 {
+			ch := p.waiters[0]
+			p.waiters = p.waiters[1:]
+			ch <- conn
+			p.mu.Unlock()
+			return nil
+		}
+		if len(p.idle) < p.maxIdle {
+			p.active--
+			p.idle = append(p.idle, idleConn{conn: conn, since: time.Now()})
+			p.mu.Unlock()
+			return nil
+		}
 	}
+	p.mu.Unlock()
+
+	// 连接池关闭后归还的连接或空闲连接达到最大值时直接关闭
+	return p.Discard(conn)
+}
+
+// Discard 关闭借出的连接并释放其占用的名额, 用于出错或不应再复用的连接
+func (p *Pool) Discard(conn *TCPTransport) error {
+	err := conn.Close()
+	p.release()
+	return err
+}
 
+// release 释放一个已借出连接的名额, 有等待中的请求时转交给该请求
+func (p *Pool) release() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	// 如果空闲连接达到最大值，直接关闭
-	if len(p.conns) >= p.maxIdle {
-		return conn.Close()
+	if !p.closed && len(p.waiters) > 0 {
+		ch := p.waiters[0]
+		p.waiters = p.waiters[1:]
+		ch <- nil
+		return
 	}
+	p.active--
+}
 
-	select {
-	case p.conns <- conn:
-		return nil
-	default:
-		return conn.Close()
+func (p *Pool) expired(since time.Time) bool {
+	return p.idleTimeout > 0 && time.Since(since) > p.idleTimeout
+}
+
+// minReapInterval 后台协程检查空闲连接的最短间隔, 避免很小的 IdleTimeout 导致频繁唤醒
+const minReapInterval = 10 * time.Millisecond
+
+// reap 定期关闭空闲超时的连接
+func (p *Pool) reap() {
+	ticker := time.NewTicker(max(p.idleTimeout/2, minReapInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.done:
+			return
+		}
+
+		p.mu.Lock()
+		// 空闲连接按归还时间排列, 超时的都在前面
+		n := 0
+		for n < len(p.idle) && p.expired(p.idle[n].since) {
+			n++
+		}
+		expired := make([]idleConn, n)
+		copy(expired, p.idle[:n])
+		p.idle = append(p.idle[:0], p.idle[n:]...)
+		p.mu.Unlock()
+
+		for _, ic := range expired {
+			ic.conn.Close()
+		}
 	}
 }
 
-func (p *Pool) Close() error {
+// PoolStats 连接池状态
+type PoolStats struct {
+	Active       int // 已借出的连接数
+	Idle         int // 空闲连接数
+	MaxIdle      int
+	MaxActive    int
+	WaitCount    int64         // 累计等待连接的次数
+	WaitDuration time.Duration // 累计等待连接的时间
+	Dials        int64         // 累计建立连接的次数
+	DialErrors   int64         // 累计建立连接失败的次数
+}
+
+// Stats 返回连接池当前状态
+func (p *Pool) Stats() PoolStats {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	return PoolStats{
+		Active:       p.active,
+		Idle:         len(p.idle),
+		MaxIdle:      p.maxIdle,
+		MaxActive:    p.maxActive,
+		WaitCount:    p.waitCount,
+		WaitDuration: p.waitDuration,
+		Dials:        p.dials,
+		DialErrors:   p.dialErrors,
+	}
+}
+
+// Close 关闭所有空闲连接, 等待中的请求返回 ErrPoolClosed
+// 借出的连接在归还时关闭
+func (p *Pool) Close() error {
+	p.mu.Lock()
 	if p.closed {
+		p.mu.Unlock()
 		return nil
 	}
 	p.closed = true
+	close(p.done)
+	idle := p.idle
+	p.idle = nil
+	for _, ch := range p.waiters {
+		close(ch)
+	}
+	p.waiters = nil
+	p.mu.Unlock()
 
 	// 关闭所有连接
-	close(p.conns)
-	for conn := range p.conns {
-		conn.Close()
+	for _, ic := range idle {
+		ic.conn.Close()
 	}
-
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,205 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PoolTestSuite struct {
+	suite.Suite
+	listener net.Listener
+
+	mu    sync.Mutex
+	conns []net.Conn // 服务端接受的连接
+}
+
+func (s *PoolTestSuite) SetupTest() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	s.listener = listener
+	s.conns = nil
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+		}
+	}()
+}
+
+func (s *PoolTestSuite) TearDownTest() {
+	s.listener.Close()
+	s.closeServerConns()
+}
+
+func (s *PoolTestSuite) closeServerConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *PoolTestSuite) newPool(config PoolConfig) *Pool {
+	if config.MaxIdle == 0 {
+		config.MaxIdle = 1
+	}
+	if config.MaxActive == 0 {
+		config.MaxActive = 1
+	}
+	if config.Factory == nil {
+		config.Factory = func(ctx context.Context) (*TCPTransport, error) {
+			return DialContext(ctx, "tcp", s.listener.Addr().String())
+		}
+	}
+	p, err := NewPool(config)
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { p.Close() })
+	return p
+}
+
+func (s *PoolTestSuite) TestMaxActive() {
+	p := s.newPool(PoolConfig{MaxActive: 2})
+
+	conn1, err := p.Get(context.Background())
+	s.Require().NoError(err)
+	_, err = p.Get(context.Background())
+	s.Require().NoError(err)
+
+	// 不等待时立即失败
+	_, err = p.Get(context.Background())
+	s.ErrorIs(err, ErrPoolExhausted)
+	s.Equal(2, p.Stats().Active)
+
+	// 关闭的连接释放名额
+	s.Require().NoError(p.Discard(conn1))
+	_, err = p.Get(context.Background())
+	s.Require().NoError(err)
+
+	stats := p.Stats()
+	s.Equal(2, stats.Active)
+	s.Equal(int64(3), stats.Dials)
+}
+
+func (s *PoolTestSuite) TestWait() {
+	p := s.newPool(PoolConfig{Wait: true})
+
+	conn, err := p.Get(context.Background())
+	s.Require().NoError(err)
+
+	// 上下文结束时放弃等待
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = p.Get(ctx)
+	s.ErrorIs(err, context.DeadlineExceeded)
+
+	// 归还的连接直接交给等待中的请求
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		p.Put(conn)
+	}()
+	got, err := p.Get(context.Background())
+	s.Require().NoError(err)
+	s.Same(conn, got)
+
+	stats := p.Stats()
+	s.Equal(1, stats.Active)
+	s.Equal(0, stats.Idle)
+	s.Equal(int64(2), stats.WaitCount)
+	s.GreaterOrEqual(stats.WaitDuration, 100*time.Millisecond)
+	s.Equal(int64(1), stats.Dials)
+
+	// 关闭连接池时等待中的请求返回错误
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := p.Get(context.Background())
+		errCh <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	p.Close()
+	s.ErrorIs(<-errCh, ErrPoolClosed)
+}
+
+func (s *PoolTestSuite) TestPing() {
+	p := s.newPool(PoolConfig{})
+
+	conn, err := p.Get(context.Background())
+	s.Require().NoError(err)
+	s.Require().NoError(conn.Ping())
+	s.Require().NoError(p.Put(conn))
+
+	// 对端关闭的空闲连接在借出时被丢弃
+	s.Eventually(func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.conns) == 1
+	}, time.Second, 10*time.Millisecond)
+	s.closeServerConns()
+	s.Eventually(func() bool { return conn.Ping() != nil }, time.Second, 10*time.Millisecond)
+
+	got, err := p.Get(context.Background())
+	s.Require().NoError(err)
+	s.NotSame(conn, got)
+	s.Equal(int64(2), p.Stats().Dials)
+}
+
+func (s *PoolTestSuite) TestIdleReaper() {
+	p := s.newPool(PoolConfig{MaxIdle: 2, MaxActive: 2, IdleTimeout: 50 * time.Millisecond})
+
+	conn1, err := p.Get(context.Background())
+	s.Require().NoError(err)
+	conn2, err := p.Get(context.Background())
+	s.Require().NoError(err)
+	p.Put(conn1)
+	p.Put(conn2)
+	s.Equal(2, p.Stats().Idle)
+
+	s.Eventually(func() bool { return p.Stats().Idle == 0 }, time.Second, 10*time.Millisecond)
+	s.Error(conn1.Ping())
+	s.Error(conn2.Ping())
+}
+
+func (s *PoolTestSuite) TestTinyIdleTimeout() {
+	// 极小的空闲超时不会让后台协程创建无效的定时器
+	p := s.newPool(PoolConfig{MaxIdle: 1, MaxActive: 1, IdleTimeout: time.Nanosecond})
+
+	conn, err := p.Get(context.Background())
+	s.Require().NoError(err)
+	p.Put(conn)
+	s.Eventually(func() bool { return p.Stats().Idle == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func (s *PoolTestSuite) TestDialError() {
+	errDial := errors.New("dial failed")
+	p := s.newPool(PoolConfig{Factory: func(ctx context.Context) (*TCPTransport, error) {
+		return nil, errDial
+	}})
+
+	_, err := p.Get(context.Background())
+	s.ErrorIs(err, errDial)
+
+	stats := p.Stats()
+	s.Equal(0, stats.Active)
+	s.Equal(int64(1), stats.Dials)
+	s.Equal(int64(1), stats.DialErrors)
+
+	p.Close()
+	_, err = p.Get(context.Background())
+	s.ErrorIs(err, ErrPoolClosed)
+}
+
+func TestPoolSuite(t *testing.T) {
+	suite.Run(t, new(PoolTestSuite))
+}
@@ -1,6 +1,8 @@
 package transport
 
 import (
+    "context"
+    "crypto/tls"
     "net"
 )
 
@@ -8,14 +10,16 @@ import (
 type Server struct {
     listener net.Listener
     handler  func(Transport)
+    opts     *options
 }
 
-func NewServer(addr string) (*Server, error) {
+// NewServer 监听指定地址, 设置 WithTLS 时只接受 TLS 连接
+func NewServer(addr string, opts ...Option) (*Server, error) {
     listener, err := net.Listen("tcp", addr)
     if err != nil {
         return nil, err
     }
-    return &Server{listener: listener}, nil
+    return &Server{listener: listener, opts: newOptions(opts)}, nil
 }
 
 // Accept 接受新的连接
@@ -31,8 +35,31 @@ func (s *Server) Accept(handler func(Transport)) error {
     }
 }
 
+// Addr 返回监听地址
+func (s *Server) Addr() net.Addr {
+    return s.listener.Addr()
+}
+
+// Close 停止监听, 阻塞中的 Accept 会返回错误, 已建立的连接不受影响
+func (s *Server) Close() error {
+    return s.listener.Close()
+}
+
 func (s *Server) handleConn(conn net.Conn) {
-    transport := NewTCPTransport(conn)
+    // 握手完成后才交给处理函数, 处理函数可以通过 Peer 获取客户端证书
+    if s.opts.tlsConfig != nil {
+        tlsConn := tls.Server(conn, s.opts.tlsConfig)
+        ctx, cancel := context.WithTimeout(context.Background(), s.opts.handshakeTimeout)
+        err := tlsConn.HandshakeContext(ctx)
+        cancel()
+        if err != nil {
+            conn.Close()
+            return
+        }
+        conn = tlsConn
+    }
+
+    transport := NewTCPTransport(conn, s.opts.transportOpts(false))
     defer transport.Close()
     s.handler(transport)
 }
\ No newline at end of file
@@ -0,0 +1,169 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSConfig 基于证书文件的 TLS 配置
+type TLSConfig struct {
+	// 本端证书和私钥, 文件修改后自动重新加载; 客户端不设置时不提供证书
+	CertFile string
+	KeyFile  string
+	// 校验对端证书的 CA 证书, 为空时使用系统 CA
+	CAFile string
+	// 服务端是否要求客户端提供由 CAFile 签发的证书 (mTLS)
+	RequireClientCert bool
+	// 客户端校验的服务端名称, 为空时使用连接地址中的主机名
+	ServerName string
+	// 检查证书文件是否修改的间隔, 为 0 时每次握手都检查
+	ReloadInterval time.Duration
+}
+
+// ServerConfig 创建服务端的 tls.Config
+func (c *TLSConfig) ServerConfig() (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("tls: server certificate is required")
+	}
+	reloader, err := NewCertReloader(c.CertFile, c.KeyFile, c.ReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if c.RequireClientCert {
+		pool, err := loadCertPool(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// ClientConfig 创建客户端的 tls.Config
+func (c *TLSConfig) ClientConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.ServerName,
+	}
+	if c.CAFile != "" {
+		pool, err := loadCertPool(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		reloader, err := NewCertReloader(c.CertFile, c.KeyFile, c.ReloadInterval)
+		if err != nil {
+			return nil, err
+		}
+		config.GetClientCertificate = reloader.GetClientCertificate
+	}
+	return config, nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	if caFile == "" {
+		return x509.SystemCertPool()
+	}
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("tls: no certificates found in %s", caFile)
+	}
+	return pool, nil
+}
+
+// CertReloader 从文件加载证书, 握手时发现文件修改后重新加载, 证书轮换时无需重启服务
+// 重新加载失败时继续使用之前的证书
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+// NewCertReloader 加载证书, interval 为检查文件是否修改的最小间隔
+func NewCertReloader(certFile, keyFile string, interval time.Duration) (*CertReloader, error) {
+	r := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: interval,
+	}
+	modTime, err := r.stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(modTime); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate 用于 tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.certificate(), nil
+}
+
+// GetClientCertificate 用于 tls.Config.GetClientCertificate
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.certificate(), nil
+}
+
+// certificate 返回当前证书, 距上次检查超过 interval 且文件修改时重新加载
+func (r *CertReloader) certificate() *tls.Certificate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checked) >= r.interval {
+		r.checked = time.Now()
+		if modTime, err := r.stat(); err == nil && !modTime.Equal(r.modTime) {
+			r.load(modTime)
+		}
+	}
+	return r.cert
+}
+
+func (r *CertReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// stat 返回证书和私钥文件中较晚的修改时间
+func (r *CertReloader) stat() (time.Time, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if keyInfo.ModTime().After(certInfo.ModTime()) {
+		return keyInfo.ModTime(), nil
+	}
+	return certInfo.ModTime(), nil
+}
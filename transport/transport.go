@@ -1,102 +1,135 @@
 package transport
 
 import (
-	"encoding/binary"
-	"encoding/json"
-	"io"
+	"bufio"
+	"context"
+	"crypto/tls"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/eason-lee/l-rpc/peer"
+	"github.com/eason-lee/l-rpc/protocol"
 )
 
 // Transport 定义传输层接口
 type Transport interface {
+	// Send 发送数据并等待对端响应
 	Send(data []byte) ([]byte, error)
+	// Write 仅发送数据, 不等待响应, 用于同一连接上的多路复用
+	Write(data []byte) error
+	// Receive 接收数据
 	Receive() ([]byte, error)
+	// WriteMessage 编码并发送一条消息, 不等待响应
+	WriteMessage(msg *protocol.Message) error
+	// ReadMessage 接收并解码一条消息
+	ReadMessage() (*protocol.Message, error)
 	Close() error
 }
 
-const (
-	// 心跳相关常量
-	heartbeatInterval = 30 * time.Second
-	heartbeatTimeout  = 5 * time.Second
-)
-
-// HeartbeatMessage 心跳消息类型
-type HeartbeatMessage struct {
-	Type    byte  // 0: ping, 1: pong
-	TimeNow int64 // 发送时间戳
-}
 type TransportOpts struct {
 	Compressor Compressor
 	Encryptor  Encryptor
+	// HeartbeatInterval 发送 ping 的间隔, 为 0 时不发送心跳, 只回复对端的 ping
+	HeartbeatInterval time.Duration
+	// HeartbeatTimeout 等待 pong 的超时时间, 为 0 时使用默认的 5 秒
+	HeartbeatTimeout time.Duration
+	// MaxHeaderSize 和 MaxBodySize 接收的消息头和消息体的最大长度, 为 0 时使用
+	// protocol.DefaultMaxHeaderSize 和 protocol.DefaultMaxBodySize
+	MaxHeaderSize int
+	MaxBodySize   int
+}
+
+// Dial 建立连接, 设置 WithTLS 时完成 TLS 握手后返回
+func Dial(network, addr string, opts ...Option) (*TCPTransport, error) {
+	return DialContext(context.Background(), network, addr, opts...)
+}
+
+// DialContext 与 Dial 相同, 上下文结束时放弃建立连接和 TLS 握手
+func DialContext(ctx context.Context, network, addr string, opts ...Option) (*TCPTransport, error) {
+	o := newOptions(opts)
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.tlsConfig != nil {
+		config := o.tlsConfig
+		if config.ServerName == "" && !config.InsecureSkipVerify {
+			config = config.Clone()
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				host = addr
+			}
+			config.ServerName = host
+		}
+
+		tlsConn := tls.Client(conn, config)
+		ctx, cancel := context.WithTimeout(ctx, o.handshakeTimeout)
+		defer cancel()
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	return NewTCPTransport(conn, o.transportOpts(true)), nil
 }
 
 type TCPTransport struct {
 	conn           net.Conn
-	heartbeatStop  chan struct{}
+	reader         *protocol.FrameReader
+	writer         *protocol.FrameWriter
 	lastActiveTime time.Time
 	mu             sync.Mutex
-	compressor     Compressor
-	encryptor      Encryptor
+	closeOnce      sync.Once
+
+	// 心跳
+	heartbeatInterval time.Duration
+	heartbeatTimeout  time.Duration
+	heartbeatStop     chan struct{}
+	pongs             chan uint64  // 读协程收到的 pong 的消息ID
+	rtt               atomic.Int64 // 最近一次心跳的往返时间
+
+	errMu sync.Mutex
+	err   error // 连接被判定为断开的原因
 }
 
 func NewTCPTransport(conn net.Conn, opts ...TransportOpts) *TCPTransport {
-	opt :=  TransportOpts{
-		Compressor: &GzipCompressor{},
-		Encryptor:  NewAESEncryptor([]byte("default-secure-key-12345")),
-	}
+	// 默认不对整帧压缩和加密: 消息体按 Header.Compress 单独压缩, 需要加密时使用 TLS 或通过 WithEncryptor 开启对称加密
+	var opt TransportOpts
     if len(opts) > 0 {
         opt = opts[0]
     }
 	t :=  &TCPTransport{
-		conn:           conn,
-		heartbeatStop:  make(chan struct{}),
-		lastActiveTime: time.Now(),
-		compressor:     opt.Compressor,
-		encryptor:      opt.Encryptor,
-	}
-	go t.heartbeat()
-	
-	return t
-}
-
-func (t *TCPTransport) heartbeat() {
-	ticker := time.NewTicker(heartbeatInterval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			if err := t.sendHeartbeat(); err != nil {
-				t.Close()
-				return
-			}
-		case <-t.heartbeatStop:
-			return
-		}
+		conn:              conn,
+		lastActiveTime:    time.Now(),
+		heartbeatInterval: opt.HeartbeatInterval,
+		heartbeatTimeout:  opt.HeartbeatTimeout,
+		heartbeatStop:     make(chan struct{}),
+		pongs:             make(chan uint64, 1),
 	}
-}
-
-func (t *TCPTransport) sendHeartbeat() error {
-	heartbeat := HeartbeatMessage{
-		Type:    0, // ping
-		TimeNow: time.Now().UnixNano(),
+	br := bufio.NewReader(conn)
+	if opt.Compressor != nil || opt.Encryptor != nil {
+		// 对整帧加密或压缩时按信封帧收发
+		e := envelope{compressor: opt.Compressor, encryptor: opt.Encryptor}
+		t.reader = protocol.NewEnvelopeReader(br, e, opt.MaxHeaderSize, opt.MaxBodySize)
+		t.writer = protocol.NewEnvelopeWriter(conn, e, opt.MaxHeaderSize, opt.MaxBodySize)
+	} else {
+		t.reader = protocol.NewFrameReader(br, opt.MaxHeaderSize, opt.MaxBodySize)
+		t.writer = protocol.NewFrameWriter(conn, opt.MaxHeaderSize, opt.MaxBodySize)
 	}
-
-	data, err := json.Marshal(heartbeat)
-	if err != nil {
-		return err
+	if t.heartbeatTimeout <= 0 {
+		t.heartbeatTimeout = defaultHeartbeatTimeout
+	}
+	if t.heartbeatInterval > 0 {
+		go t.heartbeat()
 	}
 
-	// 设置写入超时
-	t.conn.SetWriteDeadline(time.Now().Add(heartbeatTimeout))
-	defer t.conn.SetWriteDeadline(time.Time{})
-
-	// 直接调用 send 方法，避免死锁
-	t.mu.Lock()
-	defer t.mu.Unlock()
-	return t.send(data)
+	return t
 }
 
 func (t *TCPTransport) updateLastActiveTime() {
@@ -120,80 +153,131 @@ func (t *TCPTransport) Send(data []byte) ([]byte, error) {
 	return t.receive()
 }
 
-// receive 接收原始数据
-func (t *TCPTransport) receive() ([]byte, error) {
-    // 先读取数据长度
-    var length uint32
-    if err := binary.Read(t.conn, binary.BigEndian, &length); err != nil {
-        return nil, err
-    }
-    
-    // 读取数据内容
-    data := make([]byte, length)
-    _, err := io.ReadFull(t.conn, data)
-    if err != nil {
-        return nil, err
-    }
-    return data, nil
+// Write 仅发送数据, 不等待响应
+func (t *TCPTransport) Write(data []byte) error {
+	t.updateLastActiveTime()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.send(data)
+}
+
+// WriteMessage 编码并发送一条消息, 不等待响应, 编码使用 protocol 缓冲池中的缓冲区
+func (t *TCPTransport) WriteMessage(msg *protocol.Message) error {
+	t.updateLastActiveTime()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.writer.WriteMessage(msg)
 }
 
 // Receive 接收数据
 func (t *TCPTransport) Receive() ([]byte, error) {
 	t.updateLastActiveTime()
+	return t.receive()
+}
 
-	data, err := t.receive()
+// receive 接收一帧数据
+func (t *TCPTransport) receive() ([]byte, error) {
+	data, err := t.reader.ReadFrame()
 	if err != nil {
-		return nil, err
+		return nil, t.readErr(err)
 	}
+	return data, nil
+}
 
-	if t.encryptor != nil {
-		data, err = t.encryptor.Decrypt(data)
-		if err != nil {
-			return nil, err
-		}
-	}
+// ReadMessage 接收并解码一条消息, 消息头使用 protocol 缓冲池中的缓冲区读取
+func (t *TCPTransport) ReadMessage() (*protocol.Message, error) {
+	t.updateLastActiveTime()
 
-	if t.compressor != nil {
-		data, err = t.compressor.Decompress(data)
-		if err != nil {
-			return nil, err
-		}
+	msg, err := t.reader.ReadMessage()
+	if err != nil {
+		return nil, t.readErr(err)
 	}
+	return msg, nil
+}
 
-	return data, nil
+// readErr 心跳超时关闭的连接返回 ErrHeartbeatTimeout
+func (t *TCPTransport) readErr(err error) error {
+	if connErr := t.Err(); connErr != nil {
+		return connErr
+	}
+	return err
 }
 
-// send 发送原始数据
+// send 发送一帧数据
 func (t *TCPTransport) send(data []byte) error {
-	// 压缩数据
-	if t.compressor != nil {
+	return t.writer.WriteFrame(data)
+}
+
+// envelope 对整帧压缩和加密, 发送时先压缩再加密, 接收时先解密再解压
+// 压缩和加密算法返回的数据不能与传入的数据共享内存
+type envelope struct {
+	compressor Compressor
+	encryptor  Encryptor
+}
+
+func (e envelope) Seal(frame []byte) ([]byte, error) {
+	data := frame
+	if e.compressor != nil {
 		var err error
-		data, err = t.compressor.Compress(data)
-		if err != nil {
-			return err
+		if data, err = e.compressor.Compress(data); err != nil {
+			return nil, err
+		}
+	}
+	if e.encryptor != nil {
+		var err error
+		if data, err = e.encryptor.Encrypt(data); err != nil {
+			return nil, err
 		}
 	}
+	return data, nil
+}
 
-	// 加密数据
-	if t.encryptor != nil {
+func (e envelope) Open(data []byte, maxSize int) ([]byte, error) {
+	if e.encryptor != nil {
 		var err error
-		data, err = t.encryptor.Encrypt(data)
-		if err != nil {
-			return err
+		if data, err = e.encryptor.Decrypt(data); err != nil {
+			return nil, err
 		}
 	}
+	if e.compressor == nil {
+		return data, nil
+	}
+	if l, ok := e.compressor.(LimitedDecompressor); ok {
+		return l.DecompressLimit(data, maxSize)
+	}
+	data, err := e.compressor.Decompress(data)
+	if err == nil && len(data) > maxSize {
+		return nil, protocol.ErrFrameTooLarge
+	}
+	return data, err
+}
 
-	// 写入数据长度
-	if err := binary.Write(t.conn, binary.BigEndian, uint32(len(data))); err != nil {
+// Ping 检查连接是否仍然可用, 不读取数据, 只用于没有协程在读取的空闲连接
+// 对端已关闭连接时返回 io.EOF, 依赖非阻塞窥探, 非 unix 平台上只返回连接已记录的错误
+func (t *TCPTransport) Ping() error {
+	if err := t.Err(); err != nil {
 		return err
 	}
+	return checkConn(t.conn)
+}
 
-	// 写入数据内容
-	_, err := t.conn.Write(data)
-	return err
+// Peer 返回连接对端的信息
+func (t *TCPTransport) Peer() *peer.Peer {
+	p := &peer.Peer{Addr: t.conn.RemoteAddr()}
+	if conn, ok := t.conn.(*tls.Conn); ok {
+		state := conn.ConnectionState()
+		p.TLS = &state
+	}
+	return p
 }
 
 func (t *TCPTransport) Close() error {
-	close(t.heartbeatStop)
-	return t.conn.Close()
+	var err error
+	t.closeOnce.Do(func() {
+		close(t.heartbeatStop)
+		err = t.conn.Close()
+	})
+	return err
 }
@@ -0,0 +1,104 @@
+package transport
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/eason-lee/l-rpc/protocol"
+	"github.com/stretchr/testify/suite"
+)
+
+type TransportTestSuite struct {
+	suite.Suite
+}
+
+// pipe 返回通过 net.Pipe 连接的两端
+func (s *TransportTestSuite) pipe(opts TransportOpts) (*TCPTransport, net.Conn) {
+	local, remote := net.Pipe()
+	trans := NewTCPTransport(local, opts)
+	s.T().Cleanup(func() {
+		trans.Close()
+		remote.Close()
+	})
+	return trans, remote
+}
+
+func (s *TransportTestSuite) TestFrames() {
+	for _, opts := range []TransportOpts{
+		{},
+		{Encryptor: NewAESEncryptor([]byte("0123456789abcdef"))},
+		{Compressor: &GzipCompressor{}, Encryptor: NewAESEncryptor([]byte("0123456789abcdef"))},
+	} {
+		local, remote := net.Pipe()
+		client, server := NewTCPTransport(local, opts), NewTCPTransport(remote, opts)
+
+		frame, err := protocol.NewDefaultCodec().Encode(&protocol.Message{
+			Header: &protocol.Header{ID: 1, Type: protocol.TypeRequest},
+			Data:   []byte("hello"),
+		})
+		s.Require().NoError(err)
+
+		go client.Write(frame)
+		data, err := server.Receive()
+		s.Require().NoError(err)
+		s.Equal(frame, data)
+
+		msg := &protocol.Message{Header: &protocol.Header{ID: 2, Type: protocol.TypeResponse}, Data: []byte("world")}
+		go server.WriteMessage(msg)
+		read, err := client.ReadMessage()
+		s.Require().NoError(err)
+		s.Equal(msg, read)
+
+		client.Close()
+		server.Close()
+	}
+}
+
+func (s *TransportTestSuite) TestMaxFrameSize() {
+	trans, remote := s.pipe(TransportOpts{MaxBodySize: 1024})
+
+	// 只发送声明了超大消息体的帧头, 接收端不等待消息体, 直接返回错误
+	frame, err := protocol.NewDefaultCodec().Encode(&protocol.Message{Header: &protocol.Header{}})
+	s.Require().NoError(err)
+	binary.BigEndian.PutUint32(frame[8:12], 1<<31)
+	go remote.Write(frame[:protocol.FrameHeaderSize])
+
+	_, err = trans.Receive()
+	s.ErrorIs(err, protocol.ErrFrameTooLarge)
+
+	// 发送超过限制的消息
+	frame, err = protocol.NewDefaultCodec().Encode(&protocol.Message{
+		Header: &protocol.Header{},
+		Data:   make([]byte, 1025),
+	})
+	s.Require().NoError(err)
+	s.ErrorIs(trans.Write(frame), protocol.ErrFrameTooLarge)
+
+	// 不是协议帧的数据不会被发送
+	s.ErrorIs(trans.Write([]byte("raw")), protocol.ErrInvalidMessage)
+}
+
+func (s *TransportTestSuite) TestEnvelope() {
+	trans, remote := s.pipe(TransportOpts{Encryptor: NewAESEncryptor([]byte("0123456789abcdef")), MaxBodySize: 1024})
+
+	// 加密后的帧作为信封帧发送, 没有额外的长度前缀
+	go trans.WriteMessage(&protocol.Message{Header: &protocol.Header{ID: 1}, Data: []byte("hello")})
+	prefix := make([]byte, protocol.FrameHeaderSize)
+	_, err := io.ReadFull(remote, prefix)
+	s.Require().NoError(err)
+	s.Equal("LRPE", string(prefix[0:4]))
+	_, err = io.CopyN(io.Discard, remote, int64(binary.BigEndian.Uint32(prefix[8:12])))
+	s.Require().NoError(err)
+
+	// 声明了超大信封的帧头直接返回错误
+	binary.BigEndian.PutUint32(prefix[8:12], 1<<31)
+	go remote.Write(prefix)
+	_, err = trans.ReadMessage()
+	s.ErrorIs(err, protocol.ErrFrameTooLarge)
+}
+
+func TestTransportSuite(t *testing.T) {
+	suite.Run(t, new(TransportTestSuite))
+}